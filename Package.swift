@@ -30,7 +30,8 @@ let package = Package(
     .package(url: "https://github.com/apple/swift-nio.git", from: "2.51.0"),
     .package(url: "https://github.com/apple/swift-nio-ssl.git", from: "2.10.1"),
     .package(url: "https://github.com/apple/swift-nio-transport-services.git", from: "1.9.1"),
-    .package(url: "https://github.com/apple/swift-protobuf.git", from: "1.9.0"),
+    // The messages the generator makes for -socket-format protobuf support 1.20 up to 2.0.
+    .package(url: "https://github.com/apple/swift-protobuf.git", from: "1.20.0"),
     .package(url: "https://github.com/grpc/grpc-swift.git", from: "1.16.0"),
     .package(url: "https://github.com/apple/swift-atomics.git", from: "1.1.0")
   ],
@@ -17,6 +17,8 @@
 import Logging
 import Foundation
 
+/// Sends the requests of the client. Requests are expected to be cancelled along with the task awaiting them, and
+/// streams to stop once their consumer terminates them.
 protocol HttpAdapterProtocol {
 	/// The logger to use with the adapter.
     var logger: Logger? { get set }
@@ -31,4 +33,26 @@ protocol HttpAdapterProtocol {
 	///   - timeoutSec: Request timeout.
 	/// - Returns: A task which resolves to the contents of the response.
     func sendAsync<T: Codable>(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) async throws -> T
+
+	/// Send a HTTP request without checking the status or decoding the response.
+	///
+	/// - Parameters:
+	///   - method: HTTP method to use for this request.
+	///   - uri: The fully qualified URI to use.
+	///   - headers: Request headers to set.
+	///   - body: Request content body to set.
+	///   - timeoutSec: Request timeout.
+	/// - Returns: A task which resolves to the raw body and the response.
+    func sendRawAsync(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) async throws -> (Data, HTTPURLResponse)
+
+	/// Send a HTTP request and stream the response body as it arrives.
+	///
+	/// - Parameters:
+	///   - method: HTTP method to use for this request.
+	///   - uri: The fully qualified URI to use.
+	///   - headers: Request headers to set.
+	///   - body: Request content body to set.
+	///   - timeoutSec: Request timeout.
+	/// - Returns: A stream of the chunks of the response body, which fails with an `ApiResponseError` for error statuses.
+    func streamAsync(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) -> AsyncThrowingStream<Data, Error>
 }
@@ -34,7 +34,7 @@ public class HttpClient: HttpClientProtocol {
     private let retryInvoker: RetryInvoker
     private var logger: Logger?
     
-    public init(scheme: String = "http", host: String = "127.0.0.1", port: Int = 7450, apiKey: String, autoRefreshSession: Bool = true) {
+    public init(scheme: String = "http", host: String = "127.0.0.1", port: Int = 7450, apiKey: String, autoRefreshSession: Bool = true, serverTrustEvaluator: ServerTrustEvaluating? = nil) {
         self.scheme = scheme
         self.host = host
         self.port = port
@@ -52,7 +52,7 @@ public class HttpClient: HttpClientProtocol {
         guard let url = URL(string: "\(scheme)://\(host):\(port)") else {
             fatalError("Invalid url is used")
         }
-        self.apiClient = ApiClient(baseUri: url, httpAdapter: HttpRequestAdapter(logger: self.logger))
+        self.apiClient = ApiClient(baseUri: url, httpAdapter: HttpRequestAdapter(logger: self.logger, serverTrustEvaluator: serverTrustEvaluator))
     }
     
     public func authenticate(id: String, defaultProperties: [String : String]? = nil, customProperties: [String : String]? = nil, retryConfig: RetryConfiguration? = nil) async throws -> Session {
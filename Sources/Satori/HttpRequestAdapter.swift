@@ -22,8 +22,25 @@ import Logging
 class HttpRequestAdapter: HttpAdapterProtocol {
     var logger: Logger?
     
-    init(logger: Logger? = nil) {
+    /// Evaluates the trust of the servers, nil for the default evaluation of the system.
+    let serverTrustEvaluator: ServerTrustEvaluating?
+    
+    private let session: URLSession
+    
+    init(logger: Logger? = nil, serverTrustEvaluator: ServerTrustEvaluating? = nil) {
         self.logger = logger
+        self.serverTrustEvaluator = serverTrustEvaluator
+        if let serverTrustEvaluator {
+            self.session = URLSession(configuration: .default, delegate: ServerTrustDelegate(evaluator: serverTrustEvaluator, logger: logger), delegateQueue: nil)
+        } else {
+            self.session = URLSession.shared
+        }
+    }
+    
+    deinit {
+        if session !== URLSession.shared {
+            session.finishTasksAndInvalidate()
+        }
     }
     
     func sendAsync<T: Codable>(method: String, uri: URL, headers: [String: String] = [:], body: Data? = nil, timeoutSec: Int = 60) async throws -> T {
@@ -36,51 +53,197 @@ class HttpRequestAdapter: HttpAdapterProtocol {
             request.httpBody = body
         }
         
-        return try await withCheckedThrowingContinuation { continuation in
-            let task = URLSession.shared.dataTask(with: request) { data, response, error in
-                if let error = error {
-                    self.logger?.error("Request failed: \(error.localizedDescription)")
-                    continuation.resume(throwing: error)
-                    return
-                }
-                
-                guard let httpResponse = response as? HTTPURLResponse, (200...299).contains(httpResponse.statusCode) else {
-                    self.logger?.error("Server returned an error")
-                    guard let data else {
-                        // No data
-                        let apiError = ApiResponseError(grpcStatusCode: 0, message: "HTTPError")
-                        apiError.statusCode = (response as? HTTPURLResponse)?.statusCode
-                        continuation.resume(throwing: apiError)
+        let cancellable = CancellableTask()
+        return try await withTaskCancellationHandler {
+            try await withCheckedThrowingContinuation { continuation in
+                let task = self.session.dataTask(with: request) { data, response, error in
+                    if let error = error {
+                        self.logger?.error("Request failed: \(error.localizedDescription)")
+                        continuation.resume(throwing: error)
+                        return
+                    }
+                    
+                    guard let httpResponse = response as? HTTPURLResponse, (200...299).contains(httpResponse.statusCode) else {
+                        self.logger?.error("Server returned an error")
+                        guard let data else {
+                            // No data
+                            let apiError = ApiResponseError(grpcStatusCode: 0, message: "HTTPError")
+                            apiError.statusCode = (response as? HTTPURLResponse)?.statusCode
+                            continuation.resume(throwing: apiError)
+                            return
+                        }
+                        
+                        // Decode error data
+                        do {
+                            let apiError = try JSONDecoder().decode(ApiResponseError.self, from: data)
+                            apiError.statusCode = (response as? HTTPURLResponse)?.statusCode
+                            continuation.resume(throwing: apiError)
+                        } catch {
+                            self.logger?.error("Failed to decode error response: \(error.localizedDescription)")
+                            continuation.resume(throwing: error)
+                        }
+                        return
+                    }
+                    
+                    guard let mimeType = httpResponse.mimeType, mimeType == "application/json", let data = data else {
+                        self.logger?.error("Invalid response data")
+                        continuation.resume(throwing: NSError(domain: "InvalidResponse", code: 0, userInfo: nil))
                         return
                     }
                     
-                    // Decode error data
                     do {
-                        let apiError = try JSONDecoder().decode(ApiResponseError.self, from: data)
-                        apiError.statusCode = (response as? HTTPURLResponse)?.statusCode
-                        continuation.resume(throwing: apiError)
+                        let decodedResponse = try JSONDecoder().decode(T.self, from: data)
+                        continuation.resume(returning: decodedResponse)
                     } catch {
-                        self.logger?.error("Failed to decode error response: \(error.localizedDescription)")
+                        self.logger?.error("Failed to decode response: \(error.localizedDescription)")
                         continuation.resume(throwing: error)
                     }
-                    return
-                }
-                
-                guard let mimeType = httpResponse.mimeType, mimeType == "application/json", let data = data else {
-                    self.logger?.error("Invalid response data")
-                    continuation.resume(throwing: NSError(domain: "InvalidResponse", code: 0, userInfo: nil))
-                    return
                 }
-                
-                do {
-                    let decodedResponse = try JSONDecoder().decode(T.self, from: data)
-                    continuation.resume(returning: decodedResponse)
-                } catch {
-                    self.logger?.error("Failed to decode response: \(error.localizedDescription)")
-                    continuation.resume(throwing: error)
+                cancellable.start(task)
+            }
+        } onCancel: {
+            cancellable.cancel()
+        }
+    }
+    
+    func sendRawAsync(method: String, uri: URL, headers: [String: String] = [:], body: Data? = nil, timeoutSec: Int = 60) async throws -> (Data, HTTPURLResponse) {
+        var request = URLRequest(url: uri)
+        request.httpMethod = method
+        request.allHTTPHeaderFields = headers
+        request.timeoutInterval = TimeInterval(timeoutSec)
+        
+        if let body {
+            request.httpBody = body
+        }
+        
+        let cancellable = CancellableTask()
+        return try await withTaskCancellationHandler {
+            try await withCheckedThrowingContinuation { continuation in
+                let task = self.session.dataTask(with: request) { data, response, error in
+                    if let error = error {
+                        self.logger?.error("Request failed: \(error.localizedDescription)")
+                        continuation.resume(throwing: error)
+                        return
+                    }
+                    
+                    guard let httpResponse = response as? HTTPURLResponse else {
+                        self.logger?.error("Invalid response")
+                        continuation.resume(throwing: NSError(domain: "InvalidResponse", code: 0, userInfo: nil))
+                        return
+                    }
+                    
+                    continuation.resume(returning: (data ?? Data(), httpResponse))
                 }
+                cancellable.start(task)
+            }
+        } onCancel: {
+            cancellable.cancel()
+        }
+    }
+    
+    func streamAsync(method: String, uri: URL, headers: [String: String] = [:], body: Data? = nil, timeoutSec: Int = 60) -> AsyncThrowingStream<Data, Error> {
+        var request = URLRequest(url: uri)
+        request.httpMethod = method
+        request.allHTTPHeaderFields = headers
+        request.timeoutInterval = TimeInterval(timeoutSec)
+        
+        if let body {
+            request.httpBody = body
+        }
+        
+        return AsyncThrowingStream { continuation in
+            let delegate = StreamingDelegate(continuation: continuation, serverTrustEvaluator: self.serverTrustEvaluator, logger: self.logger)
+            let session = URLSession(configuration: .default, delegate: delegate, delegateQueue: nil)
+            let task = session.dataTask(with: request)
+            continuation.onTermination = { _ in
+                task.cancel()
+                session.finishTasksAndInvalidate()
             }
             task.resume()
         }
     }
 }
+
+/// Holds the data task of a request, so cancelling the calling task cancels the request even before it is started.
+private final class CancellableTask: @unchecked Sendable {
+    private let lock = NSLock()
+    private var task: URLSessionTask?
+    private var cancelled = false
+    
+    /// Resumes the task, or cancels it right away when the calling task was cancelled already.
+    func start(_ task: URLSessionTask) {
+        lock.lock()
+        self.task = task
+        let cancelled = self.cancelled
+        lock.unlock()
+        
+        if cancelled {
+            task.cancel()
+        } else {
+            task.resume()
+        }
+    }
+    
+    func cancel() {
+        lock.lock()
+        cancelled = true
+        let task = self.task
+        lock.unlock()
+        
+        task?.cancel()
+    }
+}
+
+/// Forwards the chunks of a streamed response body to a stream as they arrive.
+private final class StreamingDelegate: NSObject, URLSessionDataDelegate {
+    private let continuation: AsyncThrowingStream<Data, Error>.Continuation
+    private let serverTrustEvaluator: ServerTrustEvaluating?
+    private let logger: Logger?
+    private var statusCode: Int?
+    private var errorBody = Data()
+    
+    init(continuation: AsyncThrowingStream<Data, Error>.Continuation, serverTrustEvaluator: ServerTrustEvaluating?, logger: Logger?) {
+        self.continuation = continuation
+        self.serverTrustEvaluator = serverTrustEvaluator
+        self.logger = logger
+    }
+    
+    func urlSession(_ session: URLSession, didReceive challenge: URLAuthenticationChallenge, completionHandler: @escaping (URLSession.AuthChallengeDisposition, URLCredential?) -> Void) {
+        guard let serverTrustEvaluator else {
+            completionHandler(.performDefaultHandling, nil)
+            return
+        }
+        ServerTrustDelegate.handle(challenge, with: serverTrustEvaluator, logger: logger, completionHandler: completionHandler)
+    }
+    
+    func urlSession(_ session: URLSession, dataTask: URLSessionDataTask, didReceive response: URLResponse, completionHandler: @escaping (URLSession.ResponseDisposition) -> Void) {
+        statusCode = (response as? HTTPURLResponse)?.statusCode
+        completionHandler(.allow)
+    }
+    
+    func urlSession(_ session: URLSession, dataTask: URLSessionDataTask, didReceive data: Data) {
+        if let statusCode, (200...299).contains(statusCode) {
+            continuation.yield(data)
+        } else {
+            errorBody.append(data)
+        }
+    }
+    
+    func urlSession(_ session: URLSession, task: URLSessionTask, didCompleteWithError error: Error?) {
+        if let error {
+            logger?.error("Request failed: \(error.localizedDescription)")
+            continuation.finish(throwing: error)
+            return
+        }
+        
+        guard let statusCode, (200...299).contains(statusCode) else {
+            logger?.error("Server returned an error")
+            let apiError = (try? JSONDecoder().decode(ApiResponseError.self, from: errorBody)) ?? ApiResponseError(grpcStatusCode: 0, message: "HTTPError")
+            apiError.statusCode = statusCode
+            continuation.finish(throwing: apiError)
+            return
+        }
+        
+        continuation.finish()
+    }
+}
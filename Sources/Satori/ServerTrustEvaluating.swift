@@ -0,0 +1,125 @@
+/*
+ * Copyright © 2024 The Satori Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import Foundation
+import Logging
+import Security
+
+/// Evaluates the trust of the servers the HTTP adapter connects to, so certificates or public keys can be pinned
+/// without replacing the adapter.
+public protocol ServerTrustEvaluating {
+    /// Evaluates the trust of a server, throwing to refuse the connection.
+    ///
+    /// - Parameters:
+    ///   - trust: The trust of the certificate chain the server presented.
+    ///   - host: The host of the server.
+    func evaluate(_ trust: SecTrust, forHost host: String) throws
+}
+
+/// The error of a server trust evaluation refusing a server.
+public struct ServerTrustError: Error {
+    /// The host of the refused server.
+    public let host: String
+    /// Why the server was refused.
+    public let reason: String
+}
+
+/// Accepts the servers which the system trusts and which present one of the pinned certificates in their chain.
+public struct PinnedCertificatesTrustEvaluator: ServerTrustEvaluating {
+    /// The DER encoded certificates pinned.
+    public let certificates: [Data]
+
+    public init(certificates: [SecCertificate]) {
+        self.certificates = certificates.map { SecCertificateCopyData($0) as Data }
+    }
+
+    public func evaluate(_ trust: SecTrust, forHost host: String) throws {
+        var error: CFError?
+        guard SecTrustEvaluateWithError(trust, &error) else {
+            throw ServerTrustError(host: host, reason: error.map { ($0 as Error).localizedDescription } ?? "The certificate chain isn't trusted")
+        }
+        for index in 0..<SecTrustGetCertificateCount(trust) {
+            if let certificate = SecTrustGetCertificateAtIndex(trust, index), certificates.contains(SecCertificateCopyData(certificate) as Data) {
+                return
+            }
+        }
+        throw ServerTrustError(host: host, reason: "No pinned certificate is in the certificate chain")
+    }
+}
+
+/// Accepts the servers which the system trusts and which present a certificate with one of the pinned public keys in
+/// their chain, so certificates can be renewed without updating the pins as long as their keys are kept.
+public struct PinnedPublicKeysTrustEvaluator: ServerTrustEvaluating {
+    /// The external representations of the public keys pinned.
+    public let publicKeys: [Data]
+
+    public init(publicKeys: [SecKey]) {
+        self.publicKeys = publicKeys.compactMap { SecKeyCopyExternalRepresentation($0, nil) as Data? }
+    }
+
+    /// Pins the public keys of certificates.
+    public init(certificates: [SecCertificate]) {
+        self.init(publicKeys: certificates.compactMap { SecCertificateCopyKey($0) })
+    }
+
+    public func evaluate(_ trust: SecTrust, forHost host: String) throws {
+        var error: CFError?
+        guard SecTrustEvaluateWithError(trust, &error) else {
+            throw ServerTrustError(host: host, reason: error.map { ($0 as Error).localizedDescription } ?? "The certificate chain isn't trusted")
+        }
+        for index in 0..<SecTrustGetCertificateCount(trust) {
+            if let certificate = SecTrustGetCertificateAtIndex(trust, index),
+               let key = SecCertificateCopyKey(certificate),
+               let data = SecKeyCopyExternalRepresentation(key, nil) as Data?,
+               publicKeys.contains(data) {
+                return
+            }
+        }
+        throw ServerTrustError(host: host, reason: "No pinned public key is in the certificate chain")
+    }
+}
+
+/// Answers the authentication challenges of sessions, evaluating server trust with an evaluator.
+final class ServerTrustDelegate: NSObject, URLSessionDelegate {
+    private let evaluator: ServerTrustEvaluating
+    private let logger: Logger?
+
+    init(evaluator: ServerTrustEvaluating, logger: Logger?) {
+        self.evaluator = evaluator
+        self.logger = logger
+    }
+
+    func urlSession(_ session: URLSession, didReceive challenge: URLAuthenticationChallenge, completionHandler: @escaping (URLSession.AuthChallengeDisposition, URLCredential?) -> Void) {
+        ServerTrustDelegate.handle(challenge, with: evaluator, logger: logger, completionHandler: completionHandler)
+    }
+
+    /// Evaluates server trust challenges with the evaluator, performing the default handling of any other challenge.
+    static func handle(_ challenge: URLAuthenticationChallenge, with evaluator: ServerTrustEvaluating, logger: Logger?, completionHandler: @escaping (URLSession.AuthChallengeDisposition, URLCredential?) -> Void) {
+        let space = challenge.protectionSpace
+        guard space.authenticationMethod == NSURLAuthenticationMethodServerTrust, let trust = space.serverTrust else {
+            completionHandler(.performDefaultHandling, nil)
+            return
+        }
+
+        do {
+            try evaluator.evaluate(trust, forHost: space.host)
+            completionHandler(.useCredential, URLCredential(trust: trust))
+        } catch {
+            logger?.error("Server trust evaluation failed for \(space.host): \(error.localizedDescription)")
+            completionHandler(.cancelAuthenticationChallenge, nil)
+        }
+    }
+}
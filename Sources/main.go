@@ -18,723 +18,9863 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 	"unicode"
 )
 
-const codeTemplate string = `/* Code generated by codegen/main.go. DO NOT EDIT. */
+// generatorVersion is the version of the generator, released along with the SDK.
+const generatorVersion = "1.2.0"
+
+const codeTemplate string = `{{- template "fileHeader" $ }}
+{{- if .Options.Combine }}
+#if canImport(Combine)
+import Combine
+#endif
+{{- end }}
+{{- if .Options.CircuitBreaker }}
+import Logging
+{{- end }}
+{{- template "namespaceDeclaration" $ }}
+{{- template "namespaceStart" $ }}
+{{- template "clientSupport" $ }}
+{{- template "modelSupport" $ }}
+{{- range $defname, $definition := .Definitions }}
+{{- template "definition" (definitionContext $defname) }}
+{{- end }}
+{{- template "client" $ }}
+{{- template "namespaceEnd" $ }}
+{{- template "clientExtensions" $ }}
+{{- template "modelExtensions" $ }}
+
+{{- define "fileHeader" }}/* Code generated by codegen/main.go. DO NOT EDIT. */
 
 import Foundation
+{{- end }}
+
+{{- define "namespaceDeclaration" }}
+{{- if .Options.WrapNamespace }}
+
+/// The namespace of the types generated for the {{ .Namespace }} API.
+{{ access }}enum {{ .Namespace }} {}
+{{- end }}
+{{- end }}
+
+{{- define "namespaceStart" }}
+{{- if .Options.WrapNamespace }}
+
+extension {{ .Namespace }} {
+{{- end }}
+{{- end }}
+
+{{- define "namespaceEnd" }}
+{{- if .Options.WrapNamespace }}
+} // {{ .Namespace }}
+{{- end }}
+{{- end }}
+
+{{- define "clientSupport" }}
 
 /// An Error generated for HTTPURLResponse that don't return a success status.
-public final class ApiResponseError: Error, Decodable {
+// Unchecked since statusCode and the details of the request are only set before the error is thrown.
+{{ access }}class ApiResponseError: Error, Decodable, @unchecked Sendable {
     /// The gRPC status code of the response.
-	public let grpcStatusCode: Int
+	{{ access }}let grpcStatusCode: GrpcStatusCode
     
     /// The message of the response.
-    public let message: String
+    {{ access }}let message: String
 
     /// The http status code of the response.
-	public var statusCode: Int?
+	{{ access }}var statusCode: Int?
+
+    /// The X-Request-Id header of the request, to find it in the server logs.
+    {{ access }}var requestId: String?
+
+    /// The method of the request.
+    {{ access }}var method: String?
+
+    /// The URL of the request.
+    {{ access }}var url: URL?
+
+    /// The headers of the response.
+    {{ access }}var responseHeaders: [String: String] = [:]
+
+    /// The raw body of the response, such as to read the fields the error doesn't decode.
+    {{ access }}var responseBody: Data?
+
+    /// Why the body of the response couldn't be decoded as the error, nil when it could.
+    {{ access }}var decodingError: Error?
 	
     private enum CodingKeys: String, CodingKey {
         case grpcStatusCode = "code"
         case message
     }
 
-    public init(grpcStatusCode: Int, message: String) {
+    {{ access }}init(grpcStatusCode: GrpcStatusCode, message: String) {
         self.grpcStatusCode = grpcStatusCode
         self.message = message
     }
 
-	public  var description: String {
-		return "ApiResponseError(StatusCode=\(statusCode ?? 0), Message='\(message)', GrpcStatusCode=\(grpcStatusCode))"
-	}
-}
-
-struct EmptyResponse: Codable {
-    init() {}
-}
+    /// Creates an error with a numeric gRPC status code, codes this client doesn't know are unknown.
+    {{ access }}convenience init(grpcStatusCode: Int, message: String) {
+        self.init(grpcStatusCode: GrpcStatusCode(rawValue: grpcStatusCode) ?? .unknown, message: message)
+    }
 
-{{- range $defname, $definition := .Definitions }}
-{{- $classname := $defname | title }}
+    {{ access }}required init(from decoder: Decoder) throws {
+        let container = try decoder.container(keyedBy: CodingKeys.self)
+        let code = try container.decodeIfPresent(Int.self, forKey: .grpcStatusCode) ?? 0
+        self.grpcStatusCode = GrpcStatusCode(rawValue: code) ?? .unknown
+        self.message = try container.decodeIfPresent(String.self, forKey: .message) ?? "HTTPError"
+    }
 
-{{- if isRefToEnum $defname }}
+    /// Decodes the error of a failed response, falling back to a plain ApiResponseError for unexpected bodies.
+    static func decode<T: ApiResponseError>(_ type: T.Type, statusCode: Int, data: Data, requestId: String? = nil, method: String? = nil, url: URL? = nil, response: HTTPURLResponse? = nil, coders: JSONCoders = .shared) -> ApiResponseError {
+        let error: ApiResponseError
+        do {
+            if statusCode == 429, type == ApiResponseError.self {
+                error = try coders.makeDecoder().decode(RateLimitedError.self, from: data)
+            } else {
+                error = try coders.makeDecoder().decode(type, from: data)
+            }
+        } catch let decodingError {
+            error = statusCode == 429 ? RateLimitedError(grpcStatusCode: 0, message: "HTTPError") : ApiResponseError(grpcStatusCode: 0, message: "HTTPError")
+            error.decodingError = decodingError
+        }
+        if let error = error as? RateLimitedError {
+            error.retryAfter = RateLimitedError.retryAfter(response?.value(forHTTPHeaderField: "Retry-After"))
+        }
+        error.statusCode = statusCode
+        error.requestId = requestId
+        error.method = method
+        error.url = url ?? response?.url
+        error.responseBody = data
+        for case let (name as String, value) in response?.allHeaderFields ?? [:] {
+            error.responseHeaders[name] = "\(value)"
+        }
+        return error
+    }
+{{- if $.Options.ResultVariants }}
 
-/// {{ $definition.Title }}
-public enum {{ $classname }}
-{
-    {{- range $idx, $enum := $definition.Enum }}
-    /// <summary>
-    /// {{ (index (splitEnumDescription $definition.Description) $idx) }}
-    /// </summary>
-    {{ $enum }} = {{ $idx }},
-    {{- end }}
-}
-{{- else }}
+    /// The error of a request which failed without an error response, set on the errors of the Result variants.
+    {{ access }}var underlyingError: Error?
 
-/// {{ (descriptionOrTitle $definition.Description $definition.Title) | stripNewlines }}
-protocol {{ $classname }}Protocol: Codable {
-    {{- range $propname, $property := $definition.Properties }}
-    {{- $fieldname := $propname }}
-    {{- if eq $fieldname "default" }}{{ $fieldname = "default_" }}{{ end }}
+    /// Wraps the error of a request as an ApiResponseError, returning ApiResponseErrors as they are.
+    static func wrapping(_ error: Error) -> ApiResponseError {
+        if let error = error as? ApiResponseError {
+            return error
+        }
+        let wrapped = ApiResponseError(grpcStatusCode: error is CancellationError ? .cancelled : .unknown, message: error.localizedDescription)
+        wrapped.underlyingError = error
+        return wrapped
+    }
+{{- end }}
 
-    /// {{ (descriptionOrTitle $property.Description $property.Title) | stripNewlines }}
-    {{- if eq $property.Type "integer"}}
-    var {{ $fieldname }}: Int { get }
-    {{- else if eq $property.Type "number" }}
-    var {{ $fieldname }}: Double { get }
-    {{- else if eq $property.Type "boolean" }}
-    var {{ $fieldname }}: Bool? { get }
-    {{- else if eq $property.Type "string"}}
-    var {{ $fieldname }}: String { get }
-    {{- else if eq $property.Type "array"}}
-        {{- if eq $property.Items.Type "string"}}
-    var {{ $fieldname }}: [String] { get }
-        {{- else if eq $property.Items.Type "integer"}}
-    var {{ $fieldname }}: [Int] { get }
-        {{- else if eq $property.Items.Type "number"}}
-    var {{ $fieldname }}: [Double] { get }
-        {{- else if eq $property.Items.Type "boolean"}}
-    var {{ $fieldname }}: [Bool] { get }
-        {{- else}}
-    var {{ $fieldname }}: [{{ $property.Items.Ref | cleanRef }}]? { get }
-        {{- end }}
-    {{- else if eq $property.Type "object"}}
-        {{- if eq $property.AdditionalProperties.Type "string" }}
-            {{- if eq $property.AdditionalProperties.Format "int64" }}
-    var {{ $fieldname }}: [String: Int]? { get }
-            {{- else }}
-    var {{ $fieldname }}: [String: String]? { get }
-            {{- end }}
-        {{- else if eq $property.AdditionalProperties.Type "integer"}}
-    var {{ $fieldname }}: [String: Int]? { get }
-        {{- else if eq $property.AdditionalProperties.Type "number"}}
-    var ? Double]? { get }
-        {{- else if eq $property.AdditionalProperties.Type "boolean"}}
-    var {{ $fieldname }}: [String: Bool]? { get }
-        {{- else }}
-    var {{ $fieldname }}: [String: {{$property.AdditionalProperties.Ref | cleanRef}}]? { get }
-        {{- end}}
-    {{- else }}
-    var {{ $fieldname }}: {{ $property.Ref | cleanRef }}? { get }
-    {{- end }}
-    {{- end }}
+	{{ access }} var description: String {
+		return "ApiResponseError(StatusCode=\(statusCode ?? 0), Message='\(message)', GrpcStatusCode=\(grpcStatusCode.rawValue))"
+	}
 }
 
-struct {{ $classname }}: {{ $classname }}Protocol {
-    {{- range $propname, $property := $definition.Properties }}
-    {{- $fieldname := $propname }}
-    {{- $attrDataName := $propname | camelToSnake }}
-    {{- if eq $fieldname "default" }}{{ $fieldname = "default_" }}{{ end }}
-
-    {{- if eq $property.Type "integer" }}
-    public var {{ $fieldname }}: Int
-    {{- else if eq $property.Type "number" }}
-    public var {{ $fieldname }}: Double
-    {{- else if eq $property.Type "boolean" }}
-    public var {{ $fieldname }}: Bool?
-    {{- else if eq $property.Type "string" }}
-    public var {{ $fieldname }}: String
-    {{- else if eq $property.Type "array" }}
-        {{- if eq $property.Items.Type "string" }}
-    public var {{ $fieldname }}:[String]
-        {{- else if eq $property.Items.Type "integer" }}
-    public var {{ $fieldname }}: [Int]
-        {{- else if eq $property.Items.Type "number" }}
-    public var {{ $fieldname }}: [Double]
-        {{- else if eq $property.Items.Type "boolean" }}
-    public var {{ $fieldname }}: [Bool]
-        {{- else}}
-    public var {{ $fieldname }}: [{{ $property.Items.Ref | cleanRef }}]? = []
-        {{- end }}
-    {{- else if eq $property.Type "object"}}
-        {{- if eq $property.AdditionalProperties.Type "string"}}
-            {{- if eq $property.AdditionalProperties.Format "int64" }}
-    public var {{ $fieldname }}: [String: Int]? = [:]
-    {{- else }}
-    public var {{ $fieldname }}: [String: String]? = [:]
-    {{- end }}
-    {{- else if eq $property.AdditionalProperties.Type "integer"}}
-    public var {{ $fieldname }}: [String: Int]? = [:]
-    {{- else if eq $property.AdditionalProperties.Type "number"}}
-    public var {{ $fieldname }}: [String: Double]? = [:]
-    {{- else if eq $property.AdditionalProperties.Type "boolean"}}
-    public var {{ $fieldname }}: [String: Bool]? = [:]
-    {{- else}}
-    public var {{ $fieldname }}: [String: {{$property.AdditionalProperties.Ref | cleanRef}}]? = [:]
-    {{- end}}
-    {{- else if isRefToEnum (cleanRef $property.Ref) }}
-    public var {{ $property.Ref | cleanRef }} {{ $fieldname }}
-    {{- else }}
-    public var {{ $fieldname }}: {{ $property.Ref | cleanRef }}?
-    {{- end }}
-    {{- end }}
+/// The error of a request the server rate limited with a 429 status, unless the operation documents its own.
+// Unchecked as its superclass, retryAfter is only set before the error is thrown.
+{{ access }}final class RateLimitedError: ApiResponseError, @unchecked Sendable {
+    /// How long the server asked to wait before retrying, from its Retry-After header, nil without one.
+    {{ access }}var retryAfter: TimeInterval?
 
-    private enum CodingKeys: String, CodingKey {
-        {{- range $fieldname, $property := $definition.Properties }}
-        {{- $propname := $fieldname }}
-        {{- if eq $fieldname "default" }}{{ $fieldname = "default_" }}{{ end }}
-        {{- if eq $propname "refreshToken" }}{{ $propname = "refresh_token" }}{{ end }}
-        case {{ $fieldname }} = "{{ $propname }}"
-        {{- end }}
-    }
-    
-    init(
-        {{- $first := true -}}
-        {{- range $propname, $property := $definition.Properties }}
-        {{- if eq $propname "default" }}{{ $propname = "default_" }}{{ end }}
-        {{- if $first }}{{- $first = false }}{{- else }}, {{- end }}
-        {{- $fieldname := $propname }}
-        {{- $attrDataName := $propname | camelToSnake }}
-        {{- if eq $property.Type "integer" }}
-        {{ $fieldname }}: Int
-        {{- else if eq $property.Type "number" }}
-        {{ $fieldname }}: Double
-        {{- else if eq $property.Type "boolean" }}
-        {{ $fieldname }}: Bool
-        {{- else if eq $property.Type "string" }}
-        {{ $fieldname }}: String
-        {{- else if eq $property.Type "array" }}
-            {{- if eq $property.Items.Type "string" }}
-        {{ $fieldname }}:[String]
-            {{- else if eq $property.Items.Type "integer" }}
-        {{ $fieldname }}: [Int]
-            {{- else if eq $property.Items.Type "number" }}
-        {{ $fieldname }}: [Double]
-            {{- else if eq $property.Items.Type "boolean" }}
-        {{ $fieldname }}: [Bool]
-            {{- else}}
-        {{ $fieldname }}: [{{ $property.Items.Ref | cleanRef }}] = []
-            {{- end }}
-        {{- else if eq $property.Type "object"}}
-            {{- if eq $property.AdditionalProperties.Type "string"}}
-                {{- if eq $property.AdditionalProperties.Format "int64" }}
-        {{ $fieldname }}: [String: Int] = [:]
-        {{- else }}
-        {{ $fieldname }}: [String: String] = [:]
-        {{- end }}
-        {{- else if eq $property.AdditionalProperties.Type "integer"}}
-        {{ $fieldname }}: [String: Int] = [:]
-        {{- else if eq $property.AdditionalProperties.Type "number"}}
-        {{ $fieldname }}: [String: Double] = [:]
-        {{- else if eq $property.AdditionalProperties.Type "boolean"}}
-        {{ $fieldname }}: [String: Bool] = [:]
-        {{- else}}
-        {{ $fieldname }}: [String: {{$property.AdditionalProperties.Ref | cleanRef}}] = [:]
-        {{- end}}
-        {{- else if isRefToEnum (cleanRef $property.Ref) }}
-        {{ $property.Ref | cleanRef }} {{ $fieldname }}
-        {{- else }}
-        {{ $fieldname }}: {{ $property.Ref | cleanRef }}
-        {{- end }}
-        {{- end }}
-    ) {
-        {{- range $fieldname, $property := $definition.Properties }}
-        {{- if eq $fieldname "default" }}{{ $fieldname = "default_" }}{{ end }}
-        self.{{ $fieldname }} = {{ $fieldname }}
-        {{- end }}
+    /// Parses a Retry-After header of seconds or of an HTTP date into the seconds to wait, nil for values which
+    /// aren't finite such as "inf" or "nan".
+    static func retryAfter(_ header: String?) -> TimeInterval? {
+        guard let header = header?.trimmingCharacters(in: .whitespaces) else {
+            return nil
+        }
+        if let seconds = TimeInterval(header) {
+            return seconds.isFinite ? max(0, seconds) : nil
+        }
+        let formatter = DateFormatter()
+        formatter.locale = Locale(identifier: "en_US_POSIX")
+        formatter.timeZone = TimeZone(identifier: "GMT")
+        formatter.dateFormat = "EEE, dd MMM yyyy HH:mm:ss zzz"
+        return formatter.date(from: header).map { max(0, $0.timeIntervalSinceNow) }
     }
+}
 
-    var debugDescription: String {
-        return "{{- range $fieldname, $property := $definition.Properties }}{{- if eq $fieldname "default" }}{{ $fieldname | snakeToCamel }}: \({{ $fieldname | snakeToCamel }}_) {{- else }}{{ $fieldname | snakeToCamel }}: \({{ $fieldname | snakeToCamel }}) {{- end }}{{- end }}"
-    }
+/// The status codes of gRPC, which the server responds with in the code of its errors.
+{{ access }}enum GrpcStatusCode: Int, Codable, Sendable {
+    /// Not an error, the code of errors without a gRPC status.
+    case ok = 0
+    /// The operation was cancelled, typically by the caller.
+    case cancelled = 1
+    /// An unknown error, or one with a code this client doesn't know.
+    case unknown = 2
+    /// The client specified an invalid argument.
+    case invalidArgument = 3
+    /// The deadline expired before the operation could complete.
+    case deadlineExceeded = 4
+    /// A requested entity wasn't found.
+    case notFound = 5
+    /// The entity the client attempted to create already exists.
+    case alreadyExists = 6
+    /// The caller doesn't have permission to execute the operation.
+    case permissionDenied = 7
+    /// A resource has been exhausted, such as a quota.
+    case resourceExhausted = 8
+    /// The system isn't in a state required for the operation.
+    case failedPrecondition = 9
+    /// The operation was aborted, typically due to a concurrency issue.
+    case aborted = 10
+    /// The operation was attempted past the valid range.
+    case outOfRange = 11
+    /// The operation isn't implemented or supported.
+    case unimplemented = 12
+    /// An internal error of the server.
+    case internalError = 13
+    /// The service is currently unavailable, retrying may succeed.
+    case unavailable = 14
+    /// Unrecoverable data loss or corruption.
+    case dataLoss = 15
+    /// The request doesn't have valid authentication credentials.
+    case unauthenticated = 16
 }
-{{- end }}
 
+struct EmptyResponse: Codable, Sendable {
+    init() {}
+}
 
-{{- end }}
+/// The strategies of the JSON coders the client encodes bodies and decodes responses with. Responses the
+/// HttpAdapter decodes itself use the coders of the adapter.
+// Unchecked since the custom strategies hold closures which older SDKs don't declare Sendable.
+{{ access }}struct JSONCoders: @unchecked Sendable {
+    /// The coders of the clients created without their own, set it at launch before any request is sent to tune
+    /// coding globally.
+    // Unsafe since it's only meant to be set before it's read from other tasks. nonisolated(unsafe) needs Swift 5.10,
+    // older compilers don't check the isolation of static vars.
+    #if compiler(>=5.10)
+    nonisolated(unsafe) {{ access }}static var shared = JSONCoders()
+    #else
+    {{ access }}static var shared = JSONCoders()
+    #endif
 
-/// The low level client for the {{ .Namespace }} API.
-class ApiClient
-{
-    public let httpAdapter: HttpAdapterProtocol
-    public let timeout: Int
+    {{ access }}var dateEncodingStrategy: JSONEncoder.DateEncodingStrategy = .deferredToDate
+    {{ access }}var dataEncodingStrategy: JSONEncoder.DataEncodingStrategy = .base64
+    /// The models name their coding keys, so they're only affected by custom key strategies.
+    {{ access }}var keyEncodingStrategy: JSONEncoder.KeyEncodingStrategy = .useDefaultKeys
+    {{ access }}var dateDecodingStrategy: JSONDecoder.DateDecodingStrategy = .deferredToDate
+    {{ access }}var dataDecodingStrategy: JSONDecoder.DataDecodingStrategy = .base64
+    /// The models name their coding keys, so they're only affected by custom key strategies.
+    {{ access }}var keyDecodingStrategy: JSONDecoder.KeyDecodingStrategy = .useDefaultKeys
 
-    private(set) var baseUri: URL
+    {{ access }}init() {}
 
-    public init(baseUri: URL, httpAdapter: HttpAdapterProtocol, timeout: Int = 10)
-    {
-        self.baseUri = baseUri
-        self.httpAdapter = httpAdapter
-        self.timeout = timeout
+    /// Creates an encoder with the encoding strategies.
+    {{ access }}func makeEncoder() -> JSONEncoder {
+        let encoder = JSONEncoder()
+        encoder.dateEncodingStrategy = dateEncodingStrategy
+        encoder.dataEncodingStrategy = dataEncodingStrategy
+        encoder.keyEncodingStrategy = keyEncodingStrategy
+        return encoder
     }
 
-    {{- range $url, $path := .Paths }}
-    {{- range $method, $operation := $path}}
+    /// Creates a decoder with the decoding strategies.
+    {{ access }}func makeDecoder() -> JSONDecoder {
+        let decoder = JSONDecoder()
+        decoder.dateDecodingStrategy = dateDecodingStrategy
+        decoder.dataDecodingStrategy = dataDecodingStrategy
+        decoder.keyDecodingStrategy = keyDecodingStrategy
+        return decoder
+    }
+}
+{{- if $.Options.TypedCursors }}
 
-    /// {{ $operation.Summary | stripNewlines }}
-    public func {{ $operation.OperationId | stripOperationPrefix | snakeToPascal }}(
+/// An opaque cursor of the pages of Page, typed so the cursor of one listing can't be passed to another.
+{{ access }}struct Cursor<Page>: RawRepresentable, Hashable, Codable, Sendable {
+    {{ access }}let rawValue: String
 
-    {{- $isPreviousParam := false}}
+    {{ access }}init(rawValue: String) {
+        self.rawValue = rawValue
+    }
+}
 
-    {{- if $operation.Security }}
-        {{- range $idx, $security := $operation.Security}}
-            {{- range $key, $value := $security}}
-                {{- if or (eq $key "BasicAuth") (eq $key "HttpKeyAuth") }}
-        basicAuthUsername: String,
-        basicAuthPassword: String
-                    {{- $isPreviousParam = true}}
-                {{- else if (eq $key "BearerJwt") }}
-                    {{- $isPreviousParam = true}}
-        bearerToken: String,
-                {{- end }}
-            {{- end }}
-        {{- end }}
-    {{- else }}
-        {{- $isPreviousParam = true}}
-        bearerToken: String
-    {{- end }}
+{{- end }}
+{{- if $.Options.CircuitBreaker }}
 
-    {{- range $parameter := $operation.Parameters }}
+/// The error of the requests a CircuitBreakerAdapter fails fast while its circuit is open.
+{{ access }}struct CircuitOpenError: Error {
+    /// When the circuit lets a request through again to probe the server.
+    {{ access }}let retryAt: Date
+}
 
-    {{- if eq $isPreviousParam true}},{{- end}}
-    {{- if eq $parameter.In "path" }}
-        {{ $parameter.Name }}: {{ $parameter.Type | camelToPascal }}{{- if not $parameter.Required }}?{{- end }}
-    {{- else if eq $parameter.In "body" }}
-        {{- if eq $parameter.Schema.Type "string" }}
-        string{{- if not $parameter.Required }}?{{- end }} {{ $parameter.Name }}
-        {{- else }}
-        {{ $parameter.Name }}: {{ $parameter.Schema.Ref | cleanRef }}{{- if not $parameter.Required }}?{{- end }}
-        {{- end }}
-    {{- else if eq $parameter.Type "array"}}
-        {{ $parameter.Name | snakeToCamel }}: [{{ $parameter.Items.Type | camelToPascal }}]
-    {{- else if eq $parameter.Type "object"}}
-        {{- if eq $parameter.AdditionalProperties.Type "string"}}
-    {{ $parameter.Name }}: [String : String]
-        {{- else if eq $parameter.Items.Type "integer"}}
-    {{ $parameter.Name }}: [String : Int]
-        {{- else if eq $parameter.Items.Type "boolean"}}
-    {{ $parameter.Name }}: [String : Int]
-        {{- else}}
-    {{ $parameter.Name }}: [String : {{ $parameter.Items.Type }}] 
-        {{- end}}
-    {{- else if eq $parameter.Type "integer" }}
-        {{ $parameter.Name }}: Int?
-    {{- else if eq $parameter.Type "boolean" }}
-        {{ $parameter.Name }}: Bool?
-    {{- else if eq $parameter.Type "string" }}
-        {{ $parameter.Name }}: String?
-    {{- else }}
-        {{ $parameter.Type }} {{ $parameter.Name }}
-    {{- end }}
-    {{- $isPreviousParam = true}}
-{{- end }}) async throws -> {{- if $operation.Responses.Ok.Schema.Ref }} {{ $operation.Responses.Ok.Schema.Ref | cleanRef }}{{- else }} Void {{- end }} {
-        {{- range $parameter := $operation.Parameters }}
-        {{- if $parameter.Required }}
-        {{- end }}
-    {{- end }}
+/// Wraps an adapter to fail requests fast during server outages. The circuit opens after failureThreshold
+/// consecutive transport failures, failing requests with a CircuitOpenError for the cooldown, then lets a single
+/// request through to probe the server: the circuit closes when it succeeds and opens again when it fails.
+/// Error responses count as successes, the server was reached.
+// Unchecked since the state is guarded by the lock and the adapter isn't required to be Sendable. Internal like the
+// HttpAdapterProtocol it conforms to and wraps.
+final class CircuitBreakerAdapter: HttpAdapterProtocol, @unchecked Sendable {
+    private enum State {
+        case closed(failures: Int)
+        case open(until: Date)
+        /// A request is probing the server.
+        case halfOpen
+    }
 
-        var urlComponents = URLComponents()
-        urlComponents.scheme = baseUri.scheme
-        urlComponents.host = baseUri.host
-        urlComponents.path = "{{- $url }}"
+    private var adapter: HttpAdapterProtocol
+    {{ access }}let failureThreshold: Int
+    /// How long the circuit stays open, in seconds.
+    {{ access }}let cooldown: TimeInterval
+    private let lock = NSLock()
+    private var state = State.closed(failures: 0)
 
-        {{- range $parameter := $operation.Parameters }}
-        {{- $camelToSnake := $parameter.Name | camelToSnake }}
-        {{- if eq $parameter.In "path" }}
-        urlComponents.path.append({{ $parameter.Name }}.addingPercentEncoding(withAllowedCharacters: .urlPathAllowed)!)
-        {{- end }}
-    {{- end }}
+    init(adapter: HttpAdapterProtocol, failureThreshold: Int = 5, cooldown: TimeInterval = 30) {
+        self.adapter = adapter
+        self.failureThreshold = failureThreshold
+        self.cooldown = cooldown
+    }
 
-        var queryItems = [URLQueryItem]()
-        {{- range $parameter := $operation.Parameters }}
-        {{- $camelToSnake := $parameter.Name | camelToSnake }}
-        {{- if eq $parameter.In "query"}}
-            {{- if eq $parameter.Type "integer" }}
-        if let {{ $parameter.Name }} {
-            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: "\({{ $parameter.Name }})"))
+    {{ access }}var logger: Logger? {
+        get { adapter.logger }
+        set { adapter.logger = newValue }
+    }
+
+    /// Whether requests fail fast, the circuit is open or a request is probing the server.
+    {{ access }}var isOpen: Bool {
+        lock.lock()
+        defer {
+            lock.unlock()
         }
-            {{- else if eq $parameter.Type "string" }}
-        if let {{ $parameter.Name }} {
-            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: {{ $parameter.Name }}.lowercased()))
+        if case .closed = state {
+            return false
         }
-            {{- else if eq $parameter.Type "boolean" }}
-        if let {{ $parameter.Name }} {
-            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: "\({{ $parameter.Name }})".addingPercentEncoding(withAllowedCharacters: .urlQueryAllowed)))
+        return true
+    }
+
+    {{ access }}func sendAsync<T: Codable>(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) async throws -> T {
+        try acquire()
+        do {
+            let response: T = try await adapter.sendAsync(method: method, uri: uri, headers: headers, body: body, timeoutSec: timeoutSec)
+            record(nil)
+            return response
+        } catch {
+            record(error)
+            throw error
         }
-            {{- else if eq $parameter.Type "array" }}
-        for param in {{ $parameter.Name | snakeToCamel }} {
-            {{- if eq $parameter.Items.Type "string" }}
-            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: param))
-                {{- else }}
-            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: param.description))
-                {{- end }}
+    }
+
+    {{ access }}func sendRawAsync(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) async throws -> (Data, HTTPURLResponse) {
+        try acquire()
+        do {
+            let response = try await adapter.sendRawAsync(method: method, uri: uri, headers: headers, body: body, timeoutSec: timeoutSec)
+            record(nil)
+            return response
+        } catch {
+            record(error)
+            throw error
         }
-            {{- else }}
-        {{ $parameter }}
-            {{- end }}
-        {{- end }}
-    {{- end }}
-        urlComponents.queryItems = queryItems
-        guard let url = urlComponents.url else {
-            throw SatoriError.invalidURL
+    }
+
+    {{ access }}func streamAsync(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) -> AsyncThrowingStream<Data, Error> {
+        return AsyncThrowingStream { continuation in
+            let task = Task { [self] in
+                do {
+                    try acquire()
+                } catch {
+                    continuation.finish(throwing: error)
+                    return
+                }
+                do {
+                    for try await chunk in adapter.streamAsync(method: method, uri: uri, headers: headers, body: body, timeoutSec: timeoutSec) {
+                        continuation.yield(chunk)
+                    }
+                    record(nil)
+                    continuation.finish()
+                } catch {
+                    record(error)
+                    continuation.finish(throwing: error)
+                }
+            }
+            continuation.onTermination = { _ in
+                task.cancel()
+            }
         }
+    }
 
-        let method = "{{- $method | uppercase }}"
-        var headers: [String: String] = [:]
+    /// Lets a request through, or throws a CircuitOpenError failing it fast.
+    private func acquire() throws {
+        lock.lock()
+        defer {
+            lock.unlock()
+        }
+        switch state {
+        case .closed:
+            return
+        case .open(let until) where Date() >= until:
+            state = .halfOpen
+        case .open(let until):
+            throw CircuitOpenError(retryAt: until)
+        case .halfOpen:
+            throw CircuitOpenError(retryAt: Date().addingTimeInterval(cooldown))
+        }
+    }
 
-        {{- if $operation.Security }}
-            {{- range $idx, $security := $operation.Security }}
-                {{- range $key, $value := $security }}
-                    {{- if or (eq $key "BasicAuth") (eq $key "HttpKeyAuth")}}
-        if !basicAuthUsername.isEmpty {
-            if let credentials = "\(basicAuthUsername):\(basicAuthPassword)".data(using: .utf8)?.base64EncodedString() {
-                var header = "Basic \(credentials)"
-                headers["Authorization"] = header
+    /// Records how a request let through ended, error is nil when it succeeded.
+    private func record(_ error: Error?) {
+        lock.lock()
+        defer {
+            lock.unlock()
+        }
+        if error is CancellationError || (error as? URLError)?.code == .cancelled {
+            // A cancelled probe tells nothing, the next request probes again.
+            if case .halfOpen = state {
+                state = .open(until: Date())
             }
+            return
         }
-                    {{- else if (eq $key "BearerJwt") }}
-        if !bearerToken.isEmpty {
-            var header = "Bearer \(bearerToken)"
-            headers["Authorization"] = header
+        // Only URLErrors didn't reach the server.
+        guard let error = error as? URLError else {
+            if case .halfOpen = state {
+                adapter.logger?.info("Circuit closed")
+            }
+            state = .closed(failures: 0)
+            return
         }
-                    {{- end }}
-                {{- end }}
-            {{- end }}
-        {{- else }}
-        var header = "Bearer \(bearerToken)"
-        headers["Authorization"] = header
-        {{- end }}
 
-        var content: Data? = nil
-        {{- range $parameter := $operation.Parameters }}
-        {{- if eq $parameter.In "body" }}
-        let encoder = JSONEncoder()
-        do {
-            content = try encoder.encode({{ $parameter.Name }})
-        } catch {
-            print("Error encoding body: \(error)")
+        switch state {
+        case .closed(let failures) where failures + 1 < failureThreshold:
+            state = .closed(failures: failures + 1)
+        case .closed, .halfOpen:
+            adapter.logger?.warning("Circuit opened for \(cooldown)s after \(error.localizedDescription)")
+            state = .open(until: Date().addingTimeInterval(cooldown))
+        case .open:
+            break
         }
-        {{- end }}
-        {{- end }}
-
-        {{- if $operation.Responses.Ok.Schema.Ref }}
-        var response: {{ $operation.Responses.Ok.Schema.Ref | cleanRef }} = try await httpAdapter.sendAsync(method: method, uri: url, headers: headers, body: content, timeoutSec: timeout)
-        return response
-        {{- else }}
-        let _: EmptyResponse = try await httpAdapter.sendAsync(method: method, uri: url, headers: headers, body: content, timeoutSec: timeout)
-        {{- end }}
     }
-    {{- end }}
+}
 {{- end }}
+{{- if $.Options.Outbox }}
+
+/// A write request the Outbox queued while offline, replayed as it was sent.
+{{ access }}struct OutboxRequest: Codable, Sendable, Identifiable {
+    {{ access }}let id: UUID
+    {{ access }}let method: String
+    {{ access }}let url: URL
+    {{ access }}let headers: [String: String]
+    {{ access }}let body: Data?
+    /// When the request failed and was queued.
+    {{ access }}let queuedAt: Date
+
+    {{ access }}init(id: UUID = UUID(), method: String, url: URL, headers: [String: String], body: Data?, queuedAt: Date = Date()) {
+        self.id = id
+        self.method = method
+        self.url = url
+        self.headers = headers
+        self.body = body
+        self.queuedAt = queuedAt
+    }
 }
-`
 
-func convertRefToClassName(input string) (className string) {
-	cleanRef := strings.TrimPrefix(input, "#/definitions/")
-	className = strings.Title(cleanRef)
-	return
+/// Persists the queued requests of an Outbox, headers included, across launches.
+{{ access }}protocol OutboxStore: Sendable {
+    /// Loads the queued requests, in order.
+    func load() throws -> [OutboxRequest]
+    /// Replaces the queued requests.
+    func save(_ requests: [OutboxRequest]) throws
 }
 
-// camelToSnake converts a camel or Pascal case string into snake case.
-func camelToSnake(input string) (output string) {
-	for k, v := range input {
-		if unicode.IsUpper(v) {
-			formatString := "%c"
+/// Persists the queued requests of an Outbox in a JSON file.
+{{ access }}struct FileOutboxStore: OutboxStore {
+    {{ access }}let url: URL
 
-			if k != 0 {
-				formatString = "_" + formatString
-			}
+    {{ access }}init(url: URL) {
+        self.url = url
+    }
 
-			output += fmt.Sprintf(formatString, unicode.ToLower(v))
-		} else {
-			output += string(v)
-		}
-	}
+    {{ access }}func load() throws -> [OutboxRequest] {
+        guard FileManager.default.fileExists(atPath: url.path) else {
+            return []
+        }
+        return try JSONDecoder().decode([OutboxRequest].self, from: Data(contentsOf: url))
+    }
 
-	return
+    {{ access }}func save(_ requests: [OutboxRequest]) throws {
+        try JSONEncoder().encode(requests).write(to: url, options: .atomic)
+    }
 }
 
-func snakeToCamel(input string) (snakeToCamel string) {
-	isToUpper := false
-	for k, v := range input {
-		if k == 0 {
-			snakeToCamel = strings.ToLower(string(input[0]))
-		} else {
-			if isToUpper {
-				snakeToCamel += strings.ToUpper(string(v))
-				isToUpper = false
-			} else {
-				if v == '_' {
-					isToUpper = true
-				} else {
-					snakeToCamel += string(v)
-				}
-			}
-		}
+/// What replaying does with a queued request the server rejected.
+{{ access }}enum OutboxConflictResolution: Sendable {
+    /// Drops the request and replays the next one.
+    case drop
+    /// Keeps the request queued and stops replaying, until the next replay.
+    case keep
+    /// Replaces the request, such as with one merging the changes of the server, and replays it right away.
+    case replace(OutboxRequest)
+}
 
-	}
+/// Thrown instead of the error of a write request which failed while offline and was queued to be replayed. It isn't
+/// transient, so a RetryPolicy doesn't retry it: a write failing while offline is queued on its first attempt.
+{{ access }}struct OutboxQueuedError: Error {
+    {{ access }}let request: OutboxRequest
+    /// The error the request failed with.
+    {{ access }}let underlyingError: Error
+}
+
+/// Queues the write requests failing while offline in a persisted store, and replays them in order once the client
+/// is back online. Requests the server rejects are resolved by the conflict hook, dropped by default.
+{{ access }}actor Outbox {
+    private let store: OutboxStore
+    private let onConflict: @Sendable (OutboxRequest, ApiResponseError) async -> OutboxConflictResolution
+    private var requests: [OutboxRequest]
+    private var replaying = false
+
+    /// Loads the requests queued by previous launches from the store.
+    {{ access }}init(store: OutboxStore, onConflict: @escaping @Sendable (OutboxRequest, ApiResponseError) async -> OutboxConflictResolution = { _, _ in .drop }) throws {
+        self.store = store
+        self.onConflict = onConflict
+        self.requests = try store.load()
+    }
+
+    /// The queued requests, in the order they are replayed.
+    {{ access }}var pending: [OutboxRequest] {
+        return requests
+    }
+
+    /// Whether a request failed since the client is offline, rather than being refused.
+    {{ access }}static func isOffline(_ error: Error) -> Bool {
+        guard let error = error as? URLError else {
+            return false
+        }
+        switch error.code {
+        case .notConnectedToInternet, .networkConnectionLost, .cannotConnectToHost, .cannotFindHost, .dnsLookupFailed, .timedOut, .dataNotAllowed, .internationalRoamingOff:
+            return true
+        default:
+            return false
+        }
+    }
+
+    /// Queues a request after the ones queued already.
+    {{ access }}func enqueue(_ request: OutboxRequest) throws {
+        requests.append(request)
+        try store.save(requests)
+    }
+
+    /// Replays the queued requests in order with send, stopping at the first one failing since the client is still
+    /// offline or the server is unavailable. Does nothing while another replay is running.
+    {{ access }}func replay(_ send: @Sendable (OutboxRequest) async throws -> (Data, HTTPURLResponse)) async throws {
+        guard !replaying else {
+            return
+        }
+        replaying = true
+        defer {
+            replaying = false
+        }
+
+        while let request = requests.first {
+            let data: Data
+            let response: HTTPURLResponse
+            do {
+                (data, response) = try await send(request)
+            } catch let error where Outbox.isOffline(error) {
+                return
+            }
+
+            switch response.statusCode {
+            case 200...299:
+                requests.removeFirst()
+            case 408, 429, 500...599:
+                return
+            default:
+                let error = ApiResponseError.decode(ApiResponseError.self, statusCode: response.statusCode, data: data, requestId: request.headers["X-Request-Id"], method: request.method, url: request.url, response: response)
+                switch await onConflict(request, error) {
+                case .drop:
+                    requests.removeFirst()
+                case .keep:
+                    return
+                case .replace(let replacement):
+                    requests[0] = replacement
+                }
+            }
+            try store.save(requests)
+        }
+    }
+}
+{{- end }}
+
+{{- if $.Options.Retries }}
+
+/// How an operation retries requests failing with a transient error, backing off exponentially between attempts.
+{{ access }}struct RetryPolicy: Sendable {
+    /// The number of attempts made after the first one.
+    {{ access }}var maxRetries: Int
+    /// The delay in milliseconds before the first retry, doubled for every retry after it up to maxDelayMs. Every
+    /// delay is jittered down by up to half, so the clients a failure hit together don't retry together.
+    {{ access }}var baseDelayMs: Int
+    /// The longest delay in milliseconds the backoff grows to.
+    {{ access }}var maxDelayMs: Int
+    /// The longest Retry-After in milliseconds waited for before retrying a rate limited request, requests the
+    /// server asks to wait longer fail right away with a RateLimitedError.
+    {{ access }}var maxRetryAfterMs: Int
+
+    {{ access }}init(maxRetries: Int, baseDelayMs: Int, maxDelayMs: Int = 30_000, maxRetryAfterMs: Int = 30_000) {
+        self.maxRetries = maxRetries
+        self.baseDelayMs = baseDelayMs
+        self.maxDelayMs = maxDelayMs
+        self.maxRetryAfterMs = maxRetryAfterMs
+    }
+
+    /// The policy of clients created without their own, used by the idempotent operations which don't override it.
+    {{ access }}static let standard = RetryPolicy(maxRetries: 3, baseDelayMs: 500)
+
+    /// Runs request until it doesn't fail with a transient error or the retries run out.
+    func run<T>(_ request: () async throws -> T) async throws -> T {
+        try await attempt(request, failure: { _ in nil })
+    }
+
+    /// Runs request until it neither fails nor responds with a transient error, or the retries run out.
+    func run(_ request: () async throws -> (Data, HTTPURLResponse)) async throws -> (Data, HTTPURLResponse) {
+        try await attempt(request, failure: { data, response in
+            if (200...299).contains(response.statusCode) {
+                return nil
+            }
+            return ApiResponseError.decode(ApiResponseError.self, statusCode: response.statusCode, data: data, response: response)
+        })
+    }
+
+    /// Runs request, retrying while it fails or its result is a failure with a transient error.
+    private func attempt<T>(_ request: () async throws -> T, failure: (T) -> Error?) async throws -> T {
+        var retries = 0
+        while true {
+            let delayMs: Int
+            do {
+                let result = try await request()
+                guard retries < maxRetries, let error = failure(result), let delay = retryDelayMs(after: error, retries: retries) else {
+                    return result
+                }
+                delayMs = delay
+            } catch {
+                guard retries < maxRetries, let delay = retryDelayMs(after: error, retries: retries) else {
+                    throw error
+                }
+                delayMs = delay
+            }
+            try await Task.sleep(nanoseconds: UInt64(delayMs) * 1_000_000)
+            retries += 1
+        }
+    }
+
+    /// The delay before retrying after an error, the Retry-After of rate limited requests or else the jittered
+    /// exponential backoff. Nil when the error isn't transient or the server asked to wait longer than
+    /// maxRetryAfterMs.
+    private func retryDelayMs(after error: Error, retries: Int) -> Int? {
+        guard RetryPolicy.isTransient(error) else {
+            return nil
+        }
+        if let error = error as? RateLimitedError, let retryAfter = error.retryAfter {
+            // Compared before the conversion, which traps for delays out of the range of Int.
+            let delayMs = retryAfter * 1000
+            return delayMs <= Double(maxRetryAfterMs) ? Int(delayMs) : nil
+        }
+        // The backoff doubles past the range of Int after enough retries, which is reported rather than trapping.
+        let (backoffMs, overflow) = baseDelayMs.multipliedReportingOverflow(by: 1 << min(retries, Int.bitWidth - 2))
+        let delayMs = max(0, overflow ? maxDelayMs : min(backoffMs, maxDelayMs))
+        return Int.random(in: delayMs / 2...delayMs)
+    }
+
+    private static func isTransient(statusCode: Int) -> Bool {
+        return [408, 429, 502, 503, 504].contains(statusCode)
+    }
+
+    private static func isTransient(_ error: Error) -> Bool {
+        if let error = error as? ApiResponseError {
+            return isTransient(statusCode: error.statusCode ?? 0)
+        }
+        if let error = error as? URLError {
+            return [.timedOut, .networkConnectionLost, .cannotConnectToHost, .notConnectedToInternet, .dnsLookupFailed].contains(error.code)
+        }
+        return false
+    }
+}
+{{- end }}
+
+/// The versions the client was generated from.
+{{ access }}enum ClientVersion {
+    /// The version of the generator.
+    {{ access }}static let generator = {{ generatorVersion | swiftQuote }}
+    /// The version of the API schema.
+    {{ access }}static let schema = {{ $.Info.Version | swiftQuote }}
+    /// The User-Agent header sent with every request which doesn't set its own.
+    {{ access }}static let userAgent = {{ printf "nakama-swift/%s" $.Options.ClientVersion | swiftQuote }}
+}
+
+/// Compresses request bodies into gzip members.
+enum Gzip {
+    /// Compresses data, or returns nil on platforms without the deflate of Foundation.
+    static func compress(_ data: Data) -> Data? {
+        #if canImport(Darwin)
+        guard #available(iOS 13.0, macOS 10.15, tvOS 13.0, watchOS 6.0, *),
+              let deflated = try? (data as NSData).compressed(using: .zlib) as Data else {
+            return nil
+        }
+        // The zlib algorithm of Foundation writes raw deflate, which a gzip member wraps with a header and trailer.
+        var member = Data([0x1f, 0x8b, 0x08, 0, 0, 0, 0, 0, 0, 0xff])
+        member.append(deflated)
+        for value in [crc32(data), UInt32(truncatingIfNeeded: data.count)] {
+            withUnsafeBytes(of: value.littleEndian) { member.append(contentsOf: $0) }
+        }
+        return member
+        #else
+        return nil
+        #endif
+    }
+
+    private static func crc32(_ data: Data) -> UInt32 {
+        var crc: UInt32 = 0xffffffff
+        for byte in data {
+            crc ^= UInt32(byte)
+            for _ in 0..<8 {
+                crc = (crc >> 1) ^ (0xedb88320 & (0 &- (crc & 1)))
+            }
+        }
+        return ~crc
+    }
+}
+
+/// Receives the messages the client logs, such as the requests which failed and the responses it couldn't decode.
+{{ access }}protocol LoggerProtocol: Sendable {
+    func debug(_ message: String)
+    func info(_ message: String)
+    func warn(_ message: String)
+    func error(_ message: String)
+}
+
+/// A logger dropping every message, the logger of clients created without one.
+{{ access }}struct NoopLogger: LoggerProtocol {
+    {{ access }}init() {}
+
+    {{ access }}func debug(_ message: String) {}
+    {{ access }}func info(_ message: String) {}
+    {{ access }}func warn(_ message: String) {}
+    {{ access }}func error(_ message: String) {}
+}
+
+/// Observes the requests of the client, such as to report the health of the API to analytics. Both methods do
+/// nothing unless implemented.
+{{ access }}protocol ClientMetricsListener: Sendable {
+    /// Called before a request is sent, path is the path template of its operation such as /v1/message/{id}.
+    func onRequestStart(method: String, path: String)
+    /// Called once a request ended, successfully or not.
+    func onRequestEnd(_ metrics: RequestMetrics)
+}
+
+{{ access }}extension ClientMetricsListener {
+    func onRequestStart(method: String, path: String) {}
+    func onRequestEnd(_ metrics: RequestMetrics) {}
+}
+
+/// The metrics of a request which ended.
+{{ access }}struct RequestMetrics: Sendable {
+    {{ access }}let method: String
+    /// The path template of the operation, or the path of the URL for the requests replayed by an outbox.
+    {{ access }}let path: String
+    /// The status of the response, nil when the request failed without one. Streams which completed report 200.
+    {{ access }}let statusCode: Int?
+    /// The seconds from sending the request to receiving the end of its response.
+    {{ access }}let duration: TimeInterval
+    {{ access }}let bytesSent: Int
+    {{ access }}let bytesReceived: Int
+}
+
+/// A request about to be sent, as request interceptors see and change it.
+{{ access }}struct HttpRequest: Sendable {
+    {{ access }}var method: String
+    {{ access }}var url: URL
+    {{ access }}var headers: [String: String]
+    {{ access }}var body: Data?
+
+    {{ access }}init(method: String, url: URL, headers: [String: String], body: Data?) {
+        self.method = method
+        self.url = url
+        self.headers = headers
+        self.body = body
+    }
+
+    /// The request as a curl command with the Authorization header redacted, to replay it while debugging.
+    {{ access }}var curlCommand: String {
+        func quote(_ value: String) -> String {
+            return "'" + value.replacingOccurrences(of: "'", with: "'\\''") + "'"
+        }
+        var command = "curl -X \(method)"
+        for (name, value) in headers.sorted(by: { $0.key < $1.key }) {
+            let value = name.caseInsensitiveCompare("Authorization") == .orderedSame ? "<redacted>" : value
+            command += " -H \(quote("\(name): \(value)"))"
+        }
+        if let body, !body.isEmpty {
+            command += " --data-binary \(quote(String(decoding: body, as: UTF8.self)))"
+        }
+        return command + " \(quote(url.absoluteString))"
+    }
+}
+
+/// Changes every request of a client before it is sent, such as to refresh the token of its Authorization header.
+{{ access }}protocol RequestInterceptor: Sendable {
+    func intercept(_ request: HttpRequest) async throws -> HttpRequest
+}
+
+/// Observes the response of every request of a client which isn't streamed, such as to log or measure it. Throwing
+/// fails the request with the error.
+{{ access }}protocol ResponseInterceptor: Sendable {
+    func intercept(_ response: HTTPURLResponse, data: Data, for request: HttpRequest) async throws
+}
+{{- if $.Options.TypedCursors }}
+{{- range paginatedModels }}
+
+extension {{ .Model }} {
+    /// The cursor of the next page, nil on the last page.
+    {{ access }}var nextPageCursor: Cursor<{{ .Model }}>? {
+        {{- if .Optional }}
+        guard let cursor = {{ .Cursor }}, !cursor.isEmpty else {
+            return nil
+        }
+        return Cursor(rawValue: cursor)
+        {{- else }}
+        return {{ .Cursor }}.isEmpty ? nil : Cursor(rawValue: {{ .Cursor }})
+        {{- end }}
+    }
+}
+{{- end }}
+{{- end }}
+{{- if or $.Options.SessionType (sessionRefresh) }}
+
+/// Decodes the tokens of sessions.
+enum JWT {
+    /// The claims of the payload of a JWT.
+    static func claims(of jwt: String) -> [String: Any]? {
+        let parts = jwt.split(separator: ".")
+        guard parts.count == 3 else {
+            return nil
+        }
+        var payload = parts[1].replacingOccurrences(of: "-", with: "+").replacingOccurrences(of: "_", with: "/")
+        while payload.count % 4 != 0 {
+            payload += "="
+        }
+        guard let data = Data(base64Encoded: payload) else {
+            return nil
+        }
+        return try? JSONSerialization.jsonObject(with: data) as? [String: Any]
+    }
+
+    /// The expiry of a JWT, read from the exp claim of its payload.
+    static func expiry(of jwt: String) -> Date? {
+        return (claims(of: jwt)?["exp"] as? NSNumber).map { Date(timeIntervalSince1970: $0.doubleValue) }
+    }
+}
+{{- end }}
+{{- with $.Options.SessionType }}
+
+/// A session decoded from the claims of its tokens, as the other Nakama SDKs expose it.
+{{ access }}struct {{ . }}: Sendable {
+    /// The token authorizing the requests of the session.
+    {{ access }}let token: String
+    /// The token the session is refreshed with.
+    {{ access }}let refreshToken: String
+    /// The id of the user owning the session, the uid claim or the iid claim of Satori identities.
+    {{ access }}let userId: String
+    /// The username of the user owning the session, empty when the token has none.
+    {{ access }}let username: String
+    /// The variables set on the session when it was authenticated.
+    {{ access }}let vars: [String: String]
+    /// When the token expires.
+    {{ access }}let expireTime: Date
+    /// When the refresh token expires, nil without a refresh token.
+    {{ access }}let refreshExpireTime: Date?
+
+    /// Decodes the claims of the tokens, failing for a token which isn't a JWT with an expiry.
+    {{ access }}init(token: String, refreshToken: String) throws {
+        guard let claims = JWT.claims(of: token), let exp = claims["exp"] as? NSNumber else {
+            throw DecodingError.dataCorrupted(DecodingError.Context(codingPath: [], debugDescription: "The session token isn't a JWT with an expiry"))
+        }
+        self.token = token
+        self.refreshToken = refreshToken
+        self.userId = claims["uid"] as? String ?? claims["iid"] as? String ?? ""
+        self.username = claims["usn"] as? String ?? ""
+        self.vars = claims["vrs"] as? [String: String] ?? [:]
+        self.expireTime = Date(timeIntervalSince1970: exp.doubleValue)
+        self.refreshExpireTime = JWT.expiry(of: refreshToken)
+    }
+
+    /// Whether the token has expired.
+    {{ access }}var isExpired: Bool {
+        return hasExpired(offset: Date())
+    }
+
+    /// Whether the refresh token has expired, or is missing.
+    {{ access }}var isRefreshExpired: Bool {
+        return hasRefreshExpired(offset: Date())
+    }
+
+    /// Whether the token has expired by a date.
+    {{ access }}func hasExpired(offset: Date) -> Bool {
+        return offset >= expireTime
+    }
+
+    /// Whether the refresh token has expired by a date, or is missing.
+    {{ access }}func hasRefreshExpired(offset: Date) -> Bool {
+        guard let refreshExpireTime else {
+            return true
+        }
+        return offset >= refreshExpireTime
+    }
+
+}
+{{- $sessionType := . }}
+{{- range sessionModels }}
+
+extension {{ $sessionType }} {
+    /// Decodes the session an authentication or refresh returned.
+    {{ access }}init(_ session: {{ .Type }}) throws {
+        {{- if or .TokenOptional .RefreshTokenOptional }}
+        try self.init(token: session.{{ .Token }}{{ if .TokenOptional }} ?? ""{{ end }}, refreshToken: session.{{ .RefreshToken }}{{ if .RefreshTokenOptional }} ?? ""{{ end }})
+        {{- else }}
+        try self.init(token: session.{{ .Token }}, refreshToken: session.{{ .RefreshToken }})
+        {{- end }}
+    }
+}
+{{- end }}
+{{- end }}
+{{- if sessionRefresh }}
+
+/// Supplies a fresh token to every request of a client, refreshing the session shortly before its token expires.
+/// Add it to the request interceptors of the client: it sets the bearer Authorization header of every request which
+/// doesn't authorize itself otherwise.
+{{ access }}actor SessionManager: RequestInterceptor {
+    /// The token of the session, refreshed before it expires.
+    {{ access }}private(set) var token: String
+    /// The refresh token of the session.
+    {{ access }}private(set) var refreshToken: String
+    /// How long before the token expires the session is refreshed.
+    {{ access }}let refreshMargin: TimeInterval
+
+    private let refresh: @Sendable (_ refreshToken: String) async throws -> (token: String, refreshToken: String)
+    private var refreshing: Task<(token: String, refreshToken: String), Error>?
+    /// Set while refreshing, so the refresh request isn't given the expiring token and doesn't wait on itself.
+    @TaskLocal private static var isRefreshing = false
+
+    {{ access }}init(token: String, refreshToken: String, refreshMargin: TimeInterval = 300, refresh: @escaping @Sendable (_ refreshToken: String) async throws -> (token: String, refreshToken: String)) {
+        self.token = token
+        self.refreshToken = refreshToken
+        self.refreshMargin = refreshMargin
+        self.refresh = refresh
+    }
+
+    /// Replaces the session, such as after authenticating again.
+    {{ access }}func update(token: String, refreshToken: String) {
+        self.token = token
+        self.refreshToken = refreshToken
+    }
+
+    /// Returns the token, refreshing the session first when it expires within the refresh margin. Concurrent callers
+    /// share a single refresh.
+    {{ access }}func validToken() async throws -> String {
+        if let refreshing {
+            return try await refreshing.value.token
+        }
+        guard let expiry = SessionManager.expiry(of: token), expiry.timeIntervalSinceNow < refreshMargin else {
+            return token
+        }
+
+        let task = Task { [refresh, refreshToken] in
+            try await SessionManager.$isRefreshing.withValue(true) {
+                try await refresh(refreshToken)
+            }
+        }
+        refreshing = task
+        defer { refreshing = nil }
+        let session = try await task.value
+        token = session.token
+        refreshToken = session.refreshToken
+        return token
+    }
+
+    {{ access }}func intercept(_ request: HttpRequest) async throws -> HttpRequest {
+        if SessionManager.isRefreshing {
+            return request
+        }
+        if let authorization = request.headers["Authorization"], !authorization.hasPrefix("Bearer ") {
+            return request
+        }
+        var request = request
+        request.headers["Authorization"] = "Bearer \(try await validToken())"
+        return request
+    }
+
+    /// The expiry of a JWT, read from the exp claim of its payload.
+    {{ access }}static func expiry(of jwt: String) -> Date? {
+        return JWT.expiry(of: jwt)
+    }
+}
+{{- end }}
+{{- with securityCredentials }}
+
+/// The credentials used to authorize requests, each operation uses the ones its security requirements accept.
+{{- if $.Options.ObjC }}
+// Unchecked since its properties are variables, share it between tasks only once it is built.
+@objcMembers
+{{ access }}final class Credentials: NSObject, @unchecked Sendable {
+{{- else }}
+{{ access }}struct Credentials: Sendable {
+{{- end }}
+    {{- range $credential := . }}
+    /// {{ $credential.Name }}: {{ $credential.Description | stripNewlines }}
+    {{ access }}var {{ $credential.Property }}: {{ if eq $credential.Kind "basic" }}(username: String, password: String)?{{ else }}String?{{ end }}
+    {{- end }}
+
+    {{ access }}init(
+    {{- range $idx, $credential := . }}
+        {{- if $idx }},{{ end }}
+        {{ $credential.Property }}: {{ if eq $credential.Kind "basic" }}(username: String, password: String)?{{ else }}String?{{ end }} = nil
+    {{- end }}) {
+        {{- range $credential := . }}
+        self.{{ $credential.Property }} = {{ $credential.Property }}
+        {{- end }}
+    }
+}
+{{- end }}
+{{- range $model := errorModels }}
+
+/// An ApiResponseError carrying the {{ $model }} body of a documented error response.
+final class {{ $model }}ResponseError: ApiResponseError {
+    /// The decoded body of the error response.
+    let body: {{ $model }}
+
+    required init(from decoder: Decoder) throws {
+        self.body = try {{ $model }}(from: decoder)
+        try super.init(from: decoder)
+    }
+}
+{{- end }}
+{{- if needsStreaming }}
+
+/// Decodes streamed response bodies of newline-delimited JSON messages.
+{{- with availability }}
+{{ . }}
+{{- end }}
+enum JSONLines {
+    /// Decodes every line of the chunks into a message.
+    static func decode<T: Decodable & Sendable>(_ type: T.Type, from chunks: AsyncThrowingStream<Data, Error>, coders: JSONCoders = .shared) -> AsyncThrowingStream<T, Error> {
+        return AsyncThrowingStream { continuation in
+            let task = Task {
+                do {
+                    let decoder = coders.makeDecoder()
+                    var buffer = Data()
+                    for try await chunk in chunks {
+                        buffer.append(chunk)
+                        while let newline = buffer.firstIndex(of: UInt8(ascii: "\n")) {
+                            let line = Data(buffer[buffer.startIndex..<newline])
+                            buffer = Data(buffer[buffer.index(after: newline)...])
+                            if !isBlank(line) {
+                                continuation.yield(try decoder.decode(type, from: line))
+                            }
+                        }
+                    }
+                    if !isBlank(buffer) {
+                        continuation.yield(try decoder.decode(type, from: buffer))
+                    }
+                    continuation.finish()
+                } catch {
+                    continuation.finish(throwing: error)
+                }
+            }
+            continuation.onTermination = { _ in
+                task.cancel()
+            }
+        }
+    }
+
+    /// Decodes grpc-gateway server streaming results, which wrap every message in a result or an error.
+    static func decodeResults<T: Decodable & Sendable>(_ type: T.Type, from chunks: AsyncThrowingStream<Data, Error>, coders: JSONCoders = .shared) -> AsyncThrowingStream<T, Error> {
+        let results = decode(StreamResult<T>.self, from: chunks, coders: coders)
+        return AsyncThrowingStream { continuation in
+            let task = Task {
+                do {
+                    for try await result in results {
+                        if let error = result.error {
+                            throw error
+                        }
+                        if let message = result.result {
+                            continuation.yield(message)
+                        }
+                    }
+                    continuation.finish()
+                } catch {
+                    continuation.finish(throwing: error)
+                }
+            }
+            continuation.onTermination = { _ in
+                task.cancel()
+            }
+        }
+    }
+
+    private static func isBlank(_ line: Data) -> Bool {
+        return line.allSatisfy { $0 == UInt8(ascii: " ") || $0 == UInt8(ascii: "\r") || $0 == UInt8(ascii: "\t") }
+    }
+
+    private struct StreamResult<T: Decodable & Sendable>: Decodable, Sendable {
+        let result: T?
+        let error: ApiResponseError?
+    }
+}
+{{- end }}
+{{- if needsEvents }}
+
+/// Reads Server-Sent Events streams, reconnecting whenever the connection drops.
+{{- with availability }}
+{{ . }}
+{{- end }}
+enum ServerSentEvents {
+    /// Streams the decoded data of every event. Events which fail to decode are skipped and the stream only ends
+    /// when it is cancelled or the server rejects the request with a 4xx status.
+    static func stream<T: Sendable>(connect: @escaping @Sendable (_ lastEventId: String?) -> AsyncThrowingStream<Data, Error>, decode: @escaping @Sendable (String) -> T?) -> AsyncStream<T> {
+        return AsyncStream { continuation in
+            let task = Task {
+                var lastEventId: String? = nil
+                var retryMilliseconds: UInt64 = 3000
+                while !Task.isCancelled {
+                    do {
+                        var buffer = Data()
+                        var data: [String] = []
+                        var eventId: String? = nil
+                        for try await chunk in connect(lastEventId) {
+                            buffer.append(chunk)
+                            while let newline = buffer.firstIndex(of: UInt8(ascii: "\n")) {
+                                var line = String(decoding: buffer[buffer.startIndex..<newline], as: UTF8.self)
+                                buffer = Data(buffer[buffer.index(after: newline)...])
+                                if line.hasSuffix("\r") {
+                                    line.removeLast()
+                                }
+
+                                if line.isEmpty {
+                                    // A blank line dispatches the event.
+                                    if let eventId {
+                                        lastEventId = eventId
+                                    }
+                                    if !data.isEmpty, let event = decode(data.joined(separator: "\n")) {
+                                        continuation.yield(event)
+                                    }
+                                    data = []
+                                    eventId = nil
+                                    continue
+                                }
+                                if line.hasPrefix(":") {
+                                    continue
+                                }
+
+                                let parts = line.split(separator: ":", maxSplits: 1, omittingEmptySubsequences: false)
+                                var value = parts.count > 1 ? String(parts[1]) : ""
+                                if value.hasPrefix(" ") {
+                                    value.removeFirst()
+                                }
+                                switch parts[0] {
+                                case "data":
+                                    data.append(value)
+                                case "id":
+                                    eventId = value
+                                case "retry":
+                                    if let retry = UInt64(value) {
+                                        retryMilliseconds = retry
+                                    }
+                                default:
+                                    break
+                                }
+                            }
+                        }
+                    } catch let error as ApiResponseError where (400...499).contains(error.statusCode ?? 0) {
+                        break
+                    } catch {
+                        // Reconnect below.
+                    }
+                    try? await Task.sleep(nanoseconds: retryMilliseconds * 1_000_000)
+                }
+                continuation.finish()
+            }
+            continuation.onTermination = { _ in
+                task.cancel()
+            }
+        }
+    }
+}
+{{- end }}
+{{- if needsMultipart }}
+
+/// A multipart/form-data request body.
+struct MultipartFormData {
+    let boundary = "Boundary-\(UUID().uuidString)"
+    private var body = Data()
+
+    /// The value of the Content-Type header for the body.
+    var contentType: String {
+        return "multipart/form-data; boundary=\(boundary)"
+    }
+
+    /// Appends a text field to the body.
+    mutating func append(name: String, value: String) {
+        append("--\(boundary)\r\n")
+        append("Content-Disposition: form-data; name=\"\(escape(name))\"\r\n\r\n")
+        append("\(value)\r\n")
+    }
+
+    /// Appends a file part to the body.
+    mutating func append(name: String, data: Data, filename: String, mimeType: String) {
+        append("--\(boundary)\r\n")
+        append("Content-Disposition: form-data; name=\"\(escape(name))\"; filename=\"\(escape(filename))\"\r\n")
+        append("Content-Type: \(mimeType)\r\n\r\n")
+        body.append(data)
+        append("\r\n")
+    }
+
+    /// The encoded body, terminated by the closing boundary.
+    func encoded() -> Data {
+        var data = body
+        data.append(Data("--\(boundary)--\r\n".utf8))
+        return data
+    }
+
+    private mutating func append(_ string: String) {
+        body.append(Data(string.utf8))
+    }
+
+    private func escape(_ value: String) -> String {
+        return value
+            .replacingOccurrences(of: "\r", with: "%0D")
+            .replacingOccurrences(of: "\n", with: "%0A")
+            .replacingOccurrences(of: "\"", with: "%22")
+    }
+}
+{{- end }}
+{{- with oauth2Flows }}
+
+/// The token returned by an OAuth2 token endpoint.
+{{ access }}struct OAuth2Token: Codable, Sendable {
+    {{ access }}let accessToken: String
+    {{ access }}let tokenType: String?
+    {{ access }}let expiresIn: Int?
+    {{ access }}let refreshToken: String?
+    {{ access }}let scope: String?
+
+    private enum CodingKeys: String, CodingKey {
+        case accessToken = "access_token"
+        case tokenType = "token_type"
+        case expiresIn = "expires_in"
+        case refreshToken = "refresh_token"
+        case scope
+    }
+}
+{{- end }}
+{{- end }}
+
+{{- define "modelSupport" }}
+{{- if needsAnyCodable }}
+
+/// A type erased Codable value used for schemas without a single fixed type.
+// Unchecked since the value is only ever a JSON value, which is immutable.
+{{ access }}struct AnyCodable: Codable, @unchecked Sendable {
+    {{ access }}let value: Any
+
+    {{ access }}init(_ value: Any) {
+        self.value = value
+    }
+
+    {{ access }}init(from decoder: Decoder) throws {
+        let container = try decoder.singleValueContainer()
+        if container.decodeNil() {
+            value = NSNull()
+        } else if let bool = try? container.decode(Bool.self) {
+            value = bool
+        } else if let int = try? container.decode(Int.self) {
+            value = int
+        } else if let double = try? container.decode(Double.self) {
+            value = double
+        } else if let string = try? container.decode(String.self) {
+            value = string
+        } else if let array = try? container.decode([AnyCodable].self) {
+            value = array.map { $0.value }
+        } else if let dictionary = try? container.decode([String: AnyCodable].self) {
+            value = dictionary.mapValues { $0.value }
+        } else {
+            throw DecodingError.dataCorruptedError(in: container, debugDescription: "Unsupported AnyCodable value")
+        }
+    }
+
+    {{ access }}func encode(to encoder: Encoder) throws {
+        var container = encoder.singleValueContainer()
+        switch value {
+        case is NSNull:
+            try container.encodeNil()
+        case let bool as Bool:
+            try container.encode(bool)
+        case let int as Int:
+            try container.encode(int)
+        case let double as Double:
+            try container.encode(double)
+        case let string as String:
+            try container.encode(string)
+        case let array as [Any]:
+            try container.encode(array.map { AnyCodable($0) })
+        case let dictionary as [String: Any]:
+            try container.encode(dictionary.mapValues { AnyCodable($0) })
+        default:
+            throw EncodingError.invalidValue(value, EncodingError.Context(codingPath: encoder.codingPath, debugDescription: "Unsupported AnyCodable value"))
+        }
+    }
+}
+{{- if .Options.Hashable }}
+
+extension AnyCodable: Hashable {
+    /// The value as JSON with sorted keys, so equal values are written the same way.
+    private var canonicalJSON: Data? {
+        let encoder = JSONEncoder()
+        encoder.outputFormatting = .sortedKeys
+        return try? encoder.encode(self)
+    }
+
+    {{ access }}static func == (lhs: AnyCodable, rhs: AnyCodable) -> Bool {
+        return lhs.canonicalJSON == rhs.canonicalJSON
+    }
+
+    {{ access }}func hash(into hasher: inout Hasher) {
+        hasher.combine(canonicalJSON)
+    }
+}
+{{- end }}
+{{- end }}
+{{- if usesCoding "" }}
+
+/// Converts a value to and from the string it is written as in JSON.
+protocol StringCoding {
+    associatedtype Value
+
+    static func decode(_ string: String) -> Value?
+    static func encode(_ value: Value) -> String
+}
+
+{{- if usesCoding "DateTimeCoding" }}
+
+/// RFC 3339 timestamps, such as the date-time strings written by grpc-gateway.
+enum DateTimeCoding: StringCoding {
+    private static let formatter: ISO8601DateFormatter = {
+        let formatter = ISO8601DateFormatter()
+        formatter.formatOptions = [.withInternetDateTime]
+        return formatter
+    }()
+
+    private static let fractionalFormatter: ISO8601DateFormatter = {
+        let formatter = ISO8601DateFormatter()
+        formatter.formatOptions = [.withInternetDateTime, .withFractionalSeconds]
+        return formatter
+    }()
+
+    static func decode(_ string: String) -> Date? {
+        if let date = formatter.date(from: string) {
+            return date
+        }
+
+        // The formatter only understands milliseconds, protobuf timestamps can carry up to nanoseconds.
+        guard let dot = string.firstIndex(of: ".") else {
+            return nil
+        }
+        let fraction = string[string.index(after: dot)...].prefix(while: { $0.isNumber })
+        let milliseconds = String(fraction.prefix(3)).padding(toLength: 3, withPad: "0", startingAt: 0)
+        return fractionalFormatter.date(from: String(string[...dot]) + milliseconds + String(string[fraction.endIndex...]))
+    }
+
+    static func encode(_ value: Date) -> String {
+        if value.timeIntervalSince1970.truncatingRemainder(dividingBy: 1) == 0 {
+            return formatter.string(from: value)
+        }
+        return fractionalFormatter.string(from: value)
+    }
+}
+{{- end }}
+{{- if usesCoding "DateCoding" }}
+
+/// RFC 3339 full dates such as 2024-01-31, read and written as midnight UTC.
+enum DateCoding: StringCoding {
+    private static let formatter: DateFormatter = {
+        let formatter = DateFormatter()
+        formatter.calendar = Calendar(identifier: .iso8601)
+        formatter.locale = Locale(identifier: "en_US_POSIX")
+        formatter.timeZone = TimeZone(secondsFromGMT: 0)
+        formatter.dateFormat = "yyyy-MM-dd"
+        return formatter
+    }()
+
+    static func decode(_ string: String) -> Date? {
+        return formatter.date(from: string)
+    }
+
+    static func encode(_ value: Date) -> String {
+        return formatter.string(from: value)
+    }
+}
+{{- end }}
+{{- if usesCoding "DurationCoding" }}
+
+/// protobuf Duration strings such as "3.5s", in seconds.
+enum DurationCoding: StringCoding {
+    static func decode(_ string: String) -> TimeInterval? {
+        guard string.hasSuffix("s") else {
+            return nil
+        }
+        return TimeInterval(string.dropLast())
+    }
+
+    static func encode(_ value: TimeInterval) -> String {
+        if value.rounded() == value {
+            return "\(Int64(value))s"
+        }
+        return String(format: "%.9fs", value)
+    }
+}
+{{- end }}
+{{- if usesCoding "Base64Coding" }}
+
+/// Base64 encoded bytes, as protobuf bytes fields are written in JSON.
+enum Base64Coding: StringCoding {
+    static func decode(_ string: String) -> Data? {
+        // protobuf accepts the URL safe alphabet and omitted padding as well.
+        var base64 = string.replacingOccurrences(of: "-", with: "+").replacingOccurrences(of: "_", with: "/")
+        if base64.count % 4 != 0 {
+            base64 += String(repeating: "=", count: 4 - base64.count % 4)
+        }
+        return Data(base64Encoded: base64)
+    }
+
+    static func encode(_ value: Data) -> String {
+        return value.base64EncodedString()
+    }
+}
+{{- end }}
+{{- end }}
+{{- if $.Options.Builders }}
+
+/// The error thrown by the build method of a model Builder.
+{{ access }}enum BuilderError: Error, Sendable {
+    /// A property without a default wasn't set, named as in the schema.
+    case missingProperty(String)
+}
+{{- end }}
+{{- end }}
+
+{{- define "definition" }}
+{{- $defname := .Name }}
+{{- $definition := .Definition }}
+{{- $classname := $defname | typeName }}
+
+{{- if isRefToEnum $defname }}
+
+/// {{ $definition.Title }}
+{{- $encoding := enumEncoding $defname }}
+{{- $docs := enumCaseDocs $definition.Description $definition.Enum }}
+{{ access }}enum {{ $classname }}: RawRepresentable, Codable, Hashable, Sendable {
+    {{- range $idx, $enum := $definition.Enum }}
+    {{- with index $docs $enum }}
+    /// {{ . }}
+    {{- end }}
+    case {{ enumCaseName $enum }}
+    {{- end }}
+    /// A value added to the server after this client was generated.
+    case unrecognized(String)
+
+    {{ access }}init(rawValue: String) {
+        switch rawValue {
+        {{- range $enum := $definition.Enum }}
+        case {{ swiftQuote $enum }}: self = .{{ enumCaseName $enum }}
+        {{- end }}
+        default: self = .unrecognized(rawValue)
+        }
+    }
+
+    {{ access }}var rawValue: String {
+        switch self {
+        {{- range $enum := $definition.Enum }}
+        case .{{ enumCaseName $enum }}: return {{ swiftQuote $enum }}
+        {{- end }}
+        case .unrecognized(let value): return value
+        }
+    }
+    {{- if ne $encoding "string" }}
+
+    /// Creates the value at a position of the schema enum, which is how it is sent as an integer.
+    {{ access }}init(intValue: Int) {
+        switch intValue {
+        {{- range $idx, $enum := $definition.Enum }}
+        case {{ $idx }}: self = .{{ enumCaseName $enum }}
+        {{- end }}
+        default: self = .unrecognized(String(intValue))
+        }
+    }
+
+    /// The position of the value in the schema enum, or nil for an unrecognized name.
+    {{ access }}var intValue: Int? {
+        switch self {
+        {{- range $idx, $enum := $definition.Enum }}
+        case .{{ enumCaseName $enum }}: return {{ $idx }}
+        {{- end }}
+        case .unrecognized(let value): return Int(value)
+        }
+    }
+    {{- end }}
+
+    {{ access }}init(from decoder: Decoder) throws {
+        {{- if eq $encoding "int" }}
+        self.init(intValue: try decoder.singleValueContainer().decode(Int.self))
+        {{- else if eq $encoding "either" }}
+        let container = try decoder.singleValueContainer()
+        if let value = try? container.decode(Int.self) {
+            self.init(intValue: value)
+        } else {
+            self.init(rawValue: try container.decode(String.self))
+        }
+        {{- else }}
+        self.init(rawValue: try decoder.singleValueContainer().decode(String.self))
+        {{- end }}
+    }
+
+    {{ access }}func encode(to encoder: Encoder) throws {
+        var container = encoder.singleValueContainer()
+        {{- if eq $encoding "int" }}
+        if let value = intValue {
+            try container.encode(value)
+        } else {
+            try container.encode(rawValue)
+        }
+        {{- else }}
+        try container.encode(rawValue)
+        {{- end }}
+    }
+}
+{{- else if $definition.Alias }}
+
+/// {{ (descriptionOrTitle $definition.Description $definition.Title) | stripNewlines }}
+{{ access }}typealias {{ $classname }} = {{ $definition.Alias }}
+{{- else if $definition.OneOf }}
+{{- $cases := oneOfCases $defname $definition }}
+
+/// {{ (descriptionOrTitle $definition.Description $definition.Title) | stripNewlines }}
+{{ access }}{{ if isRecursive $defname }}indirect {{ end }}enum {{ $classname }}: Codable, Sendable{{ if $.Options.Hashable }}, Hashable{{ end }} {
+    {{- range $case := $cases }}
+    case {{ $case.Name }}({{ $case.Type }})
+    {{- end }}
+    {{- if $definition.Discriminator.PropertyName }}
+
+    private enum DiscriminatorKeys: String, CodingKey {
+        case discriminator = "{{ $definition.Discriminator.PropertyName }}"
+    }
+
+    {{ access }}init(from decoder: Decoder) throws {
+        let container = try decoder.container(keyedBy: DiscriminatorKeys.self)
+        let discriminator = try container.decode(String.self, forKey: .discriminator)
+        switch discriminator {
+        {{- range $case := $cases }}
+        case {{ range $idx, $value := $case.Values }}{{ if $idx }}, {{ end }}"{{ $value }}"{{ end }}:
+            self = .{{ $case.Name }}(try {{ $case.Type }}(from: decoder))
+        {{- end }}
+        default:
+            throw DecodingError.dataCorruptedError(forKey: .discriminator, in: container, debugDescription: "Unknown {{ $classname }} type '\(discriminator)'")
+        }
+    }
+
+    {{ access }}func encode(to encoder: Encoder) throws {
+        var container = encoder.container(keyedBy: DiscriminatorKeys.self)
+        switch self {
+        {{- range $case := $cases }}
+        case .{{ $case.Name }}(let value):
+            try value.encode(to: encoder)
+            try container.encode("{{ index $case.Values 0 }}", forKey: .discriminator)
+        {{- end }}
+        }
+    }
+    {{- else }}
+
+    {{ access }}init(from decoder: Decoder) throws {
+        // Without a discriminator the first schema which decodes successfully wins.
+        {{- range $case := $cases }}
+        if let value = try? {{ $case.Type }}(from: decoder) {
+            self = .{{ $case.Name }}(value)
+            return
+        }
+        {{- end }}
+        throw DecodingError.dataCorrupted(DecodingError.Context(codingPath: decoder.codingPath, debugDescription: "No {{ $classname }} schema matched"))
+    }
+
+    {{ access }}func encode(to encoder: Encoder) throws {
+        switch self {
+        {{- range $case := $cases }}
+        case .{{ $case.Name }}(let value):
+            try value.encode(to: encoder)
+        {{- end }}
+        }
+    }
+    {{- end }}
+}
+{{- else if not $definition.Properties }}
+
+/// {{ (descriptionOrTitle $definition.Description $definition.Title) | stripNewlines }}
+{{- if $.Options.ObjC }}
+@objcMembers
+{{ access }}final class {{ $classname }}: NSObject, Codable, Sendable {}
+{{- else }}
+{{ access }}struct {{ $classname }}: Codable, Sendable{{ if $.Options.Hashable }}, Hashable{{ end }} {
+    {{ access }}init() {}
+}
+{{- end }}
+{{- else }}
+{{- $protocols := not (or $.Options.ValueTypes $.Options.NoProtocols) }}
+{{- $conformance := print $classname "Protocol" }}
+{{- if not $protocols }}
+{{- $conformance = "Codable" }}
+{{- end }}
+{{- if $.Options.Hashable }}
+{{- $conformance = print $conformance ", Hashable" }}
+{{- end }}
+{{- if $protocols }}
+
+/// {{ (descriptionOrTitle $definition.Description $definition.Title) | stripNewlines }}
+{{ access }}protocol {{ $classname }}Protocol: Codable {
+    {{- range $propname, $property := $definition.Properties }}
+    {{- $fieldname := $propname }}
+    {{- $fieldname = swiftName $fieldname }}
+
+    /// {{ (descriptionOrTitle $property.Description $property.Title) | stripNewlines }}
+    {{- with exampleOf $property }}
+    ///
+    /// Example: {{ . }}
+    {{- end }}
+    {{- if $property.Deprecated }}
+    @available(*, deprecated, message: "This field is deprecated.")
+    {{- end }}
+    var {{ $fieldname }}: {{ swiftType $property }}{{ if isOptional $definition $propname $property }}?{{ end }} { get }
+    {{- end }}
+}
+{{- end }}
+{{ if not $protocols }}
+/// {{ (descriptionOrTitle $definition.Description $definition.Title) | stripNewlines }}
+{{- end }}
+{{- if $.Options.ObjC }}
+// Unchecked since its properties are variables, share it between tasks only once it is built.
+@objcMembers
+{{ access }}final class {{ $classname }}: NSObject, {{ $conformance }}, @unchecked Sendable {
+{{- else if isRecursive $defname }}
+{{- $conformance = print $conformance ", CustomStringConvertible" }}
+// A class since {{ $classname }} contains itself, which a struct can't.
+{{- if $.Options.ValueTypes }}
+{{ access }}final class {{ $classname }}: {{ $conformance }}, Sendable {
+{{- else }}
+// Unchecked since its properties are variables, share it between tasks only once it is built.
+{{ access }}final class {{ $classname }}: {{ $conformance }}, @unchecked Sendable {
+{{- end }}
+{{- else }}
+{{ access }}struct {{ $classname }}: {{ $conformance }}, CustomStringConvertible, Sendable {
+{{- end }}
+    {{- range $propname, $property := $definition.Properties }}
+    {{- $fieldname := $propname }}
+    {{- $attrDataName := $propname | camelToSnake }}
+    {{- $fieldname = swiftName $fieldname }}
+    {{- if not $protocols }}
+
+    /// {{ (descriptionOrTitle $property.Description $property.Title) | stripNewlines }}
+    {{- with exampleOf $property }}
+    ///
+    /// Example: {{ . }}
+    {{- end }}
+    {{- end }}
+    {{- if $property.Deprecated }}
+    @available(*, deprecated, message: "This field is deprecated.")
+    {{- if $.Options.ValueTypes }}
+    {{ access }}var {{ $fieldname }}: {{ swiftType $property }}{{ if isOptional $definition $propname $property }}?{{ end }} { {{ storageName $propname $property }} }
+    private let {{ storageName $propname $property }}: {{ swiftType $property }}{{ if isOptional $definition $propname $property }}?{{ end }}
+    {{- else }}
+    {{ access }}var {{ $fieldname }}: {{ swiftType $property }}{{ if isOptional $definition $propname $property }}?{{ end }} {
+        get { {{ storageName $propname $property }} }
+        set { {{ storageName $propname $property }} = newValue }
+    }
+    private var {{ storageName $propname $property }}: {{ swiftType $property }}{{ if isOptional $definition $propname $property }}?{{ end }}{{ with defaultValue $property }} = {{ . }}{{ end }}
+    {{- end }}
+    {{- else if $.Options.ValueTypes }}
+    {{ access }}let {{ $fieldname }}: {{ swiftType $property }}{{ if isOptional $definition $propname $property }}?{{ end }}
+    {{- else }}
+    {{ access }}var {{ $fieldname }}: {{ swiftType $property }}{{ if isOptional $definition $propname $property }}?{{ end }}{{ with defaultValue $property }} = {{ . }}{{ end }}
+    {{- end }}
+    {{- end }}
+
+    private enum CodingKeys: String, CodingKey {
+        {{- range $fieldname, $property := $definition.Properties }}
+        {{- $propname := $fieldname }}
+        {{- $fieldname = swiftName $fieldname }}
+        case {{ $fieldname }} = {{ jsonKey $propname | swiftQuote }}
+        {{- end }}
+    }
+    
+    {{ access }}init(
+        {{- $first := true -}}
+        {{- range $propname, $property := $definition.Properties }}
+        {{- if $first }}{{- $first = false }}{{- else }}, {{- end }}
+        {{- $fieldname := $propname }}
+        {{- $fieldname = swiftName $fieldname }}
+        {{ $fieldname }}: {{ initParameter $definition $propname $property }}
+        {{- end }}
+    ) {
+        {{- range $propname, $property := $definition.Properties }}
+        {{- $fieldname := $propname }}
+        {{- $fieldname = swiftName $fieldname }}
+        self.{{ storageName $propname $property }} = {{ $fieldname }}
+        {{- end }}
+    }
+
+    {{ access }}init(from decoder: Decoder) throws {
+        {{- if $definition.Properties }}
+        let container = try decoder.container(keyedBy: CodingKeys.self)
+        {{- end }}
+        {{- range $propname, $property := $definition.Properties }}
+        {{- $fieldname := $propname }}
+        {{- $fieldname = swiftName $fieldname }}
+        {{- if propertyCoding $property }}
+        self.{{ storageName $propname $property }} = try container.{{ if isOptional $definition $propname $property }}decodeIfPresent{{ else }}decode{{ end }}({{ propertyCoding $property }}.self, forKey: .{{ $fieldname }})
+        {{- else if and $property.Nullable (isRequired $definition $propname) }}
+        self.{{ storageName $propname $property }} = try container.decode({{ swiftType $property }}?.self, forKey: .{{ $fieldname }})
+        {{- else if schemaDefault $property }}
+        self.{{ storageName $propname $property }} = try container.decodeIfPresent({{ swiftType $property }}.self, forKey: .{{ $fieldname }}) ?? {{ schemaDefault $property }}
+        {{- else if isOptional $definition $propname $property }}
+        self.{{ storageName $propname $property }} = try container.decodeIfPresent({{ swiftType $property }}.self, forKey: .{{ $fieldname }})
+        {{- else }}
+        self.{{ storageName $propname $property }} = try container.decode({{ swiftType $property }}.self, forKey: .{{ $fieldname }})
+        {{- end }}
+        {{- end }}
+    }
+
+    {{ access }}func encode(to encoder: Encoder) throws {
+        {{- if $definition.Properties }}
+        var container = encoder.container(keyedBy: CodingKeys.self)
+        {{- end }}
+        {{- range $propname, $property := $definition.Properties }}
+        {{- $fieldname := $propname }}
+        {{- $fieldname = swiftName $fieldname }}
+        {{- if $property.Nullable }}
+        if let value = {{ storageName $propname $property }} {
+            try container.encode(value{{ with propertyCoding $property }}, using: {{ . }}.self{{ end }}, forKey: .{{ $fieldname }})
+        } else {
+            try container.encodeNil(forKey: .{{ $fieldname }})
+        }
+        {{- else if isOptional $definition $propname $property }}
+        try container.encodeIfPresent({{ storageName $propname $property }}{{ with propertyCoding $property }}, using: {{ . }}.self{{ end }}, forKey: .{{ $fieldname }})
+        {{- else }}
+        try container.encode({{ storageName $propname $property }}{{ with propertyCoding $property }}, using: {{ . }}.self{{ end }}, forKey: .{{ $fieldname }})
+        {{- end }}
+        {{- end }}
+    }
+
+    {{ access }}{{ if $.Options.ObjC }}override {{ end }}var description: String {
+        return "{{ $classname }}(
+        {{- range $idx, $propname := propertyNames $definition }}
+        {{- $property := index $definition.Properties $propname }}
+        {{- if $idx }}, {{ end }}{{ $propname | snakeToCamel }}: {{ if isSensitive $propname $property }}<redacted>
+        {{- else if isOptional $definition $propname $property }}\(String(describing: {{ storageName $propname $property }}))
+        {{- else }}\({{ storageName $propname $property }})
+        {{- end }}
+        {{- end }})"
+    }
+
+    {{ access }}{{ if $.Options.ObjC }}override {{ end }}var debugDescription: String {
+        return description
+    }
+    {{- if and $.Options.Hashable (isRecursive $defname) }}
+
+    // Classes don't get synthesized conformances, so they compare and hash every property.
+    {{ access }}static func == (lhs: {{ $classname }}, rhs: {{ $classname }}) -> Bool {
+        return {{ range $idx, $propname := propertyNames $definition }}{{ if $idx }} && {{ end }}lhs.{{ storageName $propname (index $definition.Properties $propname) }} == rhs.{{ storageName $propname (index $definition.Properties $propname) }}{{ end }}
+    }
+
+    {{ access }}func hash(into hasher: inout Hasher) {
+        {{- range $propname, $property := $definition.Properties }}
+        hasher.combine({{ storageName $propname $property }})
+        {{- end }}
+    }
+    {{- end }}
+    {{- if $.Options.Builders }}
+    {{- $fields := builderFields $definition }}
+
+    /// Builds a {{ $classname }} one property at a time, every setter returns a copy with the property set.
+    {{ access }}struct Builder {
+        {{- range $field := $fields }}
+        private var {{ $field.Name }}: {{ $field.Type }}{{ with $field.Default }} = {{ . }}{{ else }}?{{ end }}
+        {{- end }}
+
+        {{ access }}init() {}
+        {{- range $field := $fields }}
+
+        /// Sets {{ $field.Name }}.
+        {{ access }}func {{ $field.Name }}(_ value: {{ $field.Type }}) -> Builder {
+            var builder = self
+            builder.{{ $field.Name }} = value
+            return builder
+        }
+        {{- end }}
+
+        /// Builds the {{ $classname }}, throwing a BuilderError when a property without a default wasn't set.
+        {{ access }}func build() throws -> {{ $classname }} {
+            {{- range $field := $fields }}
+            {{- if not $field.Default }}
+            guard let {{ $field.Name }} else {
+                throw BuilderError.missingProperty("{{ $field.Property }}")
+            }
+            {{- end }}
+            {{- end }}
+            return {{ $classname }}({{ range $idx, $field := $fields }}{{ if $idx }}, {{ end }}{{ $field.Name }}: {{ $field.Name }}{{ end }})
+        }
+    }
+    {{- end }}
+}
+{{- end }}
+{{- end }}
+
+{{- define "client" }}
+{{- if .Options.SplitTags }}
+{{- range $group := tagGroups }}
+
+/// The low level client for the {{ $group.Name }} operations of the {{ $.Namespace }} API.
+// Unchecked since the adapter isn't required to be Sendable, every other property is a constant. Internal whatever
+// the access level, like the HttpAdapterProtocol it takes.
+{{- if $.Options.ObjC }}
+@objcMembers
+{{- end }}
+class {{ $group.ClassName }}: {{ if $.Options.ObjC }}NSObject, {{ end }}@unchecked Sendable
+{
+    {{- template "clientProperties" $ }}
+    {{- range $operation := $group.Operations }}
+    {{- template "operation" $operation }}
+    {{- end }}
+}
+{{- template "deprecatedOperations" $group }}
+{{- end }}
+
+/// The low level client for the {{ .Namespace }} API, grouping the clients of every tag.
+// Unchecked since the adapter isn't required to be Sendable, every other property is a constant. Internal whatever
+// the access level, like the HttpAdapterProtocol it takes.
+{{- if $.Options.ObjC }}
+@objcMembers
+{{- end }}
+class ApiClient: {{ if $.Options.ObjC }}NSObject, {{ end }}@unchecked Sendable
+{
+    {{ access }}let httpAdapter: HttpAdapterProtocol
+    {{ access }}let timeout: Int
+    {{ access }}let coders: JSONCoders
+    {{- if $.Options.Retries }}
+    {{ access }}let retryPolicy: RetryPolicy
+    {{- end }}
+    {{ access }}let requestInterceptors: [RequestInterceptor]
+    {{ access }}let responseInterceptors: [ResponseInterceptor]
+    {{ access }}let logger: LoggerProtocol
+    {{ access }}let traceRequests: Bool
+    {{ access }}let metricsListener: ClientMetricsListener?
+    {{ access }}let defaultHeaders: [String: String]
+    {{ access }}let compressRequests: Bool
+    {{- if $.Options.Outbox }}
+    {{ access }}let outbox: Outbox?
+    {{- end }}
+    {{- range $group := tagGroups }}
+    {{ access }}let {{ $group.Property }}: {{ $group.ClassName }}
+    {{- end }}
+
+    {{ access }}init(baseUri: URL, httpAdapter: HttpAdapterProtocol, timeout: Int = 10, coders: JSONCoders = .shared{{ if $.Options.Retries }}, retryPolicy: RetryPolicy = .standard{{ end }}, requestInterceptors: [RequestInterceptor] = [], responseInterceptors: [ResponseInterceptor] = [], logger: LoggerProtocol = NoopLogger(), traceRequests: Bool = false, metricsListener: ClientMetricsListener? = nil, defaultHeaders: [String: String] = [:], compressRequests: Bool = false{{ if $.Options.Outbox }}, outbox: Outbox? = nil{{ end }})
+    {
+        self.httpAdapter = httpAdapter
+        self.timeout = timeout
+        self.coders = coders
+        {{- if $.Options.Retries }}
+        self.retryPolicy = retryPolicy
+        {{- end }}
+        self.requestInterceptors = requestInterceptors
+        self.responseInterceptors = responseInterceptors
+        self.logger = logger
+        self.traceRequests = traceRequests
+        self.metricsListener = metricsListener
+        self.defaultHeaders = defaultHeaders
+        self.compressRequests = compressRequests
+        {{- if $.Options.Outbox }}
+        self.outbox = outbox
+        {{- end }}
+        {{- range $group := tagGroups }}
+        self.{{ $group.Property }} = {{ $group.ClassName }}(baseUri: baseUri, httpAdapter: httpAdapter, timeout: timeout, coders: coders{{ if $.Options.Retries }}, retryPolicy: retryPolicy{{ end }}, requestInterceptors: requestInterceptors, responseInterceptors: responseInterceptors, logger: logger, traceRequests: traceRequests, metricsListener: metricsListener, defaultHeaders: defaultHeaders, compressRequests: compressRequests{{ if $.Options.Outbox }}, outbox: outbox{{ end }})
+        {{- end }}
+    }
+    {{- if $.Options.Outbox }}
+    {{- with tagGroups }}
+
+    /// Replays the requests the outbox queued while offline in order, call it once back online.
+    {{ access }}func replayOutbox() async throws {
+        try await {{ (index . 0).Property }}.replayOutbox()
+    }
+    {{- end }}
+    {{- end }}
+}
+{{- else }}
+
+/// The low level client for the {{ .Namespace }} API.
+// Unchecked since the adapter isn't required to be Sendable, every other property is a constant. Internal whatever
+// the access level, like the HttpAdapterProtocol it takes.
+{{- if $.Options.ObjC }}
+@objcMembers
+{{- end }}
+class ApiClient: {{ if $.Options.ObjC }}NSObject, {{ end }}@unchecked Sendable
+{
+    {{- template "clientProperties" $ }}
+    {{- range $operation := clientOperations }}
+    {{- template "operation" $operation }}
+    {{- end }}
+}
+{{- template "deprecatedOperations" deprecatedGroup }}
+{{- end }}
+{{- end }}
+
+{{- define "deprecatedOperations" }}
+{{- if .Deprecated }}
+
+/// The deprecated operations of {{ .ClassName }}, kept apart from the rest for backward compatibility.
+extension {{ .ClassName }}
+{
+    {{- range $operation := .Deprecated }}
+    {{- template "operation" $operation }}
+    {{- end }}
+}
+{{- end }}
+{{- end }}
+
+{{- define "clientExtensions" }}
+{{- with oauth2Flows }}
+
+/// The OAuth2 flows of the API, the access token of a flow is passed to operations as its Credentials property.
+extension {{ if $.Options.WrapNamespace }}{{ $.Namespace }}.{{ end }}ApiClient
+{
+    {{- range $flow := . }}
+    {{- $name := $flow.Credential.Property }}
+    {{- if $flow.AuthorizationUrl }}
+
+    /// The URL the user is sent to in order to authorize the client with {{ $flow.Credential.Name }}.
+    {{ access }}func {{ $name }}AuthorizationUrl(clientId: String, redirectUri: String, scopes: [String] = [], state: String? = nil) -> URL? {
+        guard var urlComponents = URLComponents(string: "{{ $flow.AuthorizationUrl }}") else {
+            return nil
+        }
+        var queryItems = urlComponents.queryItems ?? []
+        queryItems.append(URLQueryItem(name: "response_type", value: "code"))
+        queryItems.append(URLQueryItem(name: "client_id", value: clientId))
+        queryItems.append(URLQueryItem(name: "redirect_uri", value: redirectUri))
+        if !scopes.isEmpty {
+            queryItems.append(URLQueryItem(name: "scope", value: scopes.joined(separator: " ")))
+        }
+        if let state {
+            queryItems.append(URLQueryItem(name: "state", value: state))
+        }
+        urlComponents.queryItems = queryItems
+        return urlComponents.url
+    }
+    {{- end }}
+    {{- if and $flow.AuthorizationUrl $flow.TokenUrl }}
+
+    /// Exchanges the authorization code of {{ $flow.Credential.Name }} for a token.
+    {{- with availability }}
+    {{ . }}
+    {{- end }}
+    {{ access }}func {{ $name }}ExchangeCode(_ code: String, clientId: String, clientSecret: String? = nil, redirectUri: String) async throws -> OAuth2Token {
+        var fields = [
+            URLQueryItem(name: "grant_type", value: "authorization_code"),
+            URLQueryItem(name: "code", value: code),
+            URLQueryItem(name: "redirect_uri", value: redirectUri),
+            URLQueryItem(name: "client_id", value: clientId),
+        ]
+        if let clientSecret {
+            fields.append(URLQueryItem(name: "client_secret", value: clientSecret))
+        }
+        return try await requestOAuth2Token(url: "{{ $flow.TokenUrl }}", fields: fields)
+    }
+    {{- end }}
+    {{- if $flow.RefreshUrl }}
+
+    /// Exchanges the refresh token of {{ $flow.Credential.Name }} for a new token.
+    {{- with availability }}
+    {{ . }}
+    {{- end }}
+    {{ access }}func {{ $name }}RefreshToken(_ refreshToken: String, clientId: String, clientSecret: String? = nil) async throws -> OAuth2Token {
+        var fields = [
+            URLQueryItem(name: "grant_type", value: "refresh_token"),
+            URLQueryItem(name: "refresh_token", value: refreshToken),
+            URLQueryItem(name: "client_id", value: clientId),
+        ]
+        if let clientSecret {
+            fields.append(URLQueryItem(name: "client_secret", value: clientSecret))
+        }
+        return try await requestOAuth2Token(url: "{{ $flow.RefreshUrl }}", fields: fields)
+    }
+    {{- end }}
+    {{- end }}
+
+    {{- with availability }}
+    {{ . }}
+    {{- end }}
+    private func requestOAuth2Token(url: String, fields: [URLQueryItem]) async throws -> OAuth2Token {
+        guard let url = URL(string: url) else {
+            throw SatoriError.invalidURL
+        }
+
+        var form = URLComponents()
+        form.queryItems = fields
+        let content = form.percentEncodedQuery?.replacingOccurrences(of: "+", with: "%2B").data(using: .utf8)
+
+        let headers = ["Content-Type": "application/x-www-form-urlencoded"]
+        return try await httpAdapter.sendAsync(method: "POST", uri: url, headers: headers, body: content, timeoutSec: timeout)
+    }
+}
+{{- end }}
+{{- with sessionRefresh }}
+
+extension {{ if $.Options.WrapNamespace }}{{ $.Namespace }}.{{ end }}{{ .ClassName }}
+{
+    /// Creates a SessionManager refreshing the session through {{ .Method }}.
+    {{ access }}func sessionManager(token: String, refreshToken: String{{ if .Credentials }}, credentials: Credentials{{ end }}, refreshMargin: TimeInterval = 300) -> SessionManager {
+        return SessionManager(token: token, refreshToken: refreshToken, refreshMargin: refreshMargin) { [self] refreshToken in
+            let session = try await {{ .Method }}({{ if .Credentials }}credentials: credentials, {{ end }}{{ .BodyName }}: {{ .BodyType }}({{ .BodyRefreshToken }}: refreshToken))
+            {{- with .Session }}
+            {{- if or .TokenOptional .RefreshTokenOptional }}
+            guard let token = session.{{ .Token }}, let refreshToken = session.{{ .RefreshToken }} else {
+                throw ApiResponseError(grpcStatusCode: 0, message: "The refreshed session has no token")
+            }
+            return (token, refreshToken)
+            {{- else }}
+            return (session.{{ .Token }}, session.{{ .RefreshToken }})
+            {{- end }}
+            {{- end }}
+        }
+    }
+}
+{{- end }}
+{{- end }}
+
+{{- define "mockFile" }}
+{{- template "fileHeader" $ }}
+import Logging
+{{- template "namespaceStart" $ }}
+
+/// An HttpAdapter which records the requests sent through it and answers them with canned responses, for unit
+/// testing code which uses the generated client without a server.
+// Unchecked since every mutable property is guarded by the lock.
+{{ access }}final class MockHttpAdapter: HttpAdapterProtocol, @unchecked Sendable {
+    /// A request sent through the adapter.
+    {{ access }}struct Request: Sendable {
+        {{ access }}let method: String
+        {{ access }}let uri: URL
+        {{ access }}let headers: [String: String]
+        {{ access }}let body: Data?
+    }
+
+    /// A canned response.
+    {{ access }}struct Response: Sendable {
+        {{ access }}var statusCode: Int
+        {{ access }}var headers: [String: String]
+        {{ access }}var body: Data
+
+        {{ access }}init(statusCode: Int = 200, headers: [String: String] = [:], body: Data = Data("{}".utf8)) {
+            self.statusCode = statusCode
+            self.headers = headers
+            self.body = body
+        }
+
+        /// A response with the JSON encoding of a value as its body.
+        {{ access }}static func json<T: Encodable>(_ value: T, statusCode: Int = 200) throws -> Response {
+            return Response(statusCode: statusCode, headers: ["Content-Type": "application/json"], body: try JSONCoders.shared.makeEncoder().encode(value))
+        }
+
+        /// A response with the contents of a fixture file as its body.
+        {{ access }}static func fixture(_ url: URL, statusCode: Int = 200) throws -> Response {
+            return Response(statusCode: statusCode, headers: ["Content-Type": "application/json"], body: try Data(contentsOf: url))
+        }
+    }
+
+    /// The error thrown for a request which matches no stub while no response is enqueued.
+    {{ access }}struct UnexpectedRequest: Error {
+        {{ access }}let request: Request
+    }
+
+    {{ access }}var logger: Logger?
+
+    private let lock = NSLock()
+    private var sent: [Request] = []
+    private var stubs: [(method: String, path: String, response: Response)] = []
+    private var enqueued: [Response] = []
+
+    {{ access }}init() {}
+
+    /// Every request sent through the adapter, in order.
+    {{ access }}var requests: [Request] {
+        lock.lock()
+        defer { lock.unlock() }
+        return sent
+    }
+
+    /// Answers every request with the method and URL path, such as "GET" and "/v1/flag", with the response. A later
+    /// stub of the same request replaces the earlier one.
+    {{ access }}func stub(_ method: String, _ path: String, with response: Response) {
+        lock.lock()
+        defer { lock.unlock() }
+        stubs.insert((method, path, response), at: 0)
+    }
+
+    /// Answers the next request which matches no stub with the response.
+    {{ access }}func enqueue(_ response: Response) {
+        lock.lock()
+        defer { lock.unlock() }
+        enqueued.append(response)
+    }
+
+    func sendAsync<T: Codable>(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) async throws -> T {
+        let (data, response) = try await sendRawAsync(method: method, uri: uri, headers: headers, body: body, timeoutSec: timeoutSec)
+        guard (200...299).contains(response.statusCode) else {
+            throw ApiResponseError.decode(ApiResponseError.self, statusCode: response.statusCode, data: data, requestId: headers["X-Request-Id"], method: method, url: uri, response: response)
+        }
+        return try JSONCoders.shared.makeDecoder().decode(T.self, from: data)
+    }
+
+    func sendRawAsync(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) async throws -> (Data, HTTPURLResponse) {
+        // Cancelled requests aren't recorded, as a real adapter wouldn't send them.
+        try Task.checkCancellation()
+        let request = Request(method: method, uri: uri, headers: headers, body: body)
+        let response = try answer(request)
+        return (response.body, HTTPURLResponse(url: uri, statusCode: response.statusCode, httpVersion: "HTTP/1.1", headerFields: response.headers)!)
+    }
+
+    func streamAsync(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) -> AsyncThrowingStream<Data, Error> {
+        let request = Request(method: method, uri: uri, headers: headers, body: body)
+        return AsyncThrowingStream { continuation in
+            do {
+                let response = try answer(request)
+                guard (200...299).contains(response.statusCode) else {
+                    throw ApiResponseError.decode(ApiResponseError.self, statusCode: response.statusCode, data: response.body, requestId: headers["X-Request-Id"], method: method, url: uri)
+                }
+                continuation.yield(response.body)
+                continuation.finish()
+            } catch {
+                continuation.finish(throwing: error)
+            }
+        }
+    }
+
+    /// Records a request and picks its response, the matching stub or else the next enqueued response.
+    private func answer(_ request: Request) throws -> Response {
+        lock.lock()
+        defer { lock.unlock() }
+        sent.append(request)
+        if let stub = stubs.first(where: { $0.method == request.method && $0.path == request.uri.path }) {
+            return stub.response
+        }
+        guard !enqueued.isEmpty else {
+            throw UnexpectedRequest(request: request)
+        }
+        return enqueued.removeFirst()
+    }
+}
+{{- template "namespaceEnd" $ }}
+{{- end }}
+
+{{- define "alamofireFile" }}
+{{- template "fileHeader" $ }}
+import Alamofire
+import Logging
+{{- template "namespaceStart" $ }}
+
+/// An HttpAdapter which sends requests through an Alamofire Session, so they go through its interceptors and event
+/// monitors.
+// Unchecked since the logger is only meant to be set before any request is sent.
+{{ access }}final class AlamofireHttpAdapter: HttpAdapterProtocol, @unchecked Sendable {
+    {{ access }}var logger: Logger?
+
+    /// The session the requests are sent through.
+    {{ access }}let session: Session
+
+    {{ access }}init(session: Session = .default, logger: Logger? = nil) {
+        self.session = session
+        self.logger = logger
+    }
+
+    func sendAsync<T: Codable>(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) async throws -> T {
+        let (data, response) = try await sendRawAsync(method: method, uri: uri, headers: headers, body: body, timeoutSec: timeoutSec)
+        guard (200...299).contains(response.statusCode) else {
+            logger?.error("Server returned an error")
+            throw ApiResponseError.decode(ApiResponseError.self, statusCode: response.statusCode, data: data, requestId: headers["X-Request-Id"], method: method, url: uri, response: response)
+        }
+
+        do {
+            return try JSONCoders.shared.makeDecoder().decode(T.self, from: data)
+        } catch {
+            logger?.error("Failed to decode response: \(error.localizedDescription)")
+            throw error
+        }
+    }
+
+    func sendRawAsync(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) async throws -> (Data, HTTPURLResponse) {
+        let request = urlRequest(method: method, uri: uri, headers: headers, body: body, timeoutSec: timeoutSec)
+        let response = await session.request(request).serializingData(automaticallyCancelling: true).response
+        guard let httpResponse = response.response else {
+            logger?.error("Request failed: \(response.error?.localizedDescription ?? "no response")")
+            throw response.error ?? URLError(.badServerResponse)
+        }
+        // Empty bodies fail serialization for most statuses, they're returned as empty data all the same.
+        return (response.data ?? Data(), httpResponse)
+    }
+
+    func streamAsync(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) -> AsyncThrowingStream<Data, Error> {
+        let request = urlRequest(method: method, uri: uri, headers: headers, body: body, timeoutSec: timeoutSec)
+        let streamRequest = session.streamRequest(request)
+        return AsyncThrowingStream { continuation in
+            let task = Task { [logger] in
+                var errorBody = Data()
+                for await stream in streamRequest.streamTask().streamingData() {
+                    switch stream.event {
+                    case .stream(.success(let data)):
+                        if let statusCode = streamRequest.response?.statusCode, !(200...299).contains(statusCode) {
+                            errorBody.append(data)
+                        } else {
+                            continuation.yield(data)
+                        }
+                    case .complete(let completion):
+                        if let error = completion.error {
+                            logger?.error("Request failed: \(error.localizedDescription)")
+                            continuation.finish(throwing: error)
+                        } else if let statusCode = completion.response?.statusCode, !(200...299).contains(statusCode) {
+                            logger?.error("Server returned an error")
+                            continuation.finish(throwing: ApiResponseError.decode(ApiResponseError.self, statusCode: statusCode, data: errorBody, requestId: headers["X-Request-Id"], method: method, url: uri, response: completion.response))
+                        } else {
+                            continuation.finish()
+                        }
+                    }
+                }
+            }
+            continuation.onTermination = { _ in
+                task.cancel()
+                streamRequest.cancel()
+            }
+        }
+    }
+
+    private func urlRequest(method: String, uri: URL, headers: [String: String], body: Data?, timeoutSec: Int) -> URLRequest {
+        var request = URLRequest(url: uri)
+        request.httpMethod = method
+        request.allHTTPHeaderFields = headers
+        request.timeoutInterval = TimeInterval(timeoutSec)
+        request.httpBody = body
+        return request
+    }
+}
+{{- template "namespaceEnd" $ }}
+{{- end }}
+
+{{- define "modelExtensions" }}
+{{- if usesCoding "" }}
+
+extension KeyedDecodingContainer {
+    func decode<C: {{ if $.Options.WrapNamespace }}{{ $.Namespace }}.{{ end }}StringCoding>(_ coding: C.Type, forKey key: Key) throws -> C.Value {
+        let string = try decode(String.self, forKey: key)
+        guard let value = C.decode(string) else {
+            throw DecodingError.dataCorruptedError(forKey: key, in: self, debugDescription: "Invalid value '\(string)'")
+        }
+        return value
+    }
+
+    func decodeIfPresent<C: {{ if $.Options.WrapNamespace }}{{ $.Namespace }}.{{ end }}StringCoding>(_ coding: C.Type, forKey key: Key) throws -> C.Value? {
+        guard let string = try decodeIfPresent(String.self, forKey: key) else {
+            return nil
+        }
+        guard let value = C.decode(string) else {
+            throw DecodingError.dataCorruptedError(forKey: key, in: self, debugDescription: "Invalid value '\(string)'")
+        }
+        return value
+    }
+}
+
+extension KeyedEncodingContainer {
+    mutating func encode<C: {{ if $.Options.WrapNamespace }}{{ $.Namespace }}.{{ end }}StringCoding>(_ value: C.Value, using coding: C.Type, forKey key: Key) throws {
+        try encode(C.encode(value), forKey: key)
+    }
+
+    mutating func encodeIfPresent<C: {{ if $.Options.WrapNamespace }}{{ $.Namespace }}.{{ end }}StringCoding>(_ value: C.Value?, using coding: C.Type, forKey key: Key) throws {
+        if let value {
+            try encode(C.encode(value), forKey: key)
+        }
+    }
+}
+{{- end }}
+{{- end }}
+
+{{- define "clientProperties" }}
+    {{ access }}let httpAdapter: HttpAdapterProtocol
+    {{ access }}let timeout: Int
+    /// The coders bodies are encoded and responses decoded with, JSONCoders.shared unless given to init.
+    {{ access }}let coders: JSONCoders
+    {{- if $.Options.Retries }}
+    /// The retry policy of the idempotent operations which don't declare their own, non-idempotent ones never retry.
+    {{ access }}let retryPolicy: RetryPolicy
+    {{- end }}
+    /// Run in order on every request before it is sent.
+    {{ access }}let requestInterceptors: [RequestInterceptor]
+    /// Run in order on the response of every request which isn't streamed.
+    {{ access }}let responseInterceptors: [ResponseInterceptor]
+    /// Logs the requests of the client and why they failed, the adapter logs through its own logger.
+    {{ access }}let logger: LoggerProtocol
+    /// Logs every request as a curl command, and the body of the responses which fail to decode.
+    {{ access }}let traceRequests: Bool
+    /// Told when every request starts and ends.
+    {{ access }}let metricsListener: ClientMetricsListener?
+    /// Sent with every request, such as to route it through a gateway, unless the operation sets the same header.
+    {{ access }}let defaultHeaders: [String: String]
+    /// Sends request bodies of at least 1 KiB gzip compressed, for servers accepting a gzip Content-Encoding.
+    /// Responses are always accepted gzip compressed, URLSession decompresses them.
+    {{ access }}let compressRequests: Bool
+    {{- if $.Options.Outbox }}
+    /// Queues the write requests failing while offline, replayed by replayOutbox. Requests aren't queued when nil.
+    {{ access }}let outbox: Outbox?
+    {{- end }}
+
+    let baseUri: URL
+
+    {{ access }}init(baseUri: URL, httpAdapter: HttpAdapterProtocol, timeout: Int = 10, coders: JSONCoders = .shared{{ if $.Options.Retries }}, retryPolicy: RetryPolicy = .standard{{ end }}, requestInterceptors: [RequestInterceptor] = [], responseInterceptors: [ResponseInterceptor] = [], logger: LoggerProtocol = NoopLogger(), traceRequests: Bool = false, metricsListener: ClientMetricsListener? = nil, defaultHeaders: [String: String] = [:], compressRequests: Bool = false{{ if $.Options.Outbox }}, outbox: Outbox? = nil{{ end }})
+    {
+        self.baseUri = baseUri
+        self.httpAdapter = httpAdapter
+        self.timeout = timeout
+        self.coders = coders
+        {{- if $.Options.Retries }}
+        self.retryPolicy = retryPolicy
+        {{- end }}
+        self.requestInterceptors = requestInterceptors
+        self.responseInterceptors = responseInterceptors
+        self.logger = logger
+        self.traceRequests = traceRequests
+        self.metricsListener = metricsListener
+        self.defaultHeaders = defaultHeaders
+        self.compressRequests = compressRequests
+        {{- if $.Options.Outbox }}
+        self.outbox = outbox
+        {{- end }}
+    }
+
+    /// Joins the path of an operation onto the path of the base URI and the base path of the API.
+    private func operationPath(_ path: String) -> String {
+        var basePath = baseUri.path
+        while basePath.hasSuffix("/") {
+            basePath.removeLast()
+        }
+        return basePath + {{ if .BasePath }}"{{ .BasePath }}" + {{ end }}path
+    }
+
+    /// Creates a request with the default headers, the User-Agent and Accept-Encoding, and runs the request
+    /// interceptors on it before compressing its body.
+    private func prepare(method: String, uri: URL, headers: [String: String], body: Data?) async throws -> HttpRequest {
+        var headers = headers.merging(defaultHeaders) { header, _ in header }
+        if headers["User-Agent"] == nil {
+            headers["User-Agent"] = ClientVersion.userAgent
+        }
+        if headers["Accept-Encoding"] == nil {
+            headers["Accept-Encoding"] = "gzip"
+        }
+        var request = HttpRequest(method: method, url: uri, headers: headers, body: body)
+        for interceptor in requestInterceptors {
+            request = try await interceptor.intercept(request)
+        }
+        if compressRequests, let body = request.body, body.count >= 1024, request.headers["Content-Encoding"] == nil, let compressed = Gzip.compress(body) {
+            request.body = compressed
+            request.headers["Content-Encoding"] = "gzip"
+        }
+        return request
+    }
+
+    /// Sends a request through the interceptors without checking its status.
+    {{- if $.Options.Outbox }}
+    /// Requests which may queueOffline are queued in the outbox when they fail since the client is offline, before
+    /// any retry of their RetryPolicy.
+    {{- end }}
+    private func sendRaw(method: String, uri: URL, path: String, headers: [String: String], body: Data?{{ if $.Options.Outbox }}, queueOffline: Bool = false{{ end }}) async throws -> (Data, HTTPURLResponse) {
+        let request = try await prepare(method: method, uri: uri, headers: headers, body: body)
+        logger.debug(traceRequests ? request.curlCommand : "Sending \(request.method) \(request.url)")
+        metricsListener?.onRequestStart(method: request.method, path: path)
+        let start = Date()
+        let data: Data
+        let response: HTTPURLResponse
+        do {
+            (data, response) = try await httpAdapter.sendRawAsync(method: request.method, uri: request.url, headers: request.headers, body: request.body, timeoutSec: timeout)
+        } catch {
+            metricsListener?.onRequestEnd(RequestMetrics(method: request.method, path: path, statusCode: (error as? ApiResponseError)?.statusCode, duration: Date().timeIntervalSince(start), bytesSent: request.body?.count ?? 0, bytesReceived: 0))
+            logger.error("Request failed: \(error.localizedDescription)")
+            {{- if $.Options.Outbox }}
+            if queueOffline, let outbox, Outbox.isOffline(error) {
+                let queued = OutboxRequest(method: method, url: uri, headers: headers, body: body)
+                try await outbox.enqueue(queued)
+                logger.info("Queued \(method) \(uri) to replay once back online")
+                throw OutboxQueuedError(request: queued, underlyingError: error)
+            }
+            {{- end }}
+            throw error
+        }
+        metricsListener?.onRequestEnd(RequestMetrics(method: request.method, path: path, statusCode: response.statusCode, duration: Date().timeIntervalSince(start), bytesSent: request.body?.count ?? 0, bytesReceived: data.count))
+        for interceptor in responseInterceptors {
+            try await interceptor.intercept(response, data: data, for: request)
+        }
+        return (data, response)
+    }
+
+    /// Sends a request through the interceptors and decodes the body of its successful response.
+    private func send<T: Codable>(method: String, uri: URL, path: String, headers: [String: String], body: Data?{{ if $.Options.Outbox }}, queueOffline: Bool = false{{ end }}) async throws -> T {
+        let (data, response) = try await sendRaw(method: method, uri: uri, path: path, headers: headers, body: body{{ if $.Options.Outbox }}, queueOffline: queueOffline{{ end }})
+        guard (200...299).contains(response.statusCode) else {
+            logger.error("Server returned status \(response.statusCode)")
+            throw ApiResponseError.decode(ApiResponseError.self, statusCode: response.statusCode, data: data, requestId: headers["X-Request-Id"], method: method, url: uri, response: response, coders: coders)
+        }
+        do {
+            return try coders.makeDecoder().decode(T.self, from: data)
+        } catch {
+            logger.error("Failed to decode response: \(error.localizedDescription)")
+            if traceRequests {
+                logger.debug("Response body: \(String(decoding: data, as: UTF8.self))")
+            }
+            throw error
+        }
+    }
+
+    {{- if $.Options.Outbox }}
+
+    /// Replays the requests the outbox queued while offline in order, call it once back online.
+    {{ access }}func replayOutbox() async throws {
+        try await outbox?.replay { [self] request in
+            try await sendRaw(method: request.method, uri: request.url, path: request.url.path, headers: request.headers, body: request.body)
+        }
+    }
+    {{- end }}
+
+    /// Streams the response body of a request sent through the request interceptors.
+    private func stream(method: String, uri: URL, path: String, headers: [String: String], body: Data?) -> AsyncThrowingStream<Data, Error> {
+        return AsyncThrowingStream { continuation in
+            let task = Task { [self] in
+                var metrics: (request: HttpRequest, start: Date, bytesReceived: Int)?
+                do {
+                    let request = try await prepare(method: method, uri: uri, headers: headers, body: body)
+                    metricsListener?.onRequestStart(method: request.method, path: path)
+                    metrics = (request, Date(), 0)
+                    for try await chunk in httpAdapter.streamAsync(method: request.method, uri: request.url, headers: request.headers, body: request.body, timeoutSec: timeout) {
+                        metrics?.bytesReceived += chunk.count
+                        continuation.yield(chunk)
+                    }
+                    if let metrics {
+                        metricsListener?.onRequestEnd(RequestMetrics(method: metrics.request.method, path: path, statusCode: 200, duration: Date().timeIntervalSince(metrics.start), bytesSent: metrics.request.body?.count ?? 0, bytesReceived: metrics.bytesReceived))
+                    }
+                    continuation.finish()
+                } catch {
+                    if let metrics {
+                        metricsListener?.onRequestEnd(RequestMetrics(method: metrics.request.method, path: path, statusCode: (error as? ApiResponseError)?.statusCode, duration: Date().timeIntervalSince(metrics.start), bytesSent: metrics.request.body?.count ?? 0, bytesReceived: metrics.bytesReceived))
+                    }
+                    logger.error("Stream failed: \(error.localizedDescription)")
+                    continuation.finish(throwing: error)
+                }
+            }
+            continuation.onTermination = { _ in
+                task.cancel()
+            }
+        }
+    }
+    {{- if hasDownloads }}
+
+    /// Streams the response body of a request sent through the request interceptors into a file, replacing it.
+    private func download(method: String, uri: URL, path: String, headers: [String: String], body: Data?, to destination: URL) async throws {
+        guard FileManager.default.createFile(atPath: destination.path, contents: nil) else {
+            throw CocoaError(.fileWriteUnknown, userInfo: [NSFilePathErrorKey: destination.path])
+        }
+        let file = try FileHandle(forWritingTo: destination)
+        defer {
+            try? file.close()
+        }
+        do {
+            for try await chunk in stream(method: method, uri: uri, path: path, headers: headers, body: body) {
+                file.write(chunk)
+            }
+        } catch {
+            // Don't leave a partial file behind.
+            try? FileManager.default.removeItem(at: destination)
+            throw error
+        }
+    }
+    {{- end }}
+{{- end }}
+
+{{- define "decodeResponse" }}
+{{- if eq .Media "text" }}String(decoding: data, as: UTF8.self)
+{{- else if eq .Media "binary" }}data
+{{- else }}try coders.makeDecoder().decode({{ .Model }}.self, from: data)
+{{- end }}
+{{- end }}
+
+{{- define "operationRequest" }}
+{{- $url := .Url }}
+{{- $method := .Method }}
+{{- $operation := .Operation }}
+{{- $credentials := operationCredentials $operation }}
+
+        var urlComponents = URLComponents()
+        urlComponents.scheme = baseUri.scheme
+        urlComponents.host = baseUri.host
+        urlComponents.port = baseUri.port
+        urlComponents.path = operationPath("{{- $url }}")
+
+        {{- range $parameter := $operation.Parameters }}
+        {{- $camelToSnake := $parameter.Name | camelToSnake }}
+        {{- if eq $parameter.In "path" }}
+        urlComponents.path.append({{ $parameter.Name | swiftName }}{{ if eq $parameter.Format "uuid" }}.uuidString{{ end }}.addingPercentEncoding(withAllowedCharacters: .urlPathAllowed)!)
+        {{- end }}
+    {{- end }}
+
+        var queryItems = [URLQueryItem]()
+        {{- range $parameter := $operation.Parameters }}
+        {{- $camelToSnake := $parameter.Name | camelToSnake }}
+        {{- if eq $parameter.In "query"}}
+            {{- if and (eq $parameter.Name "cursor") (cursorType $operation) }}
+        if let cursor {
+            queryItems.append(URLQueryItem(name: "cursor", value: cursor.rawValue))
+        }
+            {{- else if eq $parameter.Type "integer" }}
+        if let {{ $parameter.Name | swiftName }} {
+            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: "\({{ $parameter.Name | swiftName }})"))
+        }
+            {{- else if and (eq $parameter.Type "string") (eq $parameter.Format "uuid") }}
+        if let {{ $parameter.Name | swiftName }} {
+            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: {{ $parameter.Name | swiftName }}.uuidString))
+        }
+            {{- else if eq $parameter.Type "string" }}
+        if let {{ $parameter.Name | swiftName }} {
+            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: {{ $parameter.Name | swiftName }}.lowercased()))
+        }
+            {{- else if eq $parameter.Type "boolean" }}
+        if let {{ $parameter.Name | swiftName }} {
+            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: "\({{ $parameter.Name | swiftName }})".addingPercentEncoding(withAllowedCharacters: .urlQueryAllowed)))
+        }
+            {{- else if and (eq $parameter.Type "array") (collectionSeparator $parameter) }}
+        if !{{ $parameter.Name | snakeToCamel | swiftName }}.isEmpty {
+            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: {{ $parameter.Name | snakeToCamel | swiftName }}.map { "\($0)" }.joined(separator: {{ collectionSeparator $parameter | swiftQuote }})))
+        }
+            {{- else if eq $parameter.Type "array" }}
+        for param in {{ $parameter.Name | snakeToCamel | swiftName }} {
+            {{- if eq $parameter.Items.Type "string" }}
+            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: param))
+                {{- else }}
+            queryItems.append(URLQueryItem(name: "{{- $camelToSnake }}", value: param.description))
+                {{- end }}
+        }
+            {{- else }}
+        {{ $parameter }}
+            {{- end }}
+        {{- end }}
+    {{- end }}
+        {{- range $credential := $credentials }}
+        {{- if eq $credential.Kind "query" }}
+        if let {{ $credential.Property }} = credentials.{{ $credential.Property }}, !{{ $credential.Property }}.isEmpty {
+            queryItems.append(URLQueryItem(name: "{{ $credential.Key }}", value: {{ $credential.Property }}))
+        }
+        {{- end }}
+        {{- end }}
+        urlComponents.queryItems = queryItems
+        guard let url = urlComponents.url else {
+            throw SatoriError.invalidURL
+        }
+
+        let method = "{{- $method | uppercase }}"
+        var headers: [String: String] = [:]
+        headers["X-Request-Id"] = UUID().uuidString
+        {{- if idempotencyKey . }}
+        // Made once for all the retries, so the server applies the write only once.
+        headers["Idempotency-Key"] = UUID().uuidString
+        {{- end }}
+
+        {{- range $credential := $credentials }}
+        {{- if eq $credential.Kind "basic" }}
+        if let {{ $credential.Property }} = credentials.{{ $credential.Property }}, !{{ $credential.Property }}.username.isEmpty {
+            let encoded = Data("\({{ $credential.Property }}.username):\({{ $credential.Property }}.password)".utf8).base64EncodedString()
+            headers["Authorization"] = "Basic \(encoded)"
+        }
+        {{- else if eq $credential.Kind "bearer" }}
+        if let {{ $credential.Property }} = credentials.{{ $credential.Property }}, !{{ $credential.Property }}.isEmpty {
+            headers["Authorization"] = "Bearer \({{ $credential.Property }})"
+        }
+        {{- else if eq $credential.Kind "header" }}
+        if let {{ $credential.Property }} = credentials.{{ $credential.Property }}, !{{ $credential.Property }}.isEmpty {
+            headers["{{ $credential.Key }}"] = {{ $credential.Property }}
+        }
+        {{- end }}
+        {{- end }}
+
+        {{- range $parameter := $operation.Parameters }}
+        {{- if eq $parameter.In "header" }}
+        {{- $name := $parameter.Name | headerParameterName }}
+        {{- if $parameter.Required }}
+        headers["{{ $parameter.Name }}"] = {{ if eq (formattedType $parameter.Type $parameter.Format) "String" "" }}{{ $name }}{{ else }}"\({{ $name }})"{{ end }}
+        {{- else }}
+        if let {{ $name }} {
+            headers["{{ $parameter.Name }}"] = {{ if eq (formattedType $parameter.Type $parameter.Format) "String" "" }}{{ $name }}{{ else }}"\({{ $name }})"{{ end }}
+        }
+        {{- end }}
+        {{- end }}
+        {{- end }}
+        {{- if usesCookies $operation }}
+
+        var cookies: [String] = []
+        {{- range $parameter := $operation.Parameters }}
+        {{- if eq $parameter.In "cookie" }}
+        {{- $name := $parameter.Name | headerParameterName }}
+        {{- if $parameter.Required }}
+        cookies.append("{{ $parameter.Name }}=\({{ $name }})")
+        {{- else }}
+        if let {{ $name }} {
+            cookies.append("{{ $parameter.Name }}=\({{ $name }})")
+        }
+        {{- end }}
+        {{- end }}
+        {{- end }}
+        {{- range $credential := $credentials }}
+        {{- if eq $credential.Kind "cookie" }}
+        if let {{ $credential.Property }} = credentials.{{ $credential.Property }}, !{{ $credential.Property }}.isEmpty {
+            cookies.append("{{ $credential.Key }}=\({{ $credential.Property }})")
+        }
+        {{- end }}
+        {{- end }}
+        if !cookies.isEmpty {
+            headers["Cookie"] = cookies.joined(separator: "; ")
+        }
+        {{- end }}
+
+        var content: Data? = nil
+        {{- range $parameter := $operation.Parameters }}
+        {{- if isFileParameter $parameter }}
+        {{- $name := $parameter.Name | snakeToCamel | swiftName }}
+        {{- if not $parameter.Required }}
+        if let {{ $name }} {
+            headers["Content-Type"] = {{ $name }}MimeType
+            headers["Content-Disposition"] = "attachment; filename=\"\({{ $name }}Filename)\""
+            content = {{ $name }}
+        }
+        {{- else }}
+        headers["Content-Type"] = {{ $name }}MimeType
+        headers["Content-Disposition"] = "attachment; filename=\"\({{ $name }}Filename)\""
+        content = {{ $name }}
+        {{- end }}
+        {{- else if eq $parameter.In "body" }}
+        let encoder = coders.makeEncoder()
+        do {
+            content = try encoder.encode({{ $parameter.Name | swiftName }})
+        } catch {
+            logger.error("Failed to encode body: \(error.localizedDescription)")
+        }
+        {{- end }}
+        {{- end }}
+
+        {{- if hasFormData $operation }}
+        var form = MultipartFormData()
+        {{- range $parameter := $operation.Parameters }}
+        {{- if eq $parameter.In "formData" }}
+        {{- $name := $parameter.Name | snakeToCamel | swiftName }}
+        {{- if eq $parameter.Type "file" }}
+        {{- if $parameter.Required }}
+        form.append(name: "{{ $parameter.Name }}", data: {{ $name }}, filename: {{ $name }}Filename, mimeType: {{ $name }}MimeType)
+        {{- else }}
+        if let {{ $name }} {
+            form.append(name: "{{ $parameter.Name }}", data: {{ $name }}, filename: {{ $name }}Filename, mimeType: {{ $name }}MimeType)
+        }
+        {{- end }}
+        {{- else if eq $parameter.Type "array" }}
+        for value in {{ $name }}{{- if not $parameter.Required }} ?? []{{- end }} {
+            form.append(name: "{{ $parameter.Name }}", value: "\(value)")
+        }
+        {{- else if $parameter.Required }}
+        form.append(name: "{{ $parameter.Name }}", value: "\({{ $name }})")
+        {{- else }}
+        if let {{ $name }} {
+            form.append(name: "{{ $parameter.Name }}", value: "\({{ $name }})")
+        }
+        {{- end }}
+        {{- end }}
+        {{- end }}
+        headers["Content-Type"] = form.contentType
+        content = form.encoded()
+        {{- end }}
+{{- end }}
+
+{{- define "operation" }}
+{{- $operation := .Operation }}
+{{- $retry := retryPolicy . }}
+{{- $queue := queuesOffline . }}
+
+    /// {{ $operation.Summary | stripNewlines }}
+    {{- with $operation.Description }}
+    ///
+    /// {{ . | stripNewlines }}
+    {{- end }}
+    ///
+    {{- with parameterDocs $operation }}
+    /// - Parameters:
+    {{- range . }}
+    ///   - {{ .Name }}: {{ .Description }}
+    {{- end }}
+    {{- end }}
+    {{- with returnsDoc $operation }}
+    /// - Returns: {{ . }}
+    {{- end }}
+    /// - Throws: {{ throwsDoc $operation }}
+    {{- if $operation.Deprecated }}
+    @available(*, deprecated, message: "This operation is deprecated.")
+    {{- end }}
+    {{- with availability }}
+    {{ . }}
+    {{- end }}
+    {{ access }}func {{ operationName $operation }}({{- template "operationParameters" $operation }}) async throws -> {{ (successResponse $operation).ReturnType }} {
+        {{- range $parameter := $operation.Parameters }}
+        {{- if $parameter.Required }}
+        {{- end }}
+    {{- end }}
+        {{- template "operationRequest" . }}
+
+        try Task.checkCancellation()
+
+        {{- $success := successResponse $operation }}
+        {{- $errors := errorResponses $operation }}
+        {{- if $success.Events }}
+        headers["Accept"] = "text/event-stream"
+        return ServerSentEvents.stream(connect: { [self, headers, content] lastEventId in
+            var eventHeaders = headers
+            if let lastEventId {
+                eventHeaders["Last-Event-ID"] = lastEventId
+            }
+            return self.stream(method: method, uri: url, path: {{ $.Url | swiftQuote }}, headers: eventHeaders, body: content)
+        }, decode: { {{ if eq $success.Media "json" }}[self] {{ end }}data in
+            {{- if eq $success.Media "json" }}
+            return try? self.coders.makeDecoder().decode({{ $success.Model }}.self, from: Data(data.utf8))
+            {{- else }}
+            return data
+            {{- end }}
+        })
+        {{- else if $success.Stream }}
+        let chunks = stream(method: method, uri: url, path: {{ $.Url | swiftQuote }}, headers: headers, body: content)
+        return JSONLines.{{ if $success.Wrapped }}decodeResults{{ else }}decode{{ end }}({{ $success.Model }}.self, from: chunks, coders: coders)
+        {{- else if or $success.Switch $errors }}
+        let (data, response) = try await {{ with $retry }}{{ . }}.run { try await {{ end }}sendRaw(method: method, uri: url, path: {{ $.Url | swiftQuote }}, headers: headers, body: content{{ if $queue }}, queueOffline: true{{ end }}){{ if $retry }} }{{ end }}
+        switch response.statusCode {
+        {{- range $status := $success.Statuses }}
+        case {{ $status.Case }}:
+            {{- if $status.Model }}
+            return {{ template "decodeResponse" $status }}
+            {{- else if $success.Model }}
+            return nil
+            {{- else }}
+            return
+            {{- end }}
+        {{- end }}
+        case 200...299:
+            {{- if $success.Model }}
+            return {{ template "decodeResponse" $success }}
+            {{- else }}
+            return
+            {{- end }}
+        {{- range $error := $errors }}
+        case {{ $error.Case }}:
+            throw ApiResponseError.decode({{ $error.Model }}ResponseError.self, statusCode: response.statusCode, data: data, requestId: headers["X-Request-Id"], method: method, url: url, response: response, coders: coders)
+        {{- end }}
+        default:
+            throw ApiResponseError.decode(ApiResponseError.self, statusCode: response.statusCode, data: data, requestId: headers["X-Request-Id"], method: method, url: url, response: response, coders: coders)
+        }
+        {{- else if $operation.Responses.Ok.Schema.Ref }}
+        var response: {{ $operation.Responses.Ok.Schema.Ref | cleanRef }} = try await {{ with $retry }}{{ . }}.run { try await {{ end }}send(method: method, uri: url, path: {{ $.Url | swiftQuote }}, headers: headers, body: content{{ if $queue }}, queueOffline: true{{ end }}){{ if $retry }} }{{ end }}
+        return response
+        {{- else }}
+        let _: EmptyResponse = try await {{ with $retry }}{{ . }}.run { try await {{ end }}send(method: method, uri: url, path: {{ $.Url | swiftQuote }}, headers: headers, body: content{{ if $queue }}, queueOffline: true{{ end }}){{ if $retry }} }{{ end }}
+        {{- end }}
+    }
+    {{- with completionType $operation }}
+
+    /// {{ $operation.Summary | stripNewlines }}
+    ///
+    /// Passes the result of the async variant to completion, for callers which can't await such as Objective-C.
+    {{- if $operation.Deprecated }}
+    @available(*, deprecated, message: "This operation is deprecated.")
+    {{- end }}
+    {{- with availability }}
+    {{ . }}
+    {{- end }}
+    {{ access }}func {{ operationName $operation }}({{- template "operationParameters" $operation }}{{ if or (operationCredentials $operation) $operation.Parameters }},{{ end }}
+        completion: @escaping @Sendable {{ . }}) {
+        Task {
+            do {
+                {{- if eq (successResponse $operation).ReturnType "Void" }}
+                try await {{ operationName $operation }}({{ operationArguments $operation }})
+                completion(nil)
+                {{- else }}
+                completion(try await {{ operationName $operation }}({{ operationArguments $operation }}), nil)
+                {{- end }}
+            } catch {
+                completion({{ if ne (successResponse $operation).ReturnType "Void" }}nil, {{ end }}error)
+            }
+        }
+    }
+    {{- end }}
+    {{- if isDownload $operation }}
+
+    /// {{ $operation.Summary | stripNewlines }}
+    ///
+    /// Streams the response into the file at destination as it arrives instead of buffering it, replacing the file.
+    {{- if $operation.Deprecated }}
+    @available(*, deprecated, message: "This operation is deprecated.")
+    {{- end }}
+    {{- with availability }}
+    {{ . }}
+    {{- end }}
+    {{ access }}func {{ operationName $operation }}Download({{- template "operationParameters" $operation }}{{ if or (operationCredentials $operation) $operation.Parameters }},{{ end }}
+        to destination: URL) async throws {
+        {{- template "operationRequest" $ }}
+
+        try Task.checkCancellation()
+        try await download(method: method, uri: url, path: {{ $.Url | swiftQuote }}, headers: headers, body: content, to: destination)
+    }
+    {{- end }}
+    {{- with publisherType $operation }}
+
+    #if canImport(Combine)
+    /// {{ $operation.Summary | stripNewlines }}
+    ///
+    /// Publishes the result of the async variant when subscribed to, for Combine pipelines.
+    {{- if $operation.Deprecated }}
+    @available(*, deprecated, message: "This operation is deprecated.")
+    {{- end }}
+    {{- with availability }}
+    {{ . }}
+    {{- end }}
+    {{ access }}func {{ operationName $operation }}Publisher({{- template "operationParameters" $operation }}) -> AnyPublisher<{{ . }}, Error> {
+        return Deferred {
+            Future { promise in
+                Task {
+                    do {
+                        promise(.success(try await {{ operationName $operation }}({{ operationArguments $operation }})))
+                    } catch {
+                        promise(.failure(error))
+                    }
+                }
+            }
+        }
+        .eraseToAnyPublisher()
+    }
+    #endif
+    {{- end }}
+    {{- with resultType $operation }}
+
+    /// {{ $operation.Summary | stripNewlines }}
+    ///
+    /// Returns the result of the async variant instead of throwing, with the errors which aren't an ApiResponseError wrapped in one.
+    {{- if $operation.Deprecated }}
+    @available(*, deprecated, message: "This operation is deprecated.")
+    {{- end }}
+    {{- with availability }}
+    {{ . }}
+    {{- end }}
+    {{ access }}func {{ operationName $operation }}Result({{- template "operationParameters" $operation }}) async -> Result<{{ . }}, ApiResponseError> {
+        do {
+            return .success(try await {{ operationName $operation }}({{ operationArguments $operation }}))
+        } catch {
+            return .failure(.wrapping(error))
+        }
+    }
+    {{- end }}
+    {{- with pagination $operation }}
+
+    /// {{ $operation.Summary | stripNewlines }}
+    ///
+    /// Pages through the results from cursor, requesting every next page with the cursor of the last until one has none.
+    {{- if $operation.Deprecated }}
+    @available(*, deprecated, message: "This operation is deprecated.")
+    {{- end }}
+    {{- with availability }}
+    {{ . }}
+    {{- end }}
+    {{ access }}func {{ operationName $operation }}Pages({{- template "operationParameters" $operation }}) -> AsyncThrowingStream<{{ .Model }}, Error> {
+        return AsyncThrowingStream { continuation in
+            let task = Task { [self] in
+                do {
+                    var cursor = cursor
+                    repeat {
+                        let page = try await {{ operationName $operation }}({{ operationArguments $operation }})
+                        continuation.yield(page)
+                        {{- if .Typed }}
+                        cursor = page.nextPageCursor
+                    } while cursor != nil
+                        {{- else }}
+                        cursor = page.{{ .Cursor }}
+                    } while !(cursor ?? "").isEmpty
+                        {{- end }}
+                    continuation.finish()
+                } catch {
+                    continuation.finish(throwing: error)
+                }
+            }
+            continuation.onTermination = { _ in
+                task.cancel()
+            }
+        }
+    }
+    {{- end }}
+{{- end }}
+
+{{- define "operationParameters" }}
+{{- $operation := . }}
+
+    {{- $isPreviousParam := false}}
+
+    {{- $credentials := operationCredentials $operation }}
+    {{- if $credentials }}
+        {{- $isPreviousParam = true}}
+        credentials: Credentials
+    {{- end }}
+
+    {{- range $parameter := $operation.Parameters }}
+
+    {{- if eq $isPreviousParam true}},{{- end}}
+    {{- if isFileParameter $parameter }}
+        {{- $name := $parameter.Name | snakeToCamel | swiftName }}
+        {{ $name }}: Data{{- if not $parameter.Required }}?{{- end }},
+        {{ $name }}Filename: String = "{{ $parameter.Name }}",
+        {{ $name }}MimeType: String = "application/octet-stream"
+    {{- else if eq $parameter.In "path" }}
+        {{ $parameter.Name | swiftName }}: {{ if eq $parameter.Format "uuid" }}UUID{{ else }}{{ $parameter.Type | camelToPascal }}{{ end }}{{- if not $parameter.Required }}?{{- end }}
+    {{- else if eq $parameter.In "body" }}
+        {{- if eq $parameter.Schema.Type "string" }}
+        string{{- if not $parameter.Required }}?{{- end }} {{ $parameter.Name | swiftName }}
+        {{- else }}
+        {{ $parameter.Name | swiftName }}: {{ $parameter.Schema.Ref | cleanRef }}{{- if not $parameter.Required }}?{{- end }}
+        {{- end }}
+    {{- else if eq $parameter.In "header" }}
+        {{ $parameter.Name | headerParameterName }}: {{ or (formattedType $parameter.Type $parameter.Format) "String" }}{{- if not $parameter.Required }}?{{- end }}
+    {{- else if eq $parameter.In "cookie" }}
+        {{ $parameter.Name | headerParameterName }}: {{ or (formattedType $parameter.Type $parameter.Format) "String" }}{{- if not $parameter.Required }}?{{- end }}
+    {{- else if eq $parameter.In "formData" }}
+        {{- $name := $parameter.Name | snakeToCamel | swiftName }}
+        {{- if eq $parameter.Type "file" }}
+        {{ $name }}: Data{{- if not $parameter.Required }}?{{- end }},
+        {{ $name }}Filename: String = "{{ $parameter.Name }}",
+        {{ $name }}MimeType: String = "application/octet-stream"
+        {{- else if eq $parameter.Type "array" }}
+        {{ $name }}: [{{ or (primitiveType $parameter.Items.Type) "String" }}]{{- if not $parameter.Required }}?{{- end }}
+        {{- else }}
+        {{ $name }}: {{ or (primitiveType $parameter.Type) "String" }}{{- if not $parameter.Required }}?{{- end }}
+        {{- end }}
+    {{- else if eq $parameter.Type "array"}}
+        {{ $parameter.Name | snakeToCamel | swiftName }}: [{{ $parameter.Items.Type | camelToPascal }}]
+    {{- else if eq $parameter.Type "object"}}
+        {{- if eq $parameter.AdditionalProperties.Type "string"}}
+    {{ $parameter.Name | swiftName }}: [String : String]
+        {{- else if eq $parameter.Items.Type "integer"}}
+    {{ $parameter.Name | swiftName }}: [String : Int]
+        {{- else if eq $parameter.Items.Type "boolean"}}
+    {{ $parameter.Name | swiftName }}: [String : Int]
+        {{- else}}
+    {{ $parameter.Name | swiftName }}: [String : {{ $parameter.Items.Type }}] 
+        {{- end}}
+    {{- else if eq $parameter.Type "integer" }}
+        {{ $parameter.Name | swiftName }}: Int?
+    {{- else if eq $parameter.Type "boolean" }}
+        {{ $parameter.Name | swiftName }}: Bool?
+    {{- else if and (eq $parameter.Name "cursor") (cursorType $operation) }}
+        cursor: {{ cursorType $operation }}?
+    {{- else if and (eq $parameter.Type "string") (eq $parameter.Format "uuid") }}
+        {{ $parameter.Name | swiftName }}: UUID?
+    {{- else if eq $parameter.Type "string" }}
+        {{ $parameter.Name | swiftName }}: String?
+    {{- else }}
+        {{ $parameter.Type }} {{ $parameter.Name | swiftName }}
+    {{- end }}
+    {{- $isPreviousParam = true}}
+{{- end }}
+{{- end }}
+`
+
+const protoTemplate string = `{{- define "protoFile" }}/* Code generated by codegen/main.go. DO NOT EDIT. */
+
+import Foundation
+{{- with .SwiftProtobuf }}
+// The messages conform to the protocols protoc-gen-swift conforms its messages to, as of SwiftProtobuf {{ . }}.
+import SwiftProtobuf
+{{- end }}
+{{- range .Namespaces }}
+
+/// The types of the {{ .Package }} protocol buffers package.
+{{ access }}enum {{ .Name }} {}
+{{- end }}
+{{- if .Int64 }}
+
+/// A 64 bit integer, which the JSON mapping of protocol buffers writes as a string and reads from a string or a number.
+struct ProtoInt64<Value: FixedWidthInteger & Codable & Sendable>: Codable, Sendable {
+    let value: Value
+
+    init(_ value: Value) {
+        self.value = value
+    }
+
+    init(from decoder: Swift.Decoder) throws {
+        let container = try decoder.singleValueContainer()
+        guard let string = try? container.decode(String.self) else {
+            self.value = try container.decode(Value.self)
+            return
+        }
+        guard let value = Value(string) else {
+            throw DecodingError.dataCorruptedError(in: container, debugDescription: "Invalid 64 bit integer: \(string)")
+        }
+        self.value = value
+    }
+
+    func encode(to encoder: Swift.Encoder) throws {
+        var container = encoder.singleValueContainer()
+        try container.encode(String(value))
+    }
+}
+{{- end }}
+{{- if .Timestamps }}
+
+/// A google.protobuf.Timestamp, which the JSON mapping of protocol buffers writes as an RFC 3339 string.
+struct ProtoTimestamp: Codable, Sendable {
+    let date: Date
+
+    init(_ date: Date) {
+        self.date = date
+    }
+
+    init(from decoder: Swift.Decoder) throws {
+        let container = try decoder.singleValueContainer()
+        let string = try container.decode(String.self)
+        let formatter = ISO8601DateFormatter()
+        formatter.formatOptions = [.withInternetDateTime, .withFractionalSeconds]
+        if let date = formatter.date(from: string) {
+            self.date = date
+            return
+        }
+        formatter.formatOptions = [.withInternetDateTime]
+        guard let date = formatter.date(from: string) else {
+            throw DecodingError.dataCorruptedError(in: container, debugDescription: "Invalid timestamp: \(string)")
+        }
+        self.date = date
+    }
+
+    func encode(to encoder: Swift.Encoder) throws {
+        let formatter = ISO8601DateFormatter()
+        formatter.formatOptions = [.withInternetDateTime, .withFractionalSeconds]
+        var container = encoder.singleValueContainer()
+        try container.encode(formatter.string(from: date))
+    }
+}
+{{- end }}
+{{- range .Namespaces }}
+
+extension {{ .Name }} {
+{{ .Declarations }}
+}
+{{- end }}
+{{- with .Socket }}
+{{- template "protoSocket" . }}
+{{- end }}
+{{ end }}
+
+{{- define "protoSocket" }}
+
+/// A frame of a WebSocket.
+{{ access }}enum SocketFrame: Sendable {
+    case text(String)
+    case binary(Data)
+}
+
+/// The errors of a Socket which aren't answered by the server.
+{{ access }}enum SocketError: Error, Sendable {
+    /// The socket isn't connected.
+    case notConnected
+    /// The socket disconnected before the server answered, with the error closing it if any.
+    case disconnected(Error?)
+    /// The server didn't answer within the timeout of the socket.
+    case timedOut
+}
+
+/// Opens WebSockets and exchanges their frames on behalf of a Socket, such as to connect through another WebSocket
+/// library than URLSession.
+{{ access }}protocol SocketAdapterProtocol: AnyObject {
+    /// Called once the connection is open.
+    var onConnect: (@Sendable () -> Void)? { get set }
+    /// Called once the connection is closed, with the error closing it if any.
+    var onDisconnect: (@Sendable (Error?) -> Void)? { get set }
+    /// Called with every frame received.
+    var onReceive: (@Sendable (SocketFrame) -> Void)? { get set }
+
+    /// Opens a connection to the URL, failing it when it doesn't open within the timeout in seconds.
+    func connect(url: URL, timeout: TimeInterval)
+
+    /// Closes the connection.
+    func disconnect()
+
+    /// Sends a frame, throwing SocketError.notConnected when the connection isn't open.
+    func send(_ frame: SocketFrame) async throws
+}
+
+/// The SocketAdapterProtocol of URLSessionWebSocketTask. Servers are trusted as the system trusts them, unless a
+/// ServerTrustEvaluating such as PinnedPublicKeysTrustEvaluator evaluates them, to pin their certificates or keys.
+// Unchecked since the task is guarded by the lock and the handlers are only set before connecting.
+{{ access }}final class WebSocketAdapter: NSObject, SocketAdapterProtocol, URLSessionWebSocketDelegate, @unchecked Sendable {
+    {{ access }}var onConnect: (@Sendable () -> Void)?
+    {{ access }}var onDisconnect: (@Sendable (Error?) -> Void)?
+    {{ access }}var onReceive: (@Sendable (SocketFrame) -> Void)?
+
+    private let configuration: URLSessionConfiguration
+    private let serverTrustEvaluator: ServerTrustEvaluating?
+    private let lock = NSLock()
+    private var session: URLSession?
+    private var task: URLSessionWebSocketTask?
+
+    {{ access }}init(configuration: URLSessionConfiguration = .default, serverTrustEvaluator: ServerTrustEvaluating? = nil) {
+        self.configuration = configuration
+        self.serverTrustEvaluator = serverTrustEvaluator
+    }
+
+    {{ access }}func connect(url: URL, timeout: TimeInterval) {
+        let session = URLSession(configuration: configuration, delegate: self, delegateQueue: nil)
+        let task = session.webSocketTask(with: URLRequest(url: url, timeoutInterval: timeout))
+        lock.lock()
+        self.session = session
+        self.task = task
+        lock.unlock()
+        task.resume()
+    }
+
+    {{ access }}func disconnect() {
+        lock.lock()
+        let task = self.task
+        lock.unlock()
+        task?.cancel(with: .normalClosure, reason: nil)
+    }
+
+    {{ access }}func send(_ frame: SocketFrame) async throws {
+        lock.lock()
+        let task = self.task
+        lock.unlock()
+        guard let task, task.state == .running else {
+            throw SocketError.notConnected
+        }
+        switch frame {
+        case .text(let text):
+            try await task.send(.string(text))
+        case .binary(let data):
+            try await task.send(.data(data))
+        }
+    }
+
+    {{ access }}func urlSession(_ session: URLSession, webSocketTask: URLSessionWebSocketTask, didOpenWithProtocol protocol: String?) {
+        onConnect?()
+        receive(webSocketTask)
+    }
+
+    {{ access }}func urlSession(_ session: URLSession, webSocketTask: URLSessionWebSocketTask, didCloseWith closeCode: URLSessionWebSocketTask.CloseCode, reason: Data?) {
+        close(session, error: nil)
+    }
+
+    {{ access }}func urlSession(_ session: URLSession, task: URLSessionTask, didCompleteWithError error: Error?) {
+        close(session, error: error)
+    }
+
+    {{ access }}func urlSession(_ session: URLSession, didReceive challenge: URLAuthenticationChallenge, completionHandler: @escaping (URLSession.AuthChallengeDisposition, URLCredential?) -> Void) {
+        guard let serverTrustEvaluator else {
+            completionHandler(.performDefaultHandling, nil)
+            return
+        }
+        ServerTrustDelegate.handle(challenge, with: serverTrustEvaluator, logger: nil, completionHandler: completionHandler)
+    }
+
+    private func receive(_ task: URLSessionWebSocketTask) {
+        task.receive { [weak self] result in
+            switch result {
+            case .success(.string(let text)):
+                self?.onReceive?(.text(text))
+            case .success(.data(let data)):
+                self?.onReceive?(.binary(data))
+            case .success:
+                break
+            case .failure:
+                // The delegate reports the connection closing.
+                return
+            }
+            self?.receive(task)
+        }
+    }
+
+    /// Reports the connection of a session closing, once, and lets go of the session, which retains its delegate.
+    private func close(_ session: URLSession, error: Error?) {
+        lock.lock()
+        guard self.session === session else {
+            lock.unlock()
+            return
+        }
+        self.session = nil
+        self.task = nil
+        lock.unlock()
+        session.finishTasksAndInvalidate()
+        onDisconnect?(error)
+    }
+}
+{{- if .Error }}
+
+extension {{ .Error }}: Swift.Error {}
+{{- end }}
+
+/// A realtime socket exchanging {{ .Envelope }} messages with the server over a WebSocket. Requests wait for the
+/// envelope answering them, matched by its cid, and the envelopes the server sends unprompted are passed to onReceive.
+{{- if .MatchData }}
+/// Match data is also passed to onMatchData and to the handler of its op code.
+{{- end }}
+// Unchecked since the state of the socket is guarded by the lock and the handlers are only set before connecting.
+{{ access }}final class Socket: @unchecked Sendable {
+    {{ access }}let host: String
+    {{ access }}let port: Int
+    {{ access }}let ssl: Bool
+    /// How long to wait in seconds for the connection to open and for the server to answer a request.
+    {{ access }}let timeout: TimeInterval
+
+    /// Called once the socket is connected.
+    {{ access }}var onConnect: (@Sendable () -> Void)?
+    /// Called once the socket is disconnected, with the error disconnecting it if any.
+    {{ access }}var onDisconnect: (@Sendable (Error?) -> Void)?
+    /// Called with every envelope the server sends which doesn't answer a request, such as notifications.
+    {{ access }}var onReceive: (@Sendable ({{ .Envelope }}) -> Void)?
+    /// Called with the frames which can't be decoded as an envelope.
+    {{ access }}var onError: (@Sendable (Error) -> Void)?
+    {{- with .MatchData }}
+    /// Called with the match data the server relays, before the handler of its op code if any.
+    {{ access }}var onMatchData: (@Sendable ({{ .Data }}) -> Void)?
+    {{- end }}
+
+    private let adapter: SocketAdapterProtocol
+    private let lock = NSLock()
+    private var connected = false
+    private var connecting: CheckedContinuation<Void, Error>?
+    private var lastCid = 0
+    private var pending: [String: CheckedContinuation<{{ .Envelope }}, Error>] = [:]
+    /// The tasks sending the pending requests and then failing them once they time out.
+    private var timers: [String: Task<Void, Never>] = [:]
+    {{- with .MatchData }}
+    private var matchDataHandlers: [{{ .OpCode }}: @Sendable ({{ .Data }}) -> Void] = [:]
+    {{- end }}
+
+    {{ access }}init(host: String = "127.0.0.1", port: Int = 7350, ssl: Bool = false, timeout: TimeInterval = 10, adapter: SocketAdapterProtocol = WebSocketAdapter()) {
+        self.host = host
+        self.port = port
+        self.ssl = ssl
+        self.timeout = timeout
+        self.adapter = adapter
+        adapter.onConnect = { [weak self] in
+            self?.didConnect()
+        }
+        adapter.onDisconnect = { [weak self] error in
+            self?.didDisconnect(error)
+        }
+        adapter.onReceive = { [weak self] frame in
+            self?.didReceive(frame)
+        }
+    }
+
+    /// Whether the socket is connected.
+    {{ access }}var isConnected: Bool {
+        lock.lock()
+        defer { lock.unlock() }
+        return connected
+    }
+
+    /// The URL of the socket, authenticating with the token of a session.
+    ///
+    /// - Parameters:
+    ///   - token: The token of the session.
+    ///   - appearOnline: Whether the user appears online to the users following them.
+    ///   - language: The language of the user, such as "en".
+    {{ access }}func url(token: String, appearOnline: Bool? = nil, language: String? = nil) -> URL {
+        var components = URLComponents()
+        components.scheme = ssl ? "wss" : "ws"
+        components.host = host
+        components.port = port
+        components.path = "/ws"
+        components.queryItems = [URLQueryItem(name: "token", value: token), URLQueryItem(name: "format", value: "{{ if .Protobuf }}protobuf{{ else }}json{{ end }}")]
+        if let appearOnline {
+            components.queryItems?.append(URLQueryItem(name: "status", value: appearOnline ? "true" : "false"))
+        }
+        if let language {
+            components.queryItems?.append(URLQueryItem(name: "lang", value: language))
+        }
+        return components.url!
+    }
+
+    /// Connects the socket, returning once the connection is open.
+    ///
+    /// - Parameters:
+    ///   - token: The token of the session to authenticate with.
+    ///   - appearOnline: Whether the user appears online to the users following them.
+    ///   - language: The language of the user, such as "en".
+    {{ access }}func connect(token: String, appearOnline: Bool? = nil, language: String? = nil) async throws {
+        let url = self.url(token: token, appearOnline: appearOnline, language: language)
+        try await withCheckedThrowingContinuation { (continuation: CheckedContinuation<Void, Error>) in
+            lock.lock()
+            let previous = connecting
+            connecting = continuation
+            lock.unlock()
+            previous?.resume(throwing: CancellationError())
+            adapter.connect(url: url, timeout: timeout)
+        }
+    }
+
+    /// Disconnects the socket, failing the requests waiting for an answer.
+    {{ access }}func disconnect() {
+        adapter.disconnect()
+    }
+
+    /// Sends a message without waiting for an answer.
+    {{ access }}func send(_ message: {{ .Message }}) async throws {
+        try await adapter.send(encode({{ .Envelope }}(message: message)))
+    }
+
+    /// Sends a message and waits for the message answering it, which is nil for requests answered by an empty
+    /// envelope. Cancelling the task waiting stops waiting, throwing a CancellationError.
+    {{- if .Error }}
+    /// Throws the {{ .Error }} the server answers failed requests with.
+    {{- end }}
+    @discardableResult
+    {{ access }}func request(_ message: {{ .Message }}) async throws -> {{ .Message }}? {
+        lock.lock()
+        lastCid += 1
+        let cid = String(lastCid)
+        lock.unlock()
+        let frame = try encode({{ .Envelope }}(cid: cid, message: message))
+        let timeout = self.timeout
+        let response = try await withTaskCancellationHandler {
+            try await withCheckedThrowingContinuation { (continuation: CheckedContinuation<{{ .Envelope }}, Error>) in
+                lock.lock()
+                pending[cid] = continuation
+                // Set before unlocking, so the timer is cancelled however soon the request is answered. It doesn't
+                // retain the socket, so pending requests don't keep it alive.
+                timers[cid] = Task { [weak self] in
+                    do {
+                        try await self?.adapter.send(frame)
+                        try await Task.sleep(nanoseconds: UInt64(timeout * 1_000_000_000))
+                        self?.answer(cid, with: .failure(SocketError.timedOut))
+                    } catch {
+                        self?.answer(cid, with: .failure(error))
+                    }
+                }
+                lock.unlock()
+                // The handler ran before the request was pending if the task was already cancelled.
+                if Task.isCancelled {
+                    answer(cid, with: .failure(CancellationError()))
+                }
+            }
+        } onCancel: {
+            answer(cid, with: .failure(CancellationError()))
+        }
+        {{- if .Error }}
+        if case .{{ .ErrorCase }}(let error) = response.message {
+            throw error
+        }
+        {{- end }}
+        return response.message
+    }
+    {{- with .MatchData }}
+
+    /// Sends match state to the presences of a match, or to all of them when presences is empty.
+    ///
+    /// - Parameters:
+    ///   - matchId: The ID of the match.
+    ///   - opCode: The op code telling the receivers what the data is.
+    ///   - data: The data.
+    ///   - presences: The presences to send the data to, all of them when empty.
+    {{- if .Reliable }}
+    ///   - reliable: Whether the data is delivered reliably.
+    {{- end }}
+    {{ access }}func sendMatchState(matchId: String, opCode: {{ .OpCode }}, data: Data, presences: [{{ .Presence }}] = []{{ if .Reliable }}, reliable: Bool = true{{ end }}) async throws {
+        try await send(.{{ .SendCase }}({{ .Send }}(matchId: matchId, opCode: opCode, data: data, presences: presences{{ if .Reliable }}, reliable: reliable{{ end }})))
+    }
+
+    /// Sends match state encoded as JSON to the presences of a match, or to all of them when presences is empty.
+    {{ access }}func sendMatchState<State: Encodable>(matchId: String, opCode: {{ .OpCode }}, state: State, presences: [{{ .Presence }}] = []{{ if .Reliable }}, reliable: Bool = true{{ end }}) async throws {
+        try await sendMatchState(matchId: matchId, opCode: opCode, data: JSONEncoder().encode(state), presences: presences{{ if .Reliable }}, reliable: reliable{{ end }})
+    }
+
+    /// Handles the match data with an op code, replacing the handler of the op code if any.
+    {{ access }}func onMatchState(opCode: {{ .OpCode }}, _ handler: @escaping @Sendable ({{ .Data }}) -> Void) {
+        lock.lock()
+        matchDataHandlers[opCode] = handler
+        lock.unlock()
+    }
+
+    /// Handles the match data with an op code, decoding its data as JSON state. The data which can't be decoded is
+    /// passed to onError.
+    {{ access }}func onMatchState<State: Decodable>(opCode: {{ .OpCode }}, as type: State.Type, _ handler: @escaping @Sendable (State, {{ .Data }}) -> Void) {
+        onMatchState(opCode: opCode) { [weak self] matchData in
+            do {
+                handler(try JSONDecoder().decode(State.self, from: matchData.data), matchData)
+            } catch {
+                self?.onError?(error)
+            }
+        }
+    }
+
+    /// Stops handling the match data with an op code.
+    {{ access }}func removeMatchStateHandler(opCode: {{ .OpCode }}) {
+        lock.lock()
+        matchDataHandlers[opCode] = nil
+        lock.unlock()
+    }
+    {{- end }}
+
+    {{- if .Protobuf }}
+
+    private func encode(_ envelope: {{ .Envelope }}) throws -> SocketFrame {
+        .binary(try envelope.serializedData())
+    }
+
+    private func decode(_ frame: SocketFrame) throws -> {{ .Envelope }} {
+        switch frame {
+        case .text(let text):
+            return try {{ .Envelope }}(serializedData: Data(text.utf8))
+        case .binary(let data):
+            return try {{ .Envelope }}(serializedData: data)
+        }
+    }
+    {{- else }}
+
+    private func encode(_ envelope: {{ .Envelope }}) throws -> SocketFrame {
+        .text(String(decoding: try JSONEncoder().encode(envelope), as: UTF8.self))
+    }
+
+    private func decode(_ frame: SocketFrame) throws -> {{ .Envelope }} {
+        switch frame {
+        case .text(let text):
+            return try JSONDecoder().decode({{ .Envelope }}.self, from: Data(text.utf8))
+        case .binary(let data):
+            return try JSONDecoder().decode({{ .Envelope }}.self, from: data)
+        }
+    }
+    {{- end }}
+
+    /// Resumes the request waiting for the envelope with the cid and cancels its timer, unless it was already.
+    private func answer(_ cid: String, with result: Result<{{ .Envelope }}, Error>) {
+        lock.lock()
+        let continuation = pending.removeValue(forKey: cid)
+        let timer = timers.removeValue(forKey: cid)
+        lock.unlock()
+        timer?.cancel()
+        continuation?.resume(with: result)
+    }
+
+    private func didConnect() {
+        lock.lock()
+        connected = true
+        let continuation = connecting
+        connecting = nil
+        lock.unlock()
+        continuation?.resume()
+        onConnect?()
+    }
+
+    private func didDisconnect(_ error: Error?) {
+        lock.lock()
+        let wasConnected = connected
+        connected = false
+        let continuation = connecting
+        connecting = nil
+        let requests = pending
+        pending = [:]
+        let timers = self.timers
+        self.timers = [:]
+        lock.unlock()
+        for timer in timers.values {
+            timer.cancel()
+        }
+        continuation?.resume(throwing: SocketError.disconnected(error))
+        for request in requests.values {
+            request.resume(throwing: SocketError.disconnected(error))
+        }
+        if wasConnected {
+            onDisconnect?(error)
+        }
+    }
+
+    private func didReceive(_ frame: SocketFrame) {
+        let envelope: {{ .Envelope }}
+        do {
+            envelope = try decode(frame)
+        } catch {
+            onError?(error)
+            return
+        }
+        lock.lock()
+        let waiting = !envelope.cid.isEmpty && pending[envelope.cid] != nil
+        lock.unlock()
+        if waiting {
+            answer(envelope.cid, with: .success(envelope))
+            return
+        }
+        {{- with .MatchData }}
+        if case .{{ .DataCase }}(let matchData) = envelope.message {
+            didReceiveMatchData(matchData)
+        }
+        {{- end }}
+        onReceive?(envelope)
+    }
+    {{- with .MatchData }}
+
+    private func didReceiveMatchData(_ matchData: {{ .Data }}) {
+        lock.lock()
+        let handler = matchDataHandlers[matchData.opCode]
+        lock.unlock()
+        onMatchData?(matchData)
+        handler?(matchData)
+    }
+    {{- end }}
+}
+{{- end }}
+
+{{- define "protoEnum" }}
+{{- with .Doc }}
+{{ protoDoc . "" }}
+{{- end }}
+{{ access }}enum {{ .SwiftName }}: RawRepresentable, Codable, Hashable, Sendable {
+{{- range .Cases }}
+{{- with .Doc }}
+{{ protoDoc . "    " }}
+{{- end }}
+    case {{ .Case }}
+{{- end }}
+    /// A value added to the server after this client was generated.
+    case unrecognized(Int32)
+
+    {{ access }}init(rawValue: Int32) {
+        switch rawValue {
+        {{- range .Cases }}
+        case {{ .Number }}: self = .{{ .Case }}
+        {{- end }}
+        default: self = .unrecognized(rawValue)
+        }
+    }
+
+    {{ access }}var rawValue: Int32 {
+        switch self {
+        {{- range .Cases }}
+        case .{{ .Case }}: return {{ .Number }}
+        {{- end }}
+        case .unrecognized(let value): return value
+        }
+    }
+
+    {{ access }}init(from decoder: Swift.Decoder) throws {
+        self.init(rawValue: try decoder.singleValueContainer().decode(Int32.self))
+    }
+
+    {{ access }}func encode(to encoder: Swift.Encoder) throws {
+        var container = encoder.singleValueContainer()
+        try container.encode(rawValue)
+    }
+}
+{{- end }}
+
+{{- define "protoMessage" }}
+{{- $message := .Message }}
+{{- with $message.Doc }}
+{{ protoDoc . "" }}
+{{- end }}
+{{ access }}struct {{ $message.SwiftName }}: Codable, {{ if .Protobuf }}Hashable{{ else }}Equatable{{ end }}, Sendable
+{{- if .Protobuf }}, SwiftProtobuf.Message, SwiftProtobuf._MessageImplementationBase, SwiftProtobuf._ProtoNameProviding{{ end }} {
+{{- with .Nested }}
+{{ . }}
+{{- end }}
+{{- range $oneof := $message.Oneofs }}{{ "\n" }}
+{{- with $oneof.Doc }}
+{{ protoDoc . "    " }}
+{{- end }}
+    {{ access }}enum {{ $oneof.TypeName }}: {{ if $.Protobuf }}Hashable{{ else }}Equatable{{ end }}, Sendable {
+    {{- range $field := $oneof.Fields }}
+    {{- with $field.Doc }}
+{{ protoDoc . "        " }}
+    {{- end }}
+        case {{ $field.Property }}({{ $field.Value.Swift }})
+    {{- end }}
+    }
+{{- end }}
+{{- if $message.Properties }}{{ "\n" }}{{ end }}
+{{- range $field := $message.Fields }}
+{{- with $field.Doc }}
+{{ protoDoc . "    " }}
+{{- end }}
+    {{ access }}var {{ $field.Property }}: {{ $field.SwiftType }}
+{{- end }}
+{{- range $oneof := $message.Oneofs }}
+{{- with $oneof.Doc }}
+{{ protoDoc . "    " }}
+{{- end }}
+    {{ access }}var {{ $oneof.Property }}: {{ $oneof.TypeName }}?
+{{- end }}
+{{- if $message.Properties }}
+
+    private enum CodingKeys: String, CodingKey {
+    {{- range $field := $message.AllFields }}
+        case {{ $field.Property }} = "{{ $field.Name }}"
+    {{- end }}
+    }
+
+    {{ access }}init(
+    {{- range $i, $property := $message.Properties }}{{ if $i }},{{ end }}
+        {{ $property.Name }}: {{ $property.Type }} = {{ $property.Default }}
+    {{- end }}
+    ) {
+    {{- range $property := $message.Properties }}
+        self.{{ $property.Name }} = {{ $property.Name }}
+    {{- end }}
+    }
+    {{- if .Protobuf }}
+    {{- with index $message.Properties 0 }}
+
+    {{ access }}init() {
+        self.init({{ .Name }}: {{ .Default }})
+    }
+    {{- end }}
+    {{- end }}
+
+    {{ access }}init(from decoder: Swift.Decoder) throws {
+        let container = try decoder.container(keyedBy: CodingKeys.self)
+    {{- range $field := $message.Fields }}
+        self.{{ $field.Property }} = {{ $field.Decoding }}
+    {{- end }}
+    {{- range $oneof := $message.Oneofs }}
+        self.{{ $oneof.Property }} = nil
+        {{- range $field := $oneof.Fields }}
+        if let value = try container.decodeIfPresent({{ $field.CodedType }}.self, forKey: .{{ $field.Property }}) {
+            self.{{ $oneof.Property }} = .{{ $field.Property }}({{ $field.CaseValue }})
+        }
+        {{- end }}
+    {{- end }}
+    }
+
+    {{ access }}func encode(to encoder: Swift.Encoder) throws {
+        var container = encoder.container(keyedBy: CodingKeys.self)
+    {{- range $field := $message.Fields }}
+        {{ $field.Encoding }}
+    {{- end }}
+    {{- range $oneof := $message.Oneofs }}
+        switch {{ $oneof.Property }} {
+        {{- range $field := $oneof.Fields }}
+        case .{{ $field.Property }}(let value):
+            try container.encode({{ $field.CaseEncoding }}, forKey: .{{ $field.Property }})
+        {{- end }}
+        case nil:
+            break
+        }
+    {{- end }}
+    }
+{{- else }}
+{{- if .Protobuf }}
+
+    // The unknown fields aren't coded as JSON.
+    private enum CodingKeys: CodingKey {}
+{{- end }}
+
+    {{ access }}init() {}
+{{- end }}
+{{- if .Protobuf }}
+
+    {{ access }}static let protoMessageName = "{{ $message.FullName }}"
+
+    {{ access }}static let _protobuf_nameMap: SwiftProtobuf._NameMap = [
+    {{- range $field := $message.AllFields }}
+        {{ $field.NameMapEntry }},
+    {{- end }}
+    ]
+
+    /// The fields of the binary messages which this client doesn't know, such as the ones newer servers add, kept
+    /// so they're sent back as is.
+    {{ access }}var unknownFields = SwiftProtobuf.UnknownStorage()
+
+    {{ access }}mutating func decodeMessage<D: SwiftProtobuf.Decoder>(decoder: inout D) throws {
+        while let fieldNumber = try decoder.nextFieldNumber() {
+            switch fieldNumber {
+            {{- range $field := $message.Fields }}
+            case {{ $field.Number }}:
+                {{- range $field.WireDecoding }}
+                {{ . }}
+                {{- end }}
+            {{- end }}
+            {{- range $oneof := $message.Oneofs }}
+            {{- range $field := $oneof.Fields }}
+            case {{ $field.Number }}:
+                var value: {{ $field.Value.WireSwiftType }}?
+                try decoder.decodeSingular{{ $field.Value.Wire }}Field(value: &value)
+                if let value {
+                    if self.{{ $oneof.Property }} != nil {
+                        try decoder.handleConflictingOneOf()
+                    }
+                    self.{{ $oneof.Property }} = .{{ $field.Property }}({{ $field.CaseFromWire }})
+                }
+            {{- end }}
+            {{- end }}
+            default:
+                break
+            }
+        }
+    }
+
+    {{ access }}func traverse<V: SwiftProtobuf.Visitor>(visitor: inout V) throws {
+    {{- range $field := $message.Fields }}
+        {{- range $field.WireTraversal }}
+        {{ . }}
+        {{- end }}
+    {{- end }}
+    {{- range $oneof := $message.Oneofs }}
+        switch self.{{ $oneof.Property }} {
+        {{- range $field := $oneof.Fields }}
+        case .{{ $field.Property }}(let value)?:
+            try visitor.visitSingular{{ $field.Value.Wire }}Field(value: {{ $field.CaseToWire }}, fieldNumber: {{ $field.Number }})
+        {{- end }}
+        case nil:
+            break
+        }
+    {{- end }}
+        try unknownFields.traverse(visitor: &visitor)
+    }
+{{- end }}
+}
+{{- end }}
+`
+
+func convertRefToClassName(input string) (className string) {
+	// "#/definitions/Foo" (Swagger 2.0) and "#/components/schemas/Foo" (OpenAPI 3.x) both name "Foo"
+	cleanRef := input[strings.LastIndex(input, "/")+1:]
+	className = typeName(cleanRef)
+	return
+}
+
+// The affixes of generated type names, set by the -strip-type-prefix, -type-prefix and -type-suffix flags.
+var (
+	strippedTypePrefix string
+	typePrefix         string
+	typeSuffix         string
+)
+
+// typeNameOverrides maps definition names, or the type names generated for them, onto the names the -names file
+// gives their types instead.
+var typeNameOverrides = map[string]string{}
+
+// typeName converts a definition name into the name of the Swift type generated for it.
+func typeName(defname string) string {
+	if name, ok := typeNameOverrides[defname]; ok {
+		return name
+	}
+
+	name := strings.Title(defname)
+	if stripped := strings.TrimPrefix(name, strippedTypePrefix); stripped != "" && unicode.IsLetter(rune(stripped[0])) {
+		name = stripped
+	}
+	name = typePrefix + name + typeSuffix
+	if override, ok := typeNameOverrides[name]; ok {
+		return override
+	}
+	return name
+}
+
+// camelToSnake converts a camel or Pascal case string into snake case.
+func camelToSnake(input string) (output string) {
+	for k, v := range input {
+		if unicode.IsUpper(v) {
+			formatString := "%c"
+
+			if k != 0 {
+				formatString = "_" + formatString
+			}
+
+			output += fmt.Sprintf(formatString, unicode.ToLower(v))
+		} else {
+			output += string(v)
+		}
+	}
+
+	return
+}
+
+func snakeToCamel(input string) (snakeToCamel string) {
+	isToUpper := false
+	for k, v := range input {
+		if k == 0 {
+			snakeToCamel = strings.ToLower(string(input[0]))
+		} else {
+			if isToUpper {
+				snakeToCamel += strings.ToUpper(string(v))
+				isToUpper = false
+			} else {
+				if v == '_' {
+					isToUpper = true
+				} else {
+					snakeToCamel += string(v)
+				}
+			}
+		}
+
+	}
+	return
+}
+
+func snakeToPascal(input string) (output string) {
+	isToUpper := false
+	for k, v := range input {
+		if k == 0 {
+			output = strings.ToUpper(string(input[0]))
+		} else {
+			if isToUpper {
+				output += strings.ToUpper(string(v))
+				isToUpper = false
+			} else {
+				if v == '_' {
+					isToUpper = true
+				} else {
+					output += string(v)
+				}
+			}
+		}
+	}
+	return
+}
+
+func isPropertyEnum(string) (output string) {
+	return
+}
+
+// pascalToCamel converts a Pascal case string to a camel case string.
+func pascalToCamel(input string) (camelCase string) {
+	if input == "" {
+		return ""
+	}
+
+	camelCase = strings.ToLower(string(input[0]))
+	camelCase += string(input[1:])
+	return camelCase
+}
+
+// platformVersionPattern matches the versions accepted by @available, such as 15 or 12.0.1.
+var platformVersionPattern = regexp.MustCompile(`^\d+(\.\d+){0,2}$`)
+
+// ParameterDoc documents a parameter of a generated operation method.
+type ParameterDoc struct {
+	Name        string
+	Description string
+}
+
+// parameterDocs documents the parameters of the method generated for an operation, named as the template names
+// them. Parameters without a description are described by where they are sent.
+func (s *Schema) parameterDocs(operation *Operation) []ParameterDoc {
+	var docs []ParameterDoc
+	if len(s.operationCredentials(operation)) > 0 {
+		docs = append(docs, ParameterDoc{"credentials", "The credentials the request is authorized with."})
+	}
+
+	for _, parameter := range operation.Parameters {
+		description := stripNewlines(strings.TrimSpace(parameter.Description))
+		if description == "" {
+			description = fmt.Sprintf("The %s %s parameter.", parameter.Name, parameter.In)
+			if parameter.In == "body" {
+				description = "The body of the request."
+			}
+		}
+
+		switch {
+		case isFileParameter(parameter) || parameter.In == "formData" && parameter.Type == "file":
+			name := swiftName(snakeToCamel(parameter.Name))
+			docs = append(docs,
+				ParameterDoc{name, description},
+				ParameterDoc{name + "Filename", "The file name " + name + " is sent with."},
+				ParameterDoc{name + "MimeType", "The content type of " + name + "."})
+		case parameter.In == "header" || parameter.In == "cookie":
+			docs = append(docs, ParameterDoc{headerParameterName(parameter.Name), description})
+		case parameter.In == "formData" || parameter.In == "query" && parameter.Type == "array":
+			docs = append(docs, ParameterDoc{swiftName(snakeToCamel(parameter.Name)), description})
+		default:
+			docs = append(docs, ParameterDoc{swiftName(parameter.Name), description})
+		}
+	}
+
+	return docs
+}
+
+// operationArguments forwards the parameters of the method generated for an operation to a call of the method,
+// labelled as the template labels them.
+func (s *Schema) operationArguments(operation *Operation) string {
+	var arguments []string
+	if len(s.operationCredentials(operation)) > 0 {
+		arguments = append(arguments, "credentials: credentials")
+	}
+
+	for _, parameter := range operation.Parameters {
+		var names []string
+		switch {
+		case isFileParameter(parameter) || parameter.In == "formData" && parameter.Type == "file":
+			name := swiftName(snakeToCamel(parameter.Name))
+			names = []string{name, name + "Filename", name + "MimeType"}
+		case parameter.In == "body" && parameter.Schema.Type == "string":
+			arguments = append(arguments, "string: "+swiftName(parameter.Name))
+		case parameter.In == "header" || parameter.In == "cookie":
+			names = []string{headerParameterName(parameter.Name)}
+		case parameter.In == "formData" || parameter.In == "query" && parameter.Type == "array":
+			names = []string{swiftName(snakeToCamel(parameter.Name))}
+		default:
+			names = []string{swiftName(parameter.Name)}
+		}
+		for _, name := range names {
+			arguments = append(arguments, name+": "+name)
+		}
+	}
+
+	return strings.Join(arguments, ", ")
+}
+
+// completionType is the completion handler type of the Objective-C variant of an operation, empty when the
+// variant isn't generated since Objective-C is off or the operation streams its response.
+func (s *Schema) completionType(operation *Operation) string {
+	success := successResponse(operation)
+	if !s.Options.ObjC || success.Stream || success.Events {
+		return ""
+	}
+	if success.ReturnType == "Void" {
+		return "(Error?) -> Void"
+	}
+	return "(" + strings.TrimSuffix(success.ReturnType, "?") + "?, Error?) -> Void"
+}
+
+// isDownload reports whether an operation returning binary data gets a variant streaming it into a file.
+func isDownload(operation *Operation) bool {
+	success := successResponse(operation)
+	return success.Media == "binary" && !success.Stream && !success.Events
+}
+
+// hasDownloads reports whether any operation gets a variant streaming its response into a file.
+func (s *Schema) hasDownloads() bool {
+	for _, operation := range s.operations() {
+		if isDownload(operation.Operation) {
+			return true
+		}
+	}
+	return false
+}
+
+// publisherType is the output type of the Combine publisher variant of an operation, empty when the variant isn't
+// generated since Combine is off or the operation streams its response.
+func (s *Schema) publisherType(operation *Operation) string {
+	success := successResponse(operation)
+	if !s.Options.Combine || success.Stream || success.Events {
+		return ""
+	}
+	return success.ReturnType
+}
+
+// resultType is the success type of the Result variant of an operation, empty when the variant isn't generated since
+// -result-variants is off or the operation streams its response.
+func (s *Schema) resultType(operation *Operation) string {
+	success := successResponse(operation)
+	if !s.Options.ResultVariants || success.Stream || success.Events {
+		return ""
+	}
+	return success.ReturnType
+}
+
+// Pagination is how a list operation pages through its results, sending the cursor of a page to get the next one.
+type Pagination struct {
+	Model    string // the page returned by the operation
+	Cursor   string // the Swift property of the page holding the cursor of the next page
+	Optional bool   // the cursor property is optional
+	Typed    bool   // the cursor is a Cursor of the page, with -typed-cursors
+}
+
+// pagination returns the pagination of a list operation taking an optional cursor query parameter and returning a
+// model with a cursor or nextCursor, or nil for the operations which don't page.
+func (s *Schema) pagination(operation *Operation) *Pagination {
+	success := successResponse(operation)
+	if success.Model == "" || success.ReturnType != success.Model || success.Media != "json" {
+		return nil
+	}
+	hasCursor := false
+	for _, parameter := range operation.Parameters {
+		if parameter.In == "query" && parameter.Name == "cursor" && parameter.Type == "string" && !parameter.Required {
+			hasCursor = true
+		}
+	}
+	if !hasCursor {
+		return nil
+	}
+
+	for defname, definition := range s.Definitions {
+		if typeName(defname) != success.Model {
+			continue
+		}
+		for _, name := range []string{"nextCursor", "cursor"} {
+			if cursor, ok := stringProperty(definition, name); ok {
+				return &Pagination{
+					Model:    success.Model,
+					Cursor:   swiftName(cursor),
+					Optional: isOptional(definition, cursor, definition.Properties[cursor]),
+					Typed:    s.Options.TypedCursors,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// cursorType is the Swift type of the cursor parameter of a list operation with -typed-cursors, or an empty string
+// when the cursor is a String.
+func (s *Schema) cursorType(operation *Operation) string {
+	if pagination := s.pagination(operation); pagination != nil && pagination.Typed {
+		return "Cursor<" + pagination.Model + ">"
+	}
+	return ""
+}
+
+// paginatedModels returns the pagination of every model a list operation pages through, once per model.
+func (s *Schema) paginatedModels() []Pagination {
+	var paginations []Pagination
+	seen := make(map[string]bool)
+	for _, operation := range s.operations() {
+		if pagination := s.pagination(operation.Operation); pagination != nil && !seen[pagination.Model] {
+			seen[pagination.Model] = true
+			paginations = append(paginations, *pagination)
+		}
+	}
+	return paginations
+}
+
+// returnsDoc documents what the method generated for an operation returns, or an empty string for Void.
+func returnsDoc(operation *Operation) string {
+	success := successResponse(operation)
+	if success.ReturnType == "Void" {
+		return ""
+	}
+
+	description := operation.Responses.Ok.Description
+	if len(success.Statuses) > 0 {
+		description = operation.Responses.Status[success.Statuses[0].Status].Description
+	}
+	if description = stripNewlines(strings.TrimSpace(description)); description == "" {
+		description = "The response of the server."
+	}
+	return description
+}
+
+// throwsDoc documents the errors thrown by the method generated for an operation.
+func throwsDoc(operation *Operation) string {
+	doc := "An `ApiResponseError` when the server responds with an error status"
+	var typed []string
+	for _, response := range errorResponses(operation) {
+		if response.Model != "" {
+			typed = append(typed, fmt.Sprintf("`%sResponseError` for %s", response.Model, response.Status))
+		}
+	}
+	if len(typed) > 0 {
+		doc += ", such as " + strings.Join(typed, ", ")
+	}
+	return doc + ", or the error of the adapter when the request fails."
+}
+
+// successResponse collects the 2xx responses of an operation. The model of the lowest status with a body is
+// returned, which becomes optional when another 2xx response, such as 204, has no body.
+func successResponse(operation *Operation) SuccessResponse {
+	var success SuccessResponse
+	for _, response := range statusResponses(operation, '2', true) {
+		if response.Model != "" && success.Model == "" {
+			success.Model = response.Model
+			success.Media = response.Media
+		}
+		if response.Status != "200" || response.Media != "json" {
+			success.Switch = true
+		}
+		success.Statuses = append(success.Statuses, response)
+	}
+
+	if success.Statuses == nil && operation.Responses.Ok.Schema.Ref != "" {
+		success.Model = convertRefToClassName(operation.Responses.Ok.Schema.Ref)
+		success.Media = "json"
+	}
+
+	ok := operation.Responses.Ok.Schema
+	if result := ok.Properties["result"]; strings.HasPrefix(ok.Title, "Stream result of") && result.Ref != "" {
+		// grpc-gateway server streaming, every line is a {"result": ..., "error": ...} object.
+		success.Model = convertRefToClassName(result.Ref)
+		success.Stream = true
+		success.Wrapped = true
+	} else if hasExtension(operation.Extensions, "x-stream") && success.Model != "" {
+		success.Stream = true
+	}
+	if success.Stream {
+		success.ReturnType = fmt.Sprintf("AsyncThrowingStream<%s, Error>", success.Model)
+		return success
+	}
+
+	events := hasExtension(operation.Extensions, "x-sse")
+	for _, mediaType := range responseMediaTypes(operation, operation.Responses.Ok) {
+		events = events || strings.HasPrefix(mediaType, "text/event-stream")
+	}
+	if events {
+		// Server-Sent Events carry JSON messages in their data, or plain text without a documented model.
+		success.Events = true
+		success.Model = "String"
+		success.Media = "text"
+		if ok.Ref != "" {
+			success.Model = convertRefToClassName(ok.Ref)
+			success.Media = "json"
+		}
+		success.ReturnType = fmt.Sprintf("AsyncStream<%s>", success.Model)
+		return success
+	}
+
+	success.ReturnType = "Void"
+	if success.Model != "" {
+		success.ReturnType = success.Model
+		for _, response := range success.Statuses {
+			if response.Model == "" {
+				success.ReturnType += "?"
+				break
+			}
+		}
+	}
+
+	return success
+}
+
+// mediaKind tells how a response body of the given media types is decoded. JSON is preferred and assumed when
+// no media type is documented, other text types are returned as a String and anything else as raw Data.
+func mediaKind(mediaTypes []string) string {
+	if len(mediaTypes) == 0 {
+		return "json"
+	}
+
+	sorted := append([]string(nil), mediaTypes...)
+	sort.Strings(sorted)
+	for _, mediaType := range sorted {
+		mediaType = strings.TrimSpace(strings.SplitN(mediaType, ";", 2)[0])
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			return "json"
+		}
+	}
+
+	if strings.HasPrefix(sorted[0], "text/") {
+		return "text"
+	}
+	return "binary"
+}
+
+// responseMediaTypes lists the media types of a response, falling back to the produces list of the operation.
+func responseMediaTypes(operation *Operation, response Response) []string {
+	if len(response.Content) == 0 {
+		return operation.Produces
+	}
+
+	mediaTypes := make([]string, 0, len(response.Content))
+	for mediaType := range response.Content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	return mediaTypes
+}
+
+// inheritProduces copies the document wide Swagger 2.0 produces list into the operations which do not override it.
+func inheritProduces(s *Schema) {
+	for _, path := range s.Paths {
+		for _, operation := range path {
+			if operation.Produces == nil {
+				operation.Produces = s.Produces
+			}
+		}
+	}
+}
+
+// errorResponses lists the 4xx and 5xx responses of an operation which document a body model, ordered by status.
+func errorResponses(operation *Operation) []StatusResponse {
+	return append(statusResponses(operation, '4', false), statusResponses(operation, '5', false)...)
+}
+
+// statusResponses lists the responses of an operation whose status starts with the class digit, ordered by
+// status. Responses without a body model are only listed when withoutModel is set.
+func statusResponses(operation *Operation, class byte, withoutModel bool) []StatusResponse {
+	var responses []StatusResponse
+	for status, response := range operation.Responses.Status {
+		if len(status) != 3 || status[0] != class {
+			continue
+		}
+
+		pattern := status
+		if strings.ToUpper(status[1:]) == "XX" {
+			// OpenAPI 3.x status ranges such as "4XX".
+			pattern = fmt.Sprintf("%c00...%c99", status[0], status[0])
+		} else if _, err := strconv.Atoi(status); err != nil {
+			continue
+		}
+
+		media := mediaKind(responseMediaTypes(operation, response))
+		if response.Schema.Type == "string" && (response.Schema.Format == "byte" || response.Schema.Format == "binary") {
+			// Binary payloads are passed through untouched whatever the documented media type.
+			media = "binary"
+		}
+
+		model := ""
+		switch {
+		case media == "text" && status[0] == '2':
+			model = "String"
+		case media == "binary" && status[0] == '2':
+			model = "Data"
+		case response.Schema.Ref != "":
+			model = convertRefToClassName(response.Schema.Ref)
+		}
+		if model == "" && !withoutModel {
+			continue
+		}
+
+		responses = append(responses, StatusResponse{
+			Status: status,
+			Case:   pattern,
+			Model:  model,
+			Media:  media,
+		})
+	}
+
+	// Exact status codes have to come before the ranges which contain them.
+	sort.Slice(responses, func(i, j int) bool {
+		iRange, jRange := strings.Contains(responses[i].Case, "..."), strings.Contains(responses[j].Case, "...")
+		if iRange != jRange {
+			return jRange
+		}
+		return responses[i].Status < responses[j].Status
+	})
+
+	return responses
+}
+
+// hasFormData reports whether an operation sends a multipart/form-data body.
+func hasFormData(operation *Operation) bool {
+	for _, param := range operation.Parameters {
+		if param.In == "formData" {
+			return true
+		}
+	}
+	return false
+}
+
+// isFileParameter reports whether a parameter outside of a form is raw file content sent as the request body.
+func isFileParameter(param Parameter) bool {
+	if param.In == "formData" {
+		return false
+	}
+	return param.Type == "file" || param.Schema.Type == "file" ||
+		(param.Schema.Type == "string" && param.Schema.Format == "binary")
+}
+
+// headerParameterName converts a header name such as "X-Request-ID" into a Swift parameter name such as "xRequestId".
+func headerParameterName(header string) (output string) {
+	words := strings.FieldsFunc(header, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for k, word := range words {
+		word = strings.ToLower(word)
+		if k > 0 {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		output += word
+	}
+	return swiftName(output)
+}
+
+// enumCaseDocs maps enum values to their documentation, parsed from the " - VALUE: doc" lines protoc-gen-openapiv2
+// writes into the description of an enum. Lines which don't start a case continue the previous one, and values
+// without a line are left undocumented.
+func enumCaseDocs(description string, values []string) map[string]string {
+	known := make(map[string]bool, len(values))
+	for _, value := range values {
+		known[value] = true
+	}
+
+	docs := make(map[string]string, len(values))
+	current := ""
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "- ") {
+			parts := strings.SplitN(strings.TrimPrefix(line, "- "), ":", 2)
+			if value := strings.TrimSpace(parts[0]); known[value] {
+				current = value
+				if len(parts) == 2 {
+					docs[value] = strings.TrimSpace(parts[1])
+				}
+				continue
+			}
+			// Values documented but missing from the enum don't run into the doc of the previous case.
+			if len(parts) == 2 && isEnumValueName(strings.TrimSpace(parts[0])) {
+				current = ""
+				continue
+			}
+		}
+
+		if current != "" && line != "" {
+			docs[current] = strings.TrimSpace(docs[current] + " " + line)
+		}
+	}
+
+	return docs
+}
+
+// isEnumValueName reports whether a name is spelled like a protobuf enum value, such as APPLE_APP_STORE.
+func isEnumValueName(name string) bool {
+	return name != "" && strings.Trim(name, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_") == ""
+}
+
+// enumCaseName converts an enum value, usually an UPPER_SNAKE_CASE protobuf name, into a Swift case name.
+func enumCaseName(value string) string {
+	if strings.ToUpper(value) == value {
+		value = strings.ToLower(value)
+	}
+
+	name := snakeToCamel(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, value))
+	return swiftName(name)
+}
+
+// swiftKeywords are the reserved words of Swift which can't name a property, parameter or enum case as is.
+var swiftKeywords = map[string]bool{
+	"associatedtype": true, "class": true, "deinit": true, "enum": true, "extension": true, "fileprivate": true,
+	"func": true, "import": true, "init": true, "inout": true, "internal": true, "let": true, "open": true,
+	"operator": true, "private": true, "precedencegroup": true, "protocol": true, "public": true, "rethrows": true,
+	"static": true, "struct": true, "subscript": true, "typealias": true, "var": true,
+	"break": true, "case": true, "catch": true, "continue": true, "default": true, "defer": true, "do": true,
+	"else": true, "fallthrough": true, "for": true, "guard": true, "if": true, "in": true, "repeat": true,
+	"return": true, "switch": true, "throw": true, "where": true, "while": true,
+	"Any": true, "as": true, "await": true, "false": true, "is": true, "nil": true, "self": true, "Self": true,
+	"super": true, "throws": true, "true": true, "try": true, "Type": true, "Protocol": true,
+}
+
+// swiftName turns a schema name into a Swift identifier. Characters identifiers can't hold are dropped, a leading
+// digit is prefixed with an underscore and reserved words are suffixed with one, as "default" is with "default_".
+func swiftName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		return "_" + name
+	}
+	if swiftKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+func stripNewlines(input string) string {
+	return strings.Replace(input, "\n", " ", -1)
+}
+
+func stripOperationPrefix(input string) string {
+	return strings.Replace(input, "Nakama_", "", 1)
+}
+
+// primitiveType maps a primitive schema type to its Swift type, returning an empty string for other types.
+func primitiveType(schemaType string) string {
+	switch schemaType {
+	case "integer":
+		return "Int"
+	case "number":
+		return "Double"
+	case "boolean":
+		return "Bool"
+	case "string":
+		return "String"
+	default:
+		return ""
+	}
+}
+
+// formattedType maps a primitive schema type to its Swift type, using the format to pick sized numeric types.
+// grpc-gateway documents protobuf 64-bit integers as strings with an int64 or uint64 format, those stay String
+// since their JSON values are strings.
+func formattedType(schemaType, format string) string {
+	if schemaType == "integer" {
+		switch format {
+		case "int32":
+			return "Int32"
+		case "int64":
+			return "Int64"
+		case "uint32":
+			return "UInt32"
+		case "uint64":
+			return "UInt64"
+		}
+	}
+	if schemaType == "number" && format == "float" {
+		return "Float"
+	}
+	if schemaType == "string" && format == "uuid" {
+		return "UUID"
+	}
+	return primitiveType(schemaType)
+}
+
+// isIntegerType reports whether a Swift type is one of the integer types primitive schemas map to.
+func isIntegerType(swiftType string) bool {
+	switch swiftType {
+	case "Int", "Int32", "Int64", "UInt32", "UInt64":
+		return true
+	default:
+		return false
+	}
+}
+
+// stringCodings maps the generated StringCoding types onto the Swift type they decode.
+var stringCodings = map[string]string{
+	"DateTimeCoding": "Date",
+	"DateCoding":     "Date",
+	"Base64Coding":   "Data",
+	"DurationCoding": "TimeInterval",
+}
+
+// formatCodings maps string formats onto the StringCoding decoding them, the -format-coding flag adds rules.
+var formatCodings = map[string]string{
+	"date-time": "DateTimeCoding",
+	"date":      "DateCoding",
+	"byte":      "Base64Coding",
+	"duration":  "DurationCoding",
+}
+
+// addFormatCodings parses format=coding rules, such as "google-duration=DurationCoding", into formatCodings.
+func addFormatCodings(rules string) error {
+	for _, rule := range strings.Split(rules, ",") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("invalid format coding rule %q, expected format=coding", rule)
+		}
+		format, coding := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if _, known := stringCodings[coding]; !known && coding != "" {
+			return fmt.Errorf("unknown coding %q in rule %q", coding, rule)
+		}
+		formatCodings[format] = coding
+	}
+
+	return nil
+}
+
+// propertyCoding returns the StringCoding used to decode a string property with a format, or an empty string
+// when the property is decoded as is.
+func propertyCoding(property ObjectProperty) string {
+	if property.Type != "string" || len(property.AnyOf) > 0 {
+		return ""
+	}
+
+	return formatCodings[property.Format]
+}
+
+// swiftType resolves the Swift type of a property, without its optionality.
+func swiftType(property ObjectProperty) string {
+	if len(property.AnyOf) > 0 {
+		return "AnyCodable"
+	}
+
+	if coding := propertyCoding(property); coding != "" {
+		return stringCodings[coding]
+	}
+
+	switch property.Type {
+	case "integer", "number", "boolean", "string":
+		return formattedType(property.Type, property.Format)
+	case "array":
+		return "[" + itemType(property.Items) + "]"
+	case "object":
+		return "[String: " + valueType(property.AdditionalProperties) + "]"
+	default:
+		return convertRefToClassName(property.Ref)
+	}
+}
+
+// itemType returns the Swift type of the elements of an array, resolving nested arrays and maps recursively.
+func itemType(items Items) string {
+	if items.Type == "array" && items.Items != nil {
+		return "[" + itemType(*items.Items) + "]"
+	}
+	if items.Type == "object" && items.Ref == "" {
+		var values AdditionalProperties
+		if items.AdditionalProperties != nil {
+			values = *items.AdditionalProperties
+		}
+		return "[String: " + valueType(values) + "]"
+	}
+	if primitive := formattedType(items.Type, items.Format); primitive != "" {
+		return primitive
+	}
+	return convertRefToClassName(items.Ref)
+}
+
+// valueType returns the Swift type of the values of a map, resolving nested arrays and maps recursively. Free-form
+// maps, which don't constrain their values, hold AnyCodable values.
+func valueType(values AdditionalProperties) string {
+	if values.Type == "array" && values.Items != nil {
+		return "[" + itemType(*values.Items) + "]"
+	}
+	if values.Type == "object" && values.AdditionalProperties != nil {
+		return "[String: " + valueType(*values.AdditionalProperties) + "]"
+	}
+	if primitive := formattedType(values.Type, values.Format); primitive != "" {
+		return primitive
+	}
+	if values.Ref == "" {
+		return "AnyCodable"
+	}
+	return convertRefToClassName(values.Ref)
+}
+
+// isOptional reports whether a property is generated as a Swift optional. Definitions with a `required` list
+// make every other property optional, definitions without one keep the historical mapping where scalars are
+// always present.
+func isOptional(definition ObjectDefinition, name string, property ObjectProperty) bool {
+	if property.Nullable || property.Wrapper {
+		return true
+	}
+
+	if definition.Required != nil {
+		return !isRequired(definition, name)
+	}
+
+	switch property.Type {
+	case "integer", "number", "string":
+		return false
+	case "array":
+		return primitiveType(property.Items.Type) == ""
+	default:
+		return true
+	}
+}
+
+// schemaDefault renders the `default` of a property as a Swift literal, or an empty string if the property has
+// no default or it can't be expressed as a literal of the property type.
+func schemaDefault(property ObjectProperty) string {
+	if property.Default == nil || propertyCoding(property) != "" {
+		return ""
+	}
+
+	switch property.Type {
+	case "array":
+		return swiftLiteral(property.Default, formattedType(property.Items.Type, property.Items.Format))
+	case "object":
+		return swiftLiteral(property.Default, primitiveType(property.AdditionalProperties.Type))
+	default:
+		return swiftLiteral(property.Default, formattedType(property.Type, property.Format))
+	}
+}
+
+// swiftLiteral renders a generic JSON value as a Swift literal of the given primitive element type.
+func swiftLiteral(value interface{}, elementType string) string {
+	switch v := value.(type) {
+	case string:
+		if elementType != "String" {
+			// int64 values are encoded as strings by grpc-gateway.
+			if _, err := strconv.ParseInt(v, 10, 64); err != nil || !isIntegerType(elementType) {
+				return ""
+			}
+			return v
+		}
+		return swiftQuote(v)
+	case bool:
+		if elementType != "Bool" {
+			return ""
+		}
+		return strconv.FormatBool(v)
+	case float64:
+		switch {
+		case isIntegerType(elementType):
+			if v < 0 && strings.HasPrefix(elementType, "UInt") {
+				return ""
+			}
+			return strconv.FormatInt(int64(v), 10)
+		case elementType == "Double" || elementType == "Float":
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			literal := swiftLiteral(item, elementType)
+			if literal == "" {
+				return ""
+			}
+			items = append(items, literal)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "[:]"
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(v))
+		for _, key := range keys {
+			literal := swiftLiteral(v[key], elementType)
+			if literal == "" {
+				return ""
+			}
+			items = append(items, swiftQuote(key)+": "+literal)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return ""
+	}
+}
+
+// swiftQuote renders a Swift string literal.
+func swiftQuote(value string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for _, c := range value {
+		switch {
+		case c == '"' || c == '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteRune(c)
+		case c == '\n':
+			quoted.WriteString("\\n")
+		case c == '\r':
+			quoted.WriteString("\\r")
+		case c == '\t':
+			quoted.WriteString("\\t")
+		case c < ' ':
+			fmt.Fprintf(&quoted, "\\u{%x}", c)
+		default:
+			quoted.WriteRune(c)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// extension returns the value of a vendor extension, or nil if it isn't set.
+func extension(extensions map[string]interface{}, name string) interface{} {
+	return extensions[name]
+}
+
+// hasExtension reports whether a vendor extension is set to anything other than false or null.
+func hasExtension(extensions map[string]interface{}, name string) bool {
+	value, ok := extensions[name]
+	if !ok || value == nil {
+		return false
+	}
+
+	if flag, ok := value.(bool); ok {
+		return flag
+	}
+
+	return true
+}
+
+// storageName returns the name a property is stored under. Deprecated properties are stored privately so the
+// generated code itself doesn't trigger deprecation warnings.
+func storageName(name string, property ObjectProperty) string {
+	name = swiftName(name)
+
+	if property.Deprecated {
+		return "_" + name
+	}
+
+	return name
+}
+
+// BuilderField is a property set through the Builder of a model.
+type BuilderField struct {
+	Name     string // the name of the init parameter and the setter
+	Property string // the name of the property in the schema
+	Type     string
+	Default  string // the default of the init parameter, empty if build throws when the property isn't set
+}
+
+// builderFields lists the properties of a definition as the Builder of its model sets them, in the order of the
+// parameters of the model init.
+func builderFields(definition ObjectDefinition) []BuilderField {
+	var fields []BuilderField
+	for _, name := range propertyNames(definition) {
+		property := definition.Properties[name]
+		field := BuilderField{Name: swiftName(name), Property: name, Type: initParameter(definition, name, property)}
+		if parts := strings.SplitN(field.Type, " = ", 2); len(parts) == 2 {
+			field.Type, field.Default = parts[0], parts[1]
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// jsonKeys maps the property names which don't convert to their JSON key onto it, the -json-key flag adds rules.
+var jsonKeys = map[string]string{}
+
+// addJSONKeys parses property=key rules, such as "userID=user_id", into jsonKeys.
+func addJSONKeys(rules string) error {
+	for _, rule := range strings.Split(rules, ",") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return fmt.Errorf("invalid JSON key rule %q, expected property=key", rule)
+		}
+		jsonKeys[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return nil
+}
+
+// jsonKey returns the JSON key of a property, converting camelCase names to snake_case unless -json-keys keeps
+// the names of the schema.
+func (s *Schema) jsonKey(name string) string {
+	if key, ok := jsonKeys[name]; ok {
+		return key
+	}
+	if s.Options.JSONKeys == "schema" {
+		return name
+	}
+	return camelToSnake(name)
+}
+
+// propertyNames lists the names of the properties of a definition in the order the template ranges over them.
+func propertyNames(definition ObjectDefinition) []string {
+	names := make([]string, 0, len(definition.Properties))
+	for name := range definition.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isSensitive reports whether the value of a property is redacted from descriptions, either because it is marked
+// with x-sensitive or because its name matches the -sensitive pattern.
+func (s *Schema) isSensitive(name string, property ObjectProperty) bool {
+	if _, ok := property.Extensions["x-sensitive"]; ok {
+		return hasExtension(property.Extensions, "x-sensitive")
+	}
+	return s.Options.Sensitive != nil && s.Options.Sensitive.MatchString(name)
+}
+
+// isRequired reports whether a property is listed in the `required` list of its definition.
+func isRequired(definition ObjectDefinition, name string) bool {
+	for _, required := range definition.Required {
+		if required == name {
+			return true
+		}
+	}
+	return false
+}
+
+// initParameter returns the Swift type and default value of a property in the memberwise initializer.
+func initParameter(definition ObjectDefinition, name string, property ObjectProperty) string {
+	parameter := swiftType(property)
+	if (definition.Required != nil || property.Nullable || property.Wrapper) && isOptional(definition, name, property) {
+		parameter += "?"
+		if defaultValue(property) == "" {
+			return parameter + " = nil"
+		}
+	}
+
+	if value := defaultValue(property); value != "" {
+		parameter += " = " + value
+	}
+
+	return parameter
+}
+
+// defaultValue returns the Swift initializer of a property, either its schema default or an empty collection,
+// or an empty string if there is none.
+func defaultValue(property ObjectProperty) string {
+	if value := schemaDefault(property); value != "" {
+		return value
+	}
+
+	switch {
+	case property.Type == "array" && primitiveType(property.Items.Type) == "":
+		return "[]"
+	case property.Type == "object":
+		return "[:]"
+	default:
+		return ""
+	}
+}
+
+// oneOfCases builds the enum cases for a oneOf definition, matching each referenced schema with the
+// discriminator values mapped to it. Schemas without a mapping are selected by their definition name.
+func oneOfCases(defname string, definition ObjectDefinition) (cases []OneOfCase) {
+	for _, schema := range definition.OneOf {
+		if schema.Ref == "" {
+			// Unions of primitive types are decoded by trying each type in turn.
+			if primitive := primitiveType(schema.Type); primitive != "" {
+				cases = append(cases, OneOfCase{Name: swiftName(pascalToCamel(primitive)), Type: primitive})
+				continue
+			}
+
+			fmt.Fprintf(os.Stderr, "Skipping inline oneOf schema in %s, only $ref and primitive schemas are supported\n", defname)
+			continue
+		}
+
+		name := schema.Ref[strings.LastIndex(schema.Ref, "/")+1:]
+		oneOfCase := OneOfCase{
+			Name: swiftName(pascalToCamel(strings.Title(name))),
+			Type: convertRefToClassName(schema.Ref),
+		}
+		for value, ref := range definition.Discriminator.Mapping {
+			if ref == schema.Ref || ref == name {
+				oneOfCase.Values = append(oneOfCase.Values, value)
+			}
+		}
+		if len(oneOfCase.Values) == 0 {
+			oneOfCase.Values = []string{name}
+		}
+		sort.Strings(oneOfCase.Values)
+
+		cases = append(cases, oneOfCase)
+	}
+
+	return
+}
+
+// exampleOf renders the first example of a property as JSON, or an empty string if it has none.
+func exampleOf(property ObjectProperty) string {
+	example := property.Example
+	if len(property.Examples) > 0 {
+		example = property.Examples[0]
+	}
+	if example == nil {
+		return ""
+	}
+
+	encoded, err := json.Marshal(example)
+	if err != nil {
+		return ""
+	}
+
+	return string(encoded)
+}
+
+func descriptionOrTitle(description string, title string) string {
+	if description != "" {
+		return description
+	}
+
+	return title
+}
+
+// camelToPascal converts a string from camel case to Pascal case.
+func camelToPascal(camelCase string) (pascalCase string) {
+
+	if len(camelCase) <= 0 {
+		return ""
+	}
+
+	pascalCase = strings.ToUpper(string(camelCase[0])) + camelCase[1:]
 	return
 }
 
-func snakeToPascal(input string) (output string) {
-	isToUpper := false
-	for k, v := range input {
-		if k == 0 {
-			output = strings.ToUpper(string(input[0]))
-		} else {
-			if isToUpper {
-				output += strings.ToUpper(string(v))
-				isToUpper = false
-			} else {
-				if v == '_' {
-					isToUpper = true
-				} else {
-					output += string(v)
-				}
+func main() {
+	// Argument flags
+	var output = flag.String("output", "", "The output for generated code.")
+	var format = flag.String("format", "", "The input format, either json or yaml. Detected from the file extension when empty.")
+	var splitTags = flag.Bool("split-tags", false, "Generate a client class per OpenAPI tag and an ApiClient facade grouping them.")
+	var formatCoding = flag.String("format-coding", "", "Comma separated format=coding rules decoding string formats with a generated coding, e.g. google-duration=DurationCoding. An empty coding keeps the format a String.")
+	var valueTypes = flag.Bool("value-types", false, "Generate models as structs with let properties instead of protocols and structs with var properties.")
+	var modelsOutput = flag.String("models-output", "", "The output for the generated models, written apart from the client.")
+	var clientOutput = flag.String("client-output", "", "The output for the generated client, written apart from the models.")
+	var mockOutput = flag.String("mock-output", "", "The output for a MockHttpAdapter recording requests and answering them with canned responses, to unit test code using the client.")
+	var alamofireOutput = flag.String("alamofire-output", "", "The output for an AlamofireHttpAdapter sending requests through an Alamofire Session.")
+	var split = flag.Bool("split", false, "Write every model and the client into separate files under the -output directory, along with a manifest.json listing them.")
+	var wrapNamespace = flag.Bool("wrap-namespace", false, "Nest the generated types in an enum named after the namespace argument. Nested protocols need Swift 5.10.")
+	var noProtocols = flag.Bool("no-protocols", false, "Generate only the model structs, without a protocol declaring the properties of each.")
+	var minIOS = flag.String("min-ios", "", "The minimum iOS version, such as 15.0, the async API is declared @available on.")
+	var minMacOS = flag.String("min-macos", "", "The minimum macOS version, such as 12.0, the async API is declared @available on.")
+	var minTvOS = flag.String("min-tvos", "", "The minimum tvOS version the async API is declared @available on.")
+	var minWatchOS = flag.String("min-watchos", "", "The minimum watchOS version the async API is declared @available on.")
+	var deprecatedOperations = flag.String("deprecated-operations", "keep", "What is done with deprecated operations: keep (generate them with the rest), skip (leave them out) or isolate (generate them into an extension of the client).")
+	var allowlistFile = flag.String("allowlist", "", "A json or yaml file listing the only operations, by OperationId, and definitions generated, e.g. {\"operations\": [\"SatoriAuthenticate\"], \"definitions\": [\"apiEvent\"]}. The definitions they reference are generated as well.")
+	var namesFile = flag.String("names", "", "A json or yaml file naming operations, by OperationId or method name, and definitions, by name or type name, e.g. {\"operations\": {\"SatoriAuthenticateRefresh\": \"refreshSession\"}, \"definitions\": {\"apiSession\": \"Session\"}}.")
+	var includeOps = flag.String("include-ops", "", "A regular expression matching the OperationIds of the only operations generated, e.g. Authenticate|Storage|Rpc.")
+	var excludeOps = flag.String("exclude-ops", "", "A regular expression matching the OperationIds of operations left out of the client.")
+	var builders = flag.Bool("builders", false, "Generate a Builder with chainable setters and a build method in every model, for request bodies too large to initialize at once.")
+	var resultVariants = flag.Bool("result-variants", false, "Generate a variant of every operation which doesn't stream its response returning a Result<T, ApiResponseError> instead of throwing, named as the operation suffixed by Result.")
+	var combine = flag.Bool("combine", false, "Generate an AnyPublisher variant of every operation which doesn't stream its response, named as the operation suffixed by Publisher, for Combine and SwiftUI code.")
+	var objc = flag.Bool("objc", false, "Generate models, Credentials and clients as @objcMembers NSObject classes, with a completion handler variant of every operation. Members Objective-C can't represent, such as enums and optional numbers, stay Swift only.")
+	var hashable = flag.Bool("hashable", false, "Generate Equatable and Hashable conformances for every model.")
+	var sensitive = flag.String("sensitive", "(?i)token|password|secret", "A regular expression matching the names of properties whose values are redacted from model descriptions. Overridden per property by x-sensitive.")
+	var accessLevel = flag.String("access-level", "public", "The access level of the generated API: public, package or internal. ApiClient and the clients of tags stay internal like the HttpAdapterProtocol they take, which the SDK wrapping them provides.")
+	var stripTypePrefix = flag.String("strip-type-prefix", "", "A prefix, such as Api, removed from the names of generated types.")
+	var typeNamePrefix = flag.String("type-prefix", "", "A prefix added to the names of generated types, after -strip-type-prefix is removed.")
+	var typeNameSuffix = flag.String("type-suffix", "", "A suffix added to the names of generated types.")
+	var enumEncoding = flag.String("enum-encoding", "string", "How enums are encoded: string (their names), int (their positions) or either (decoded from both, encoded as names). Overridden per enum by x-enum-encoding.")
+	var jsonKeyStyle = flag.String("json-keys", "snake", "How the JSON keys of model properties are derived from their names: snake (camelCase names are sent as snake_case, as grpc-gateway does with proto names) or schema (the names as is).")
+	var jsonKeyRules = flag.String("json-key", "", "Comma separated property=key rules naming the JSON keys of irregular property names, e.g. userID=user_id.")
+	var wellKnownRules = flag.String("well-known-type", "", "Comma separated name=type[:format] rules generating protobuf well-known types as a primitive schema, e.g. int64value=integer:int64. An empty type generates the definition as is.")
+	var clientVersion = flag.String("client-version", generatorVersion, "The SDK version sent in the User-Agent header of every request, e.g. nakama-swift/1.2.0.")
+	var sessionType = flag.String("session-type", "", "The name of a generated session type decoding the user and expiry claims of its tokens, with an initializer from every model holding a token and a refresh token, e.g. Session.")
+	var sessionRefresh = flag.String("session-refresh", "", "The OperationId of the operation refreshing sessions, e.g. SatoriAuthenticateRefresh, generating a SessionManager which refreshes the token of the session before it expires.")
+	var typedCursors = flag.Bool("typed-cursors", false, "Type the cursor parameters of list operations as a Cursor of the page they list, so the cursor of one listing can't be passed to another.")
+	var circuitBreaker = flag.Bool("circuit-breaker", false, "Generate a CircuitBreakerAdapter wrapping an adapter, which fails requests fast for a cooldown once failureThreshold consecutive requests failed to reach the server, then probes it with a single request.")
+	var socket = flag.Bool("socket", false, "Generate, from .proto inputs declaring an Envelope message, a Socket exchanging envelopes with the server over a WebSocket through a SocketAdapterProtocol, URLSessionWebSocketTask by default.")
+	var socketFormat = flag.String("socket-format", "json", "The format of the messages generated from .proto inputs exchanged by the Socket, json or protobuf. protobuf makes the messages SwiftProtobuf messages and exchanges them as binary protocol buffers.")
+	var packageResolved = flag.String("package-resolved", "Package.resolved", "The Package.resolved pinning the SwiftProtobuf version the messages of -socket-format protobuf are generated for, versions from 1.20 before 2.0 are supported.")
+	var outbox = flag.Bool("outbox", false, "Generate an Outbox the clients queue the requests of write methods failing while offline in, persisted and replayed in order by replayOutbox, with a hook resolving the requests the server rejects. Operations marked x-outbox: false are never queued. With -retries, writes failing while offline are queued on their first attempt rather than retried.")
+	var retries = flag.Bool("retries", false, "Retry requests failing with a transient error: idempotent methods with the RetryPolicy of the client, operations marked x-idempotent: false never and those with an x-retry object, e.g. {\"maxRetries\": 5}, with their own policy. POST and PUT operations marked x-idempotent: true send an Idempotency-Key header kept across the retries.")
+	var retryPoliciesFile = flag.String("retry-policies", "", "A json or yaml file overriding the x-idempotent and x-retry extensions of operations, by OperationId or method name, e.g. {\"SatoriEvent\": {\"idempotent\": false}, \"SatoriGetFlags\": {\"maxRetries\": 5, \"baseDelayMs\": 200}}. Implies -retries.")
+	var anyOf = flag.String("anyof", "union", "How anyOf schemas are generated: first (the first schema's type), any (AnyCodable) or union (an enum of every schema).")
+	flag.Parse()
+
+	strippedTypePrefix, typePrefix, typeSuffix = *stripTypePrefix, *typeNamePrefix, *typeNameSuffix
+
+	if err := addFormatCodings(*formatCoding); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := addWellKnownTypes(*wellKnownRules); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := addJSONKeys(*jsonKeyRules); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	inputs := flag.Args()
+	if len(inputs) < 1 {
+		fmt.Printf("No input file found: %s\n\n", inputs)
+		fmt.Println("openapi-gen [flags] inputs... [namespace]")
+		flag.PrintDefaults()
+		return
+	}
+
+	// Every input is a schema file except for an optional trailing namespace.
+	var namespace (string) = ""
+	if len(inputs) > 1 && formatFromExtension(inputs[len(inputs)-1]) == "" {
+		if len(inputs[len(inputs)-1]) <= 0 {
+			fmt.Println("Empty Namespace provided.")
+			return
+		}
+
+		namespace = inputs[len(inputs)-1]
+		inputs = inputs[:len(inputs)-1]
+	}
+
+	inputFile := inputs[0]
+	// .proto inputs generate the types of their messages instead of a client.
+	if formatFromExtension(inputFile) == "proto" {
+		for _, input := range inputs {
+			if formatFromExtension(input) != "proto" {
+				fmt.Println("Protocol buffers inputs can't be mixed with OpenAPI documents.")
+				return
+			}
+		}
+		if *socketFormat != "json" && *socketFormat != "protobuf" {
+			fmt.Printf("Unknown socket format: %s\n", *socketFormat)
+			return
+		}
+		options := ProtoOptions{AccessLevel: *accessLevel, Socket: *socket, Protobuf: *socketFormat == "protobuf", PackageResolved: *packageResolved}
+		if err := writeProtoFile(inputs, *output, options); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+	documents := make([]interface{}, 0, len(inputs))
+	for _, input := range inputs {
+		content, err := os.ReadFile(input)
+		if err != nil {
+			fmt.Printf("Unable to read file: %s\n", err)
+			return
+		}
+
+		inputFormat := *format
+		if inputFormat == "" {
+			inputFormat = formatFromExtension(input)
+		}
+		if inputFormat == "" {
+			inputFormat = "json"
+		}
+
+		document, err := decodeDocument(content, inputFormat)
+		if err != nil {
+			fmt.Printf("Unable to decode input file %s : %s\n", input, err)
+			return
+		}
+		if err := resolveExternalRefs(input, document); err != nil {
+			fmt.Printf("Unable to resolve references in %s : %s\n", input, err)
+			return
+		}
+		documents = append(documents, document)
+	}
+
+	document, err := mergeDocuments(inputs, documents)
+	if err != nil {
+		fmt.Printf("Unable to merge input files: %s\n", err)
+		return
+	}
+
+	schema, err := decodeSchema(document)
+	if err != nil {
+		fmt.Printf("Unable to decode input file %s : %s\n", inputFile, err)
+		return
+	}
+	schema.Namespace = namespace
+	schema.Options.SplitTags = *splitTags
+	schema.Options.EnumEncoding = *enumEncoding
+	schema.Options.ValueTypes = *valueTypes
+	schema.Options.Hashable = *hashable
+	schema.Options.NoProtocols = *noProtocols
+	schema.Options.WrapNamespace = *wrapNamespace
+	schema.Options.ObjC = *objc
+	schema.Options.Combine = *combine
+	schema.Options.ResultVariants = *resultVariants
+	schema.Options.Builders = *builders
+	schema.Options.ClientVersion = *clientVersion
+	schema.Options.SessionType = *sessionType
+	schema.Options.TypedCursors = *typedCursors
+	schema.Options.Outbox = *outbox
+	schema.Options.CircuitBreaker = *circuitBreaker
+	schema.Options.SessionRefresh = *sessionRefresh
+	schema.Options.Retries = *retries || *retryPoliciesFile != ""
+	if *objc && (*valueTypes || *hashable) {
+		fmt.Println("Objective-C classes can't be combined with -value-types or -hashable.")
+		return
+	}
+	if *wrapNamespace && namespace == "" {
+		fmt.Println("A namespace is needed to wrap the generated types.")
+		return
+	}
+	for _, platform := range []struct{ name, version string }{{"iOS", *minIOS}, {"macOS", *minMacOS}, {"tvOS", *minTvOS}, {"watchOS", *minWatchOS}} {
+		if platform.version == "" {
+			continue
+		}
+		if !platformVersionPattern.MatchString(platform.version) {
+			fmt.Printf("Invalid %s version: %s\n", platform.name, platform.version)
+			return
+		}
+		schema.Options.Platforms = append(schema.Options.Platforms, platform.name+" "+platform.version)
+	}
+	switch *accessLevel {
+	case "public", "package", "internal":
+		schema.Options.AccessLevel = *accessLevel
+	default:
+		fmt.Printf("Unknown access level: %s\n", *accessLevel)
+		return
+	}
+	if schema.Options.Sensitive, err = regexp.Compile(*sensitive); err != nil {
+		fmt.Printf("Invalid sensitive property pattern: %s\n", err)
+		return
+	}
+	var include, exclude *regexp.Regexp
+	if *includeOps != "" {
+		if include, err = regexp.Compile(*includeOps); err != nil {
+			fmt.Printf("Invalid included operations pattern: %s\n", err)
+			return
+		}
+	}
+	if *excludeOps != "" {
+		if exclude, err = regexp.Compile(*excludeOps); err != nil {
+			fmt.Printf("Invalid excluded operations pattern: %s\n", err)
+			return
+		}
+	}
+	switch *jsonKeyStyle {
+	case "snake", "schema":
+		schema.Options.JSONKeys = *jsonKeyStyle
+	default:
+		fmt.Printf("Unknown JSON key style: %s\n", *jsonKeyStyle)
+		return
+	}
+	switch *deprecatedOperations {
+	case "keep", "skip", "isolate":
+		schema.Options.DeprecatedOperations = *deprecatedOperations
+	default:
+		fmt.Printf("Unknown deprecated operations handling: %s\n", *deprecatedOperations)
+		return
+	}
+	if !isEnumEncoding(*enumEncoding) {
+		fmt.Printf("Unknown enum encoding: %s\n", *enumEncoding)
+		return
+	}
+
+	normalizeOpenAPI3(schema)
+	filterOperations(schema, include, exclude)
+	if *allowlistFile != "" {
+		var allowlist Allowlist
+		if err := loadConfigFile(*allowlistFile, &allowlist); err != nil {
+			fmt.Printf("Unable to read the allowlist %s : %s\n", *allowlistFile, err)
+			return
+		}
+		if err := applyAllowlist(schema, &allowlist); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if schema.Options.DeprecatedOperations == "skip" {
+		dropDeprecatedOperations(schema)
+	}
+	if *namesFile != "" {
+		var overrides NameOverrides
+		if err := loadConfigFile(*namesFile, &overrides); err != nil {
+			fmt.Printf("Unable to read the name overrides %s : %s\n", *namesFile, err)
+			return
+		}
+		if err := applyNameOverrides(schema, &overrides); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if *retryPoliciesFile != "" {
+		var overrides map[string]RetryOverride
+		if err := loadConfigFile(*retryPoliciesFile, &overrides); err != nil {
+			fmt.Printf("Unable to read the retry policies %s : %s\n", *retryPoliciesFile, err)
+			return
+		}
+		if err := applyRetryOverrides(schema, overrides); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	inheritProduces(schema)
+	schema.BasePath = strings.TrimRight(schema.BasePath, "/")
+	inlineWellKnownTypes(schema)
+	generateBodyDefinitionFromSchema(schema)
+	aliasMapDefinitions(schema)
+	if err := applyAnyOfStrategy(schema, *anyOf); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := schema.checkCollisions(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if _, err := schema.sessionRefresh(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	recursive := recursiveDefinitions(schema)
+	fmap := template.FuncMap{
+		"snakeToCamel":      snakeToCamel,
+		"camelToSnake":      camelToSnake,
+		"cleanRef":          convertRefToClassName,
+		"typeName":          typeName,
+		"definitionContext": schema.definitionContext,
+		"generatorVersion": func() string {
+			return generatorVersion
+		},
+		"availability": func() string {
+			if len(schema.Options.Platforms) == 0 {
+				return ""
+			}
+			return "@available(" + strings.Join(schema.Options.Platforms, ", ") + ", *)"
+		},
+		"builderFields":      builderFields,
+		"jsonKey":            schema.jsonKey,
+		"swiftName":          swiftName,
+		"operationName":      operationName,
+		"clientOperations":   schema.clientOperations,
+		"deprecatedGroup":    schema.deprecatedGroup,
+		"parameterDocs":      schema.parameterDocs,
+		"operationArguments": schema.operationArguments,
+		"completionType":     schema.completionType,
+		"publisherType":      schema.publisherType,
+		"isDownload":         isDownload,
+		"hasDownloads":       schema.hasDownloads,
+		"resultType":         schema.resultType,
+		"pagination":         schema.pagination,
+		"cursorType":         schema.cursorType,
+		"paginatedModels":    schema.paginatedModels,
+		"returnsDoc":         returnsDoc,
+		"throwsDoc":          throwsDoc,
+		"isRefToEnum": func(ref string) bool {
+			// swagger schema definition keys have inconsistent casing
+			var camelOk bool
+			var pascalOk bool
+			var enums []string
+
+			asCamel := pascalToCamel(ref)
+			if _, camelOk = schema.Definitions[asCamel]; camelOk {
+				enums = schema.Definitions[asCamel].Enum
+			}
+
+			asPascal := camelToPascal(ref)
+			if _, pascalOk = schema.Definitions[asPascal]; pascalOk {
+				enums = schema.Definitions[asPascal].Enum
+			}
+
+			if !pascalOk && !camelOk {
+				fmt.Printf("no definition found: %v", ref)
+				return false
+			}
+
+			return len(enums) > 0
+		},
+		"pascalToCamel":       pascalToCamel,
+		"headerParameterName": headerParameterName,
+		"primitiveType":       primitiveType,
+		"formattedType":       formattedType,
+		"snakeToPascal":       snakeToPascal,
+		"stripNewlines":       stripNewlines,
+		"title":               strings.Title,
+		"uppercase":           strings.ToUpper,
+		"camelToPascal":       camelToPascal,
+		"enumCaseDocs":        enumCaseDocs,
+		"propertyNames":       propertyNames,
+		"isSensitive":         schema.isSensitive,
+		"access": func() string {
+			// internal is the default, so it is left implicit.
+			if schema.Options.AccessLevel == "internal" {
+				return ""
+			}
+			return schema.Options.AccessLevel + " "
+		},
+		"enumCaseName":         enumCaseName,
+		"enumEncoding":         schema.enumEncoding,
+		"stripOperationPrefix": stripOperationPrefix,
+		"descriptionOrTitle":   descriptionOrTitle,
+		"exampleOf":            exampleOf,
+		"oneOfCases":           oneOfCases,
+		"swiftType":            swiftType,
+		"isOptional":           isOptional,
+		"defaultValue":         defaultValue,
+		"initParameter":        initParameter,
+		"isRequired":           isRequired,
+		"schemaDefault":        schemaDefault,
+		"storageName":          storageName,
+		"extension":            extension,
+		"hasExtension":         hasExtension,
+		"operations":           schema.operations,
+		"tagGroups":            schema.tagGroups,
+		"hasFormData":          hasFormData,
+		"securityCredentials":  schema.securityCredentials,
+		"operationCredentials": schema.operationCredentials,
+		"queuesOffline":        schema.queuesOffline,
+		"retryPolicy":          schema.retryPolicy,
+		"idempotencyKey":       schema.idempotencyKey,
+		"sessionRefresh":       schema.sessionRefresh,
+		"sessionModels":        schema.sessionModels,
+		"oauth2Flows":          schema.oauth2Flows,
+		"usesCookies":          schema.usesCookies,
+		"collectionSeparator":  collectionSeparator,
+		"propertyCoding":       propertyCoding,
+		"isRecursive": func(defname string) bool {
+			return recursive[defname]
+		},
+		"usesCoding": func(coding string) bool {
+			for _, def := range schema.Definitions {
+				for _, property := range def.Properties {
+					if used := propertyCoding(property); used != "" && (coding == "" || used == coding) {
+						return true
+					}
+				}
+			}
+			return false
+		},
+		"swiftQuote":      swiftQuote,
+		"errorResponses":  errorResponses,
+		"successResponse": successResponse,
+		"errorModels": func() []string {
+			var models []string
+			seen := make(map[string]bool)
+			for _, operation := range schema.operations() {
+				for _, response := range errorResponses(operation.Operation) {
+					if !seen[response.Model] {
+						seen[response.Model] = true
+						models = append(models, response.Model)
+					}
+				}
+			}
+			sort.Strings(models)
+			return models
+		},
+		"isFileParameter": isFileParameter,
+		"needsStreaming": func() bool {
+			for _, operation := range schema.operations() {
+				if successResponse(operation.Operation).Stream {
+					return true
+				}
+			}
+			return false
+		},
+		"needsEvents": func() bool {
+			for _, operation := range schema.operations() {
+				if successResponse(operation.Operation).Events {
+					return true
+				}
+			}
+			return false
+		},
+		"needsMultipart": func() bool {
+			for _, path := range schema.Paths {
+				for _, operation := range path {
+					if hasFormData(operation) {
+						return true
+					}
+				}
+			}
+			return false
+		},
+		"needsAnyCodable": func() bool {
+			for _, def := range schema.Definitions {
+				if strings.Contains(def.Alias, "AnyCodable") {
+					return true
+				}
+				for _, property := range def.Properties {
+					if strings.Contains(swiftType(property), "AnyCodable") {
+						return true
+					}
+				}
+			}
+			return false
+		},
+	}
+
+	tmpl, err := template.New(inputFile).Funcs(fmap).Parse(codeTemplate)
+	if err != nil {
+		panic(err)
+	}
+
+	for name, path := range map[string]string{"mockFile": *mockOutput, "alamofireFile": *alamofireOutput} {
+		if len(path) > 0 {
+			if err := writeTemplateFile(tmpl, schema, name, path); err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
+	}
+
+	if len(*modelsOutput) > 0 || len(*clientOutput) > 0 {
+		if err := writeSeparateFiles(tmpl, schema, *modelsOutput, *clientOutput); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	if *split {
+		if len(*output) < 1 {
+			fmt.Println("An output directory is needed to split the generated code.")
+			return
+		}
+		if err := writeSplitFiles(tmpl, schema, *output); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	var generated bytes.Buffer
+	if err := tmpl.Execute(&generated, schema); err != nil {
+		fmt.Println(err)
+		return
+	}
+	code := formatCode(generated.Bytes(), schema)
+
+	if len(*output) < 1 {
+		os.Stdout.Write(code)
+		return
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Printf("Unable to create file: %s\n", err)
+		return
+	}
+	defer f.Close()
+
+	writer := bufio.NewWriter(f)
+	writer.Write(code)
+	writer.Flush()
+}
+
+type Schema struct {
+	Namespace string
+	Options   Options `json:"-"`
+	// OpenAPI is set for OpenAPI 3.x documents, Swagger 2.0 documents use "swagger" instead.
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string
+		Version string
+	}
+	Paths       map[string]map[string]*Operation
+	Definitions map[string]ObjectDefinition
+	Produces    []string // used only by Swagger 2.0 documents
+	BasePath    string   // used only by Swagger 2.0 documents
+	Security    []map[string][]string
+	// SecurityDefinitions also holds the OpenAPI 3.x security schemes once the schema is normalized.
+	SecurityDefinitions map[string]SecurityScheme
+	// used only by OpenAPI 3.x documents
+	Servers []struct {
+		Url string
+	}
+	Components struct {
+		Schemas         map[string]ObjectDefinition
+		SecuritySchemes map[string]SecurityScheme
+	}
+}
+
+// SecurityScheme is a Swagger 2.0 security definition or an OpenAPI 3.x security scheme.
+type SecurityScheme struct {
+	Type             string // "basic", "apiKey", "oauth2" or the OpenAPI 3.x "http" and "openIdConnect"
+	Scheme           string // used with type "http"
+	Name             string // used with type "apiKey"
+	In               string // used with type "apiKey"
+	Description      string
+	Flow             string // used with type "oauth2", one of "implicit", "password", "application" or "accessCode"
+	AuthorizationUrl string // used with type "oauth2"
+	TokenUrl         string // used with type "oauth2"
+	RefreshUrl       string // used with type "oauth2", the token URL is used when it is not set
+	Flows            struct {
+		Implicit          *OAuthFlow
+		Password          *OAuthFlow
+		ClientCredentials *OAuthFlow
+		AuthorizationCode *OAuthFlow
+	} // used only by OpenAPI 3.x documents
+}
+
+// OAuthFlow is an OpenAPI 3.x OAuth2 flow.
+type OAuthFlow struct {
+	AuthorizationUrl string
+	TokenUrl         string
+	RefreshUrl       string
+}
+
+// OAuth2Flow is an OAuth2 security scheme for which token helpers are generated.
+type OAuth2Flow struct {
+	Credential       Credential
+	AuthorizationUrl string // set for the authorization code flow
+	TokenUrl         string
+	RefreshUrl       string
+}
+
+// Credential is a security scheme as it is exposed on the generated Credentials.
+type Credential struct {
+	Name        string // the name of the security scheme
+	Property    string // the Credentials property holding the secret
+	Kind        string // one of "basic", "bearer", "header", "query" or "cookie"
+	Key         string // the header, query item or cookie name of the "header", "query" and "cookie" kinds
+	Description string
+}
+
+// Options are the generator flags which change the generated code.
+type Options struct {
+	SplitTags bool
+	// WrapNamespace nests the generated types in an enum named after the namespace.
+	WrapNamespace bool
+	// NoProtocols generates models without the protocols declaring their properties.
+	NoProtocols bool
+	// AccessLevel is the Swift access modifier of the generated API, public, package or internal.
+	AccessLevel string
+	// Sensitive matches the names of the properties redacted from descriptions unless x-sensitive says otherwise.
+	Sensitive *regexp.Regexp
+	// Hashable adds Equatable and Hashable conformances to the models.
+	Hashable bool
+	// ValueTypes generates models as structs with constant properties and no protocols.
+	ValueTypes bool
+	// EnumEncoding is how enums are sent unless a definition overrides it with x-enum-encoding.
+	EnumEncoding string
+	// Platforms are the minimum versions, such as "iOS 15.0", the async API is declared available on.
+	Platforms []string
+	// Builders adds a Builder with chainable setters to every model.
+	Builders bool
+	// JSONKeys is how the JSON keys of properties are derived from their names, snake or schema.
+	JSONKeys string
+	// DeprecatedOperations is what is done with deprecated operations, keep, skip or isolate them into extensions.
+	DeprecatedOperations string
+	// ObjC generates NSObject classes and completion handler variants of the operations for Objective-C.
+	ObjC bool
+	// Combine generates a Combine publisher variant of the operations.
+	Combine bool
+	// ResultVariants generates a variant of the operations returning a Result instead of throwing.
+	ResultVariants bool
+	// ClientVersion is the SDK version the User-Agent header names.
+	ClientVersion string
+	// SessionType is the name of a generated session decoding the claims of its tokens, none when empty.
+	SessionType string
+	// SessionRefresh is the OperationId of the operation a generated SessionManager refreshes sessions through.
+	SessionRefresh string
+	// TypedCursors types the cursors of list operations as a Cursor of the page they list.
+	TypedCursors bool
+	// CircuitBreaker generates a CircuitBreakerAdapter failing requests fast during server outages.
+	CircuitBreaker bool
+	// Outbox generates an Outbox queueing the write requests failing while offline, to replay them once online.
+	Outbox bool
+	// Retries generates a RetryPolicy retrying the requests of idempotent operations and those declaring one.
+	Retries bool
+}
+
+// OperationContext is a single operation together with the path and method it is served at.
+type OperationContext struct {
+	Url       string
+	Method    string
+	Operation *Operation
+}
+
+// TagGroup is the set of operations generated into the client class of a tag.
+type TagGroup struct {
+	Name       string
+	ClassName  string
+	Property   string
+	Operations []OperationContext
+	Deprecated []OperationContext // the deprecated operations when they're isolated into an extension
+}
+
+type Operation struct {
+	Summary     string
+	Description string
+	OperationId string
+	Tags        []string
+	Produces    []string // used only by Swagger 2.0 documents
+	Deprecated  bool
+	Extensions  map[string]interface{} `json:"-"`
+	Responses   Responses
+	Parameters  []Parameter
+	RequestBody *RequestBody          // used only by OpenAPI 3.x documents
+	Security    []map[string][]string // nil when the document wide security applies
+}
+
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Type        string   // used with primitives
+	Items       struct { // used with type "array"
+		Type string
+	}
+	Format           string                 // used with type "boolean"
+	CollectionFormat string                 // used with type "array"
+	Style            string                 // used only by OpenAPI 3.x documents
+	Explode          *bool                  // used only by OpenAPI 3.x documents
+	Schema           ObjectSchema           `json:"schema"`
+	Extensions       map[string]interface{} `json:"-"`
+}
+
+type Responses struct {
+	Ok     Response            `json:"200"`
+	Status map[string]Response `json:"-"` // every response keyed by its status code
+}
+
+// SuccessResponse describes how the 2xx responses of an operation map onto the Swift return type.
+type SuccessResponse struct {
+	Model      string           // the model returned by the operation, empty for Void
+	Media      string           // how the body is decoded, one of "json", "text" or "binary"
+	ReturnType string           // the Swift return type of the operation
+	Statuses   []StatusResponse // the explicit 2xx responses, Model is empty for responses without a body
+	Switch     bool             // the status has to be inspected to decode the response
+	Stream     bool             // the body is a stream of newline-delimited JSON messages
+	Wrapped    bool             // every streamed message is wrapped in a grpc-gateway result object
+	Events     bool             // the body is a Server-Sent Events stream
+}
+
+// StatusResponse is a documented response of an operation.
+type StatusResponse struct {
+	Status string
+	Case   string // the Swift switch pattern matching the status
+	Model  string
+	Media  string // how the body is decoded, one of "json", "text" or "binary"
+}
+
+type Response struct {
+	Description string
+	Schema      ObjectSchema
+	Content     map[string]MediaType // used only by OpenAPI 3.x documents
+}
+
+type RequestBody struct {
+	Description string
+	Required    bool
+	Content     map[string]MediaType
+}
+
+type MediaType struct {
+	Schema ObjectSchema
+}
+
+type ObjectSchema struct {
+	Type       string
+	Ref        string `json:"$ref"`
+	Format     string
+	Items      Items // used with type "array"
+	Properties map[string]struct {
+		Type        string
+		Ref         string `json:"$ref"`
+		Format      string
+		Items       Items
+		Description string
+	}
+	Required    []string
+	Title       string
+	Description string
+}
+
+type ObjectDefinition struct {
+	Properties map[string]ObjectProperty
+	Required   []string
+
+	Enum        []string
+	Description string
+	// used only by maps, which have no properties
+	AdditionalProperties *AdditionalProperties
+	// used only by enums
+	Title string
+	// used only by polymorphic definitions
+	OneOf         []ObjectSchema
+	AnyOf         []ObjectSchema
+	Discriminator Discriminator
+	// Alias is the Swift type a definition is generated as a typealias of.
+	Alias      string                 `json:"-"`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+type Discriminator struct {
+	PropertyName string
+	Mapping      map[string]string // discriminator value to $ref
+}
+
+// OneOfCase is a single case of the Swift enum generated for a oneOf definition.
+type OneOfCase struct {
+	Name   string
+	Type   string
+	Values []string // discriminator values which select this case
+}
+
+type ObjectProperty struct {
+	Type                 string
+	Ref                  string `json:"$ref"` // used with object
+	Items                Items
+	AdditionalProperties AdditionalProperties
+	Format               string // used with type "boolean"
+	Description          string
+	Title                string // used by enums
+	Nullable             bool
+	Wrapper              bool `json:"-"` // inlined from a protobuf wrapper type, so it's optional whether required or not
+	Deprecated           bool
+	Default              interface{}
+	AnyOf                []ObjectSchema
+	Const                interface{}
+	Example              interface{}
+	Examples             []interface{}
+	Extensions           map[string]interface{} `json:"-"`
+}
+
+// The x- vendor extensions of operations, parameters, definitions and properties are collected into their
+// Extensions so the template can query them.
+
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	type operation Operation
+	if err := json.Unmarshal(data, (*operation)(o)); err != nil {
+		return err
+	}
+
+	var err error
+	o.Extensions, err = vendorExtensions(data)
+	return err
+}
+
+func (r *Responses) UnmarshalJSON(data []byte) error {
+	type responses Responses
+	if err := json.Unmarshal(data, (*responses)(r)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	r.Status = make(map[string]Response)
+	for status, field := range fields {
+		if strings.HasPrefix(status, "x-") {
+			continue
+		}
+
+		var response Response
+		if err := json.Unmarshal(field, &response); err != nil {
+			return err
+		}
+		r.Status[status] = response
+	}
+
+	return nil
+}
+
+func (p *Parameter) UnmarshalJSON(data []byte) error {
+	type parameter Parameter
+	if err := json.Unmarshal(data, (*parameter)(p)); err != nil {
+		return err
+	}
+
+	var err error
+	p.Extensions, err = vendorExtensions(data)
+	return err
+}
+
+func (d *ObjectDefinition) UnmarshalJSON(data []byte) error {
+	type objectDefinition ObjectDefinition
+	if err := json.Unmarshal(data, (*objectDefinition)(d)); err != nil {
+		return err
+	}
+
+	var err error
+	d.Extensions, err = vendorExtensions(data)
+	return err
+}
+
+func (p *ObjectProperty) UnmarshalJSON(data []byte) error {
+	type objectProperty ObjectProperty
+	if err := json.Unmarshal(data, (*objectProperty)(p)); err != nil {
+		return err
+	}
+
+	var err error
+	p.Extensions, err = vendorExtensions(data)
+	return err
+}
+
+// vendorExtensions collects the x- prefixed keys of a JSON object.
+func vendorExtensions(data []byte) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	extensions := make(map[string]interface{})
+	for key, value := range fields {
+		if strings.HasPrefix(key, "x-") {
+			extensions[key] = value
+		}
+	}
+
+	return extensions, nil
+}
+
+type Items struct {
+	Type   string
+	Format string
+	Ref    string `json:"$ref"`
+	Items  *Items // used with type "array"
+	// AdditionalProperties is the value schema of a map item.
+	AdditionalProperties *AdditionalProperties
+}
+
+type AdditionalProperties struct {
+	Type   string // used with type "map"
+	Format string // used with type "map"
+	Ref    string `json:"$ref"` // used with object
+	Items  *Items // used with maps of arrays
+	// AdditionalProperties is the value schema of a map of maps.
+	AdditionalProperties *AdditionalProperties
+}
+
+// UnmarshalJSON also accepts the boolean form of additionalProperties. Both `true` and `false` leave the value
+// schema empty, so the map is generated as a free-form one.
+func (a *AdditionalProperties) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		*a = AdditionalProperties{}
+		return nil
+	}
+
+	type additionalProperties AdditionalProperties
+	return json.Unmarshal(data, (*additionalProperties)(a))
+}
+
+func generateBodyDefinitionFromSchema(s *Schema) {
+	// Needed because of this change: https://github.com/grpc-ecosystem/grpc-gateway/issues/1670
+	for _, def := range s.Paths {
+		if verb, ok := def["put"]; ok {
+			for idx, param := range verb.Parameters {
+				if param.In == "body" && param.Name == "body" && param.Schema.Ref == "" && !isFileParameter(param) {
+					objectName := "Api" + strings.TrimPrefix(verb.OperationId, fmt.Sprintf("%s_", s.Namespace)) + "Request"
+					param.Schema.Ref = "#/definitions/" + objectName
+					def["put"].Parameters[idx] = param
+
+					properties := make(map[string]ObjectProperty)
+					for key, p := range param.Schema.Properties {
+						properties[key] = ObjectProperty{
+							Type:                 p.Type,
+							Format:               p.Format,
+							Items:                Items{},
+							AdditionalProperties: AdditionalProperties{},
+							Description:          p.Description,
+						}
+					}
+
+					s.Definitions[objectName] = ObjectDefinition{
+						Properties:  properties,
+						Description: param.Schema.Description,
+					}
+				}
+			}
+		}
+	}
+}
+
+// normalizeOpenAPI3 rewrites the OpenAPI 3.x parts of the schema into their Swagger 2.0 equivalents so the
+// template only has to deal with a single layout.
+func normalizeOpenAPI3(s *Schema) {
+	if s.OpenAPI == "" {
+		return
+	}
+
+	if s.Definitions == nil {
+		s.Definitions = make(map[string]ObjectDefinition)
+	}
+	for name, def := range s.Components.Schemas {
+		s.Definitions[name] = def
+	}
+
+	if len(s.Components.SecuritySchemes) > 0 && s.SecurityDefinitions == nil {
+		s.SecurityDefinitions = make(map[string]SecurityScheme)
+	}
+	for name, scheme := range s.Components.SecuritySchemes {
+		if scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic") {
+			scheme.Type = "basic"
+		}
+
+		// Keep the flow Swagger 2.0 would document, preferring the authorization code flow.
+		flows := []struct {
+			name string
+			flow *OAuthFlow
+		}{
+			{"accessCode", scheme.Flows.AuthorizationCode},
+			{"password", scheme.Flows.Password},
+			{"application", scheme.Flows.ClientCredentials},
+			{"implicit", scheme.Flows.Implicit},
+		}
+		for _, flow := range flows {
+			if flow.flow != nil {
+				scheme.Flow = flow.name
+				scheme.AuthorizationUrl = flow.flow.AuthorizationUrl
+				scheme.TokenUrl = flow.flow.TokenUrl
+				scheme.RefreshUrl = flow.flow.RefreshUrl
+				break
+			}
+		}
+		s.SecurityDefinitions[name] = scheme
+	}
+
+	// The path of the first server takes the place of the Swagger 2.0 basePath.
+	if len(s.Servers) > 0 {
+		if server, err := url.Parse(s.Servers[0].Url); err == nil {
+			s.BasePath = server.Path
+		}
+	}
+
+	for _, def := range s.Paths {
+		for _, verb := range def {
+			for idx, param := range verb.Parameters {
+				// Non-body parameters carry their type in a nested schema.
+				if param.In != "body" && param.Type == "" {
+					param.Type = param.Schema.Type
+					param.Format = param.Schema.Format
+					param.Items.Type = param.Schema.Items.Type
+					param.CollectionFormat = collectionFormatOf(param)
+					verb.Parameters[idx] = param
+				}
+			}
+
+			if mediaType, ok := verb.RequestBody.formData(); ok {
+				verb.Parameters = append(verb.Parameters, s.formDataParameters(mediaType.Schema)...)
+			} else if verb.RequestBody != nil {
+				verb.Parameters = append(verb.Parameters, Parameter{
+					Name:        "body",
+					In:          "body",
+					Description: verb.RequestBody.Description,
+					Required:    verb.RequestBody.Required,
+					Schema:      jsonMediaType(verb.RequestBody.Content).Schema,
+				})
+			}
+
+			if verb.Responses.Ok.Schema.Ref == "" && verb.Responses.Ok.Schema.Type == "" {
+				verb.Responses.Ok.Schema = jsonMediaType(verb.Responses.Ok.Content).Schema
+			}
+			for status, response := range verb.Responses.Status {
+				if response.Schema.Ref == "" && response.Schema.Type == "" {
+					response.Schema = jsonMediaType(response.Content).Schema
+					verb.Responses.Status[status] = response
+				}
+			}
+		}
+	}
+}
+
+// formData returns the multipart/form-data media type of a request body which has no JSON alternative.
+func (b *RequestBody) formData() (MediaType, bool) {
+	if b == nil {
+		return MediaType{}, false
+	}
+	if _, ok := b.Content["application/json"]; ok {
+		return MediaType{}, false
+	}
+	mediaType, ok := b.Content["multipart/form-data"]
+	return mediaType, ok
+}
+
+// formDataParameters expands an OpenAPI 3.x multipart/form-data schema into Swagger 2.0 formData parameters.
+func (s *Schema) formDataParameters(schema ObjectSchema) []Parameter {
+	def := s.Definitions[schema.Ref[strings.LastIndex(schema.Ref, "/")+1:]]
+	if schema.Ref == "" {
+		def = ObjectDefinition{Properties: make(map[string]ObjectProperty), Required: schema.Required}
+		for name, property := range schema.Properties {
+			def.Properties[name] = ObjectProperty{Type: property.Type, Format: property.Format, Items: property.Items}
+		}
+	}
+
+	names := make([]string, 0, len(def.Properties))
+	for name := range def.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]Parameter, 0, len(names))
+	for _, name := range names {
+		property := def.Properties[name]
+		param := Parameter{
+			Name:     name,
+			In:       "formData",
+			Required: isRequired(def, name),
+			Type:     property.Type,
+			Format:   property.Format,
+		}
+		param.Items.Type = property.Items.Type
+		if property.Type == "string" && property.Format == "binary" {
+			param.Type = "file"
+		}
+		params = append(params, param)
+	}
+
+	return params
+}
+
+// jsonMediaType picks the JSON media type out of an OpenAPI 3.x content map, falling back to any other entry.
+func jsonMediaType(content map[string]MediaType) MediaType {
+	if mediaType, ok := content["application/json"]; ok {
+		return mediaType
+	}
+
+	keys := make([]string, 0, len(content))
+	for key := range content {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return MediaType{}
+	}
+	sort.Strings(keys)
+
+	return content[keys[0]]
+}
+
+// formatFromExtension guesses the input format from a file name, returning an empty string for names which
+// are not schema files.
+func formatFromExtension(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".proto":
+		return "proto"
+	default:
+		return ""
+	}
+}
+
+// decodeDocument decodes a Swagger 2.0 or OpenAPI 3.x document in either the json or yaml format into its
+// generic form.
+func decodeDocument(content []byte, format string) (interface{}, error) {
+	var document interface{}
+	switch format {
+	case "json":
+		if err := json.Unmarshal(content, &document); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		var err error
+		if document, err = decodeYAML(content); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown input format %q", format)
+	}
+
+	// Normalize JSON Schema keywords in the generic form before the typed decode.
+	defs := make(map[string]interface{})
+	if err := normalizeJSONSchema(document, defs); err != nil {
+		return nil, err
+	}
+	if root, ok := document.(map[string]interface{}); ok && len(defs) > 0 {
+		definitions, ok := root["definitions"].(map[string]interface{})
+		if !ok {
+			definitions = make(map[string]interface{})
+			root["definitions"] = definitions
+		}
+		var schemas map[string]interface{}
+		if components, ok := root["components"].(map[string]interface{}); ok {
+			schemas, _ = components["schemas"].(map[string]interface{})
+		}
+		for name, def := range defs {
+			for _, existing := range []map[string]interface{}{definitions, schemas} {
+				if other, ok := existing[name]; ok && !reflect.DeepEqual(other, def) {
+					return nil, fmt.Errorf("the $defs schema %s conflicts with the definition of the same name", name)
+				}
+			}
+			definitions[name] = def
+		}
+	}
+
+	return document, nil
+}
+
+// decodeSchema decodes the generic form of a document into the typed schema.
+func decodeSchema(document interface{}) (*Schema, error) {
+	content, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema *Schema
+	if err := json.Unmarshal(content, &schema); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// normalizeJSONSchema rewrites OpenAPI 3.1 / JSON Schema 2020-12 keywords into the forms understood by the
+// typed schema structs: `type` arrays become a single type plus `nullable`, a `const` implies its type, and
+// `$defs` are collected into defs so they can be hoisted into the top level definitions, failing when two share a
+// name but not their schema.
+func normalizeJSONSchema(node interface{}, defs map[string]interface{}) error {
+	switch value := node.(type) {
+	case []interface{}:
+		for _, item := range value {
+			if err := normalizeJSONSchema(item, defs); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			if err := normalizeJSONSchema(item, defs); err != nil {
+				return err
+			}
+		}
+
+		if types, ok := value["type"].([]interface{}); ok {
+			value["type"] = ""
+			for _, t := range types {
+				if t == "null" {
+					value["nullable"] = true
+				} else if value["type"] == "" {
+					value["type"] = t
+				}
+			}
+		}
+
+		if _, ok := value["type"]; !ok {
+			switch constant := value["const"].(type) {
+			case string:
+				value["type"] = "string"
+			case bool:
+				value["type"] = "boolean"
+			case float64:
+				if constant == float64(int64(constant)) {
+					value["type"] = "integer"
+				} else {
+					value["type"] = "number"
+				}
+			}
+		}
+
+		// Swagger 2.0 marks nullable fields with a vendor extension.
+		if nullable, ok := value["x-nullable"].(bool); ok && nullable {
+			value["nullable"] = true
+		}
+
+		// Swagger 2.0 discriminators are a plain property name.
+		if propertyName, ok := value["discriminator"].(string); ok {
+			value["discriminator"] = map[string]interface{}{"propertyName": propertyName}
+		}
+
+		if nested, ok := value["$defs"].(map[string]interface{}); ok {
+			for name, def := range nested {
+				if other, ok := defs[name]; ok && !reflect.DeepEqual(other, def) {
+					return fmt.Errorf("the $defs schemas %s of different schemas conflict", name)
+				}
+				defs[name] = def
+			}
+			delete(value, "$defs")
+		}
+	}
+	return nil
+}
+
+// decodeYAML decodes the subset of YAML used by OpenAPI documents (block and flow collections, plain, quoted
+// and block scalars) into the same generic values produced by json.Unmarshal. Anchors, aliases, tags, directives
+// and streams of several documents are rejected rather than misread.
+func decodeYAML(content []byte) (interface{}, error) {
+	p := &yamlParser{}
+	started, ended := false, false
+	for idx, line := range strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " \t")
+		text := stripYAMLComment(line)
+		// Markers and directives only stand at the start of a line, indented they're content.
+		switch {
+		case text == "---" || strings.HasPrefix(text, "--- "):
+			if started || ended {
+				return nil, fmt.Errorf("yaml: line %d: multiple documents aren't supported", idx+1)
+			}
+			if text != "---" {
+				return nil, fmt.Errorf("yaml: line %d: content on the document marker isn't supported", idx+1)
+			}
+			line = ""
+		case text == "..." || strings.HasPrefix(text, "... "):
+			if text != "..." {
+				return nil, fmt.Errorf("yaml: line %d: content on the document marker isn't supported", idx+1)
+			}
+			ended = true
+			line = ""
+		case strings.HasPrefix(text, "%"):
+			return nil, fmt.Errorf("yaml: line %d: directives aren't supported", idx+1)
+		case ended && strings.TrimSpace(text) != "":
+			return nil, fmt.Errorf("yaml: line %d: multiple documents aren't supported", idx+1)
+		}
+		started = started || strings.TrimSpace(text) != ""
+		// Blank lines keep the line numbers of errors.
+		p.lines = append(p.lines, line)
+	}
+
+	node, err := p.parseNode(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.next() {
+		return nil, fmt.Errorf("yaml: line %d: unexpected content", p.pos+1)
+	}
+
+	return node, nil
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+// next skips blank and comment lines and reports whether there is content left.
+func (p *yamlParser) next() bool {
+	for ; p.pos < len(p.lines); p.pos++ {
+		if text := strings.TrimSpace(p.lines[p.pos]); text != "" && !strings.HasPrefix(text, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// line returns the indentation and the comment stripped text of the current line.
+func (p *yamlParser) line() (int, string) {
+	raw := p.lines[p.pos]
+	text := strings.TrimLeft(raw, " ")
+	return len(raw) - len(text), stripYAMLComment(text)
+}
+
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	if !p.next() {
+		return nil, nil
+	}
+
+	lineIndent, text := p.line()
+	if lineIndent < indent {
+		return nil, nil
+	}
+
+	if text == "-" || strings.HasPrefix(text, "- ") {
+		return p.parseSequence(lineIndent)
+	}
+	if _, _, ok := splitYAMLMapping(text); ok {
+		return p.parseMapping(lineIndent)
+	}
+
+	return p.parseValue(lineIndent-1, text)
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	mapping := make(map[string]interface{})
+	for p.next() {
+		lineIndent, text := p.line()
+		if lineIndent < indent {
+			break
+		}
+		if lineIndent > indent {
+			return nil, fmt.Errorf("yaml: line %d: unexpected indentation", p.pos+1)
+		}
+
+		key, rest, ok := splitYAMLMapping(text)
+		if !ok {
+			if text == "-" || strings.HasPrefix(text, "- ") {
+				break
+			}
+			return nil, fmt.Errorf("yaml: line %d: expected a mapping key", p.pos+1)
+		}
+		if isYAMLProperty(text) {
+			return nil, fmt.Errorf("yaml: line %d: anchors, aliases and tags aren't supported", p.pos+1)
+		}
+		p.pos++
+
+		var value interface{}
+		var err error
+		if rest == "" {
+			// Sequences are allowed at the same indentation as their parent key.
+			if p.next() {
+				nextIndent, nextText := p.line()
+				if nextIndent > indent || (nextIndent == indent && (nextText == "-" || strings.HasPrefix(nextText, "- "))) {
+					value, err = p.parseNode(nextIndent)
+				}
+			}
+		} else {
+			value, err = p.parseValue(indent, rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		mapping[key] = value
+	}
+
+	return mapping, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	sequence := make([]interface{}, 0)
+	for p.next() {
+		lineIndent, text := p.line()
+		if lineIndent != indent || (text != "-" && !strings.HasPrefix(text, "- ")) {
+			break
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			value, err := p.parseNode(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			sequence = append(sequence, value)
+			continue
+		}
+
+		// Nested block collections start on the same line as the dash, re-indent them and parse in place.
+		_, _, isMapping := splitYAMLMapping(rest)
+		if isMapping || rest == "-" || strings.HasPrefix(rest, "- ") {
+			offset := len(text) - len(rest)
+			p.lines[p.pos] = strings.Repeat(" ", indent+offset) + rest
+			value, err := p.parseNode(indent + offset)
+			if err != nil {
+				return nil, err
+			}
+			sequence = append(sequence, value)
+			continue
+		}
+
+		p.pos++
+		value, err := p.parseValue(indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		sequence = append(sequence, value)
+	}
+
+	return sequence, nil
+}
+
+// parseValue parses an inline value whose parent collection is at indent. The current line has already been
+// consumed, continuation lines for block, flow and multi-line scalars are consumed here.
+func (p *yamlParser) parseValue(indent int, text string) (interface{}, error) {
+	if strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">") {
+		return p.parseBlockScalar(indent, text), nil
+	}
+
+	// Gather continuation lines for flow collections, quoted and plain multi-line scalars.
+	for p.pos < len(p.lines) && !yamlValueComplete(text) {
+		if strings.TrimSpace(p.lines[p.pos]) == "" {
+			text += "\n"
+			p.pos++
+			continue
+		}
+		lineIndent, next := p.line()
+		if lineIndent <= indent {
+			break
+		}
+		switch {
+		case strings.HasSuffix(text, "\n"):
+			text += strings.TrimSpace(next)
+		case strings.HasPrefix(strings.TrimSpace(text), "\"") && escapedYAMLLineBreak(text):
+			// An escaped line break joins the lines without a space.
+			text = text[:len(text)-1] + strings.TrimSpace(next)
+		default:
+			text += " " + strings.TrimSpace(next)
+		}
+		p.pos++
+	}
+
+	var value interface{}
+	var rest string
+	var err error
+	if text = strings.TrimSpace(text); strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		value, rest, err = parseYAMLFlow(text)
+	} else {
+		value, rest, err = parseYAMLScalar(text, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("yaml: line %d: %s", p.pos, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("yaml: line %d: unexpected content %q", p.pos, rest)
+	}
+
+	return value, nil
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar.
+func (p *yamlParser) parseBlockScalar(indent int, header string) string {
+	var lines []string
+	contentIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos]
+		text := strings.TrimLeft(raw, " ")
+		if text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if len(raw)-len(text) <= indent {
+			break
+		}
+		if contentIndent < 0 {
+			contentIndent = len(raw) - len(text)
+		}
+		if len(raw)-len(text) < contentIndent {
+			break
+		}
+		lines = append(lines, raw[contentIndent:])
+	}
+
+	// Trailing blank lines are only kept with the keep (+) chomping indicator.
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var value string
+	if strings.HasPrefix(header, ">") {
+		for idx, line := range lines {
+			switch {
+			case idx == 0:
+				value = line
+			case line == "":
+				value += "\n"
+			case lines[idx-1] == "":
+				value += line
+			case strings.HasPrefix(line, " "):
+				value += "\n" + line
+			default:
+				value += " " + line
+			}
+		}
+	} else {
+		value = strings.Join(lines, "\n")
+	}
+
+	switch {
+	case strings.Contains(header, "-"):
+		return value
+	case strings.Contains(header, "+"):
+		return value + strings.Repeat("\n", trailing+1)
+	default:
+		return value + "\n"
+	}
+}
+
+// stripYAMLComment removes a trailing comment from a line, ignoring '#' characters inside quotes.
+func stripYAMLComment(text string) string {
+	var quote rune
+	for idx, c := range text {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (idx == 0 || text[idx-1] == ' ' || text[idx-1] == '\t'):
+			return strings.TrimRight(text[:idx], " \t")
+		}
+	}
+	return text
+}
+
+// splitYAMLMapping splits a "key: value" line, returning false if the line is not a mapping entry.
+func splitYAMLMapping(text string) (key string, rest string, ok bool) {
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+
+	end := 0
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		value, remaining, err := parseYAMLScalar(text, false)
+		if err != nil || !strings.HasPrefix(remaining, ":") {
+			return "", "", false
+		}
+		end = len(text) - len(remaining)
+		key = fmt.Sprint(value)
+	} else {
+		end = strings.Index(text, ": ")
+		if end < 0 {
+			if !strings.HasSuffix(text, ":") {
+				return "", "", false
+			}
+			end = len(text) - 1
+		}
+		key = strings.TrimSpace(text[:end])
+	}
+
+	rest = strings.TrimPrefix(text[end:], ":")
+	if rest != "" && !strings.HasPrefix(rest, " ") {
+		return "", "", false
+	}
+
+	return key, strings.TrimSpace(rest), true
+}
+
+// yamlValueComplete reports whether an inline value needs no further continuation lines.
+func yamlValueComplete(text string) bool {
+	depth := 0
+	var quote rune
+	escaped := false
+	for _, c := range text {
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case quote == '"':
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+
+	if quote != 0 || depth > 0 {
+		return false
+	}
+
+	// Plain scalars may continue on more indented lines, quoted scalars and flow collections end here.
+	trimmed := strings.TrimSpace(text)
+	return strings.HasPrefix(trimmed, "\"") || strings.HasPrefix(trimmed, "'") ||
+		strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{")
+}
+
+// parseYAMLFlow parses a flow collection or a scalar, returning the remaining unparsed text.
+func parseYAMLFlow(text string) (interface{}, string, error) {
+	text = strings.TrimLeft(text, " \n")
+	switch {
+	case strings.HasPrefix(text, "["):
+		sequence := make([]interface{}, 0)
+		text = strings.TrimLeft(text[1:], " \n")
+		for !strings.HasPrefix(text, "]") {
+			value, rest, err := parseYAMLFlow(text)
+			if err != nil {
+				return nil, "", err
+			}
+			sequence = append(sequence, value)
+			text = strings.TrimLeft(rest, " \n")
+			if strings.HasPrefix(text, ",") {
+				text = strings.TrimLeft(text[1:], " \n")
+			} else if !strings.HasPrefix(text, "]") {
+				return nil, "", fmt.Errorf("yaml: expected ',' or ']' in flow sequence")
+			}
+		}
+		return sequence, text[1:], nil
+	case strings.HasPrefix(text, "{"):
+		mapping := make(map[string]interface{})
+		text = strings.TrimLeft(text[1:], " \n")
+		for !strings.HasPrefix(text, "}") {
+			key, rest, err := parseYAMLFlow(text)
+			if err != nil {
+				return nil, "", err
+			}
+			rest = strings.TrimLeft(rest, " \n")
+			if !strings.HasPrefix(rest, ":") {
+				return nil, "", fmt.Errorf("yaml: expected ':' in flow mapping")
+			}
+			value, rest, err := parseYAMLFlow(rest[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			mapping[fmt.Sprint(key)] = value
+			text = strings.TrimLeft(rest, " \n")
+			if strings.HasPrefix(text, ",") {
+				text = strings.TrimLeft(text[1:], " \n")
+			} else if !strings.HasPrefix(text, "}") {
+				return nil, "", fmt.Errorf("yaml: expected ',' or '}' in flow mapping")
+			}
+		}
+		return mapping, text[1:], nil
+	default:
+		return parseYAMLScalar(text, true)
+	}
+}
+
+// parseYAMLScalar parses a quoted or plain scalar, returning the remaining unparsed text. Plain scalars inside
+// flow collections end at the next flow indicator.
+func parseYAMLScalar(text string, flow bool) (interface{}, string, error) {
+	switch {
+	case strings.HasPrefix(text, "'"):
+		var value strings.Builder
+		for idx := 1; idx < len(text); idx++ {
+			if text[idx] == '\'' {
+				if idx+1 < len(text) && text[idx+1] == '\'' {
+					value.WriteByte('\'')
+					idx++
+					continue
+				}
+				return value.String(), text[idx+1:], nil
+			}
+			value.WriteByte(text[idx])
+		}
+		return nil, "", fmt.Errorf("yaml: unterminated single quoted scalar")
+	case strings.HasPrefix(text, "\""):
+		for idx := 1; idx < len(text); idx++ {
+			if text[idx] == '\\' {
+				idx++
+				continue
+			}
+			if text[idx] == '"' {
+				value, err := unescapeYAML(text[1:idx])
+				if err != nil {
+					return nil, "", fmt.Errorf("yaml: invalid double quoted scalar: %s", err)
+				}
+				return value, text[idx+1:], nil
+			}
+		}
+		return nil, "", fmt.Errorf("yaml: unterminated double quoted scalar")
+	}
+
+	end := len(text)
+	if flow {
+		if idx := strings.IndexAny(text, ",]}"); idx >= 0 {
+			end = idx
+		}
+		if idx := strings.Index(text, ": "); idx >= 0 && idx < end {
+			end = idx
+		}
+		if strings.HasSuffix(text[:end], ":") {
+			end--
+		}
+	}
+
+	plain := strings.TrimSpace(text[:end])
+	if isYAMLProperty(plain) {
+		return nil, "", fmt.Errorf("yaml: anchors, aliases and tags aren't supported: %q", plain)
+	}
+	switch plain {
+	case "", "~", "null", "Null", "NULL":
+		return nil, text[end:], nil
+	case "true", "True", "TRUE":
+		return true, text[end:], nil
+	case "false", "False", "FALSE":
+		return false, text[end:], nil
+	}
+	if strings.ContainsAny(plain[:1], "0123456789-+.") && !strings.ContainsAny(plain, "xXoOnN_") {
+		if number, err := strconv.ParseFloat(plain, 64); err == nil {
+			return number, text[end:], nil
+		}
+	}
+
+	return plain, text[end:], nil
+}
+
+// isYAMLProperty reports whether a plain scalar starts with an anchor (&), an alias (*) or a tag (!), which
+// decodeYAML doesn't support.
+func isYAMLProperty(text string) bool {
+	return strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*") || strings.HasPrefix(text, "!")
+}
+
+// escapedYAMLLineBreak reports whether a line of a double quoted scalar ends with an escaped line break, an odd
+// number of backslashes.
+func escapedYAMLLineBreak(text string) bool {
+	count := len(text) - len(strings.TrimRight(text, "\\"))
+	return count%2 == 1
+}
+
+// yamlEscapes are the characters of the single character escapes of double quoted scalars.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f", 'r': "\r",
+	'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// unescapeYAML decodes the escapes of the content of a double quoted scalar, following the YAML rules rather
+// than Go's.
+func unescapeYAML(text string) (string, error) {
+	var value strings.Builder
+	for idx := 0; idx < len(text); idx++ {
+		if text[idx] != '\\' {
+			value.WriteByte(text[idx])
+			continue
+		}
+		idx++
+		if idx >= len(text) {
+			return "", fmt.Errorf("unterminated escape")
+		}
+		if escape, ok := yamlEscapes[text[idx]]; ok {
+			value.WriteString(escape)
+			continue
+		}
+		digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[text[idx]]
+		if digits == 0 || idx+digits >= len(text) {
+			return "", fmt.Errorf("invalid escape \\%c", text[idx])
+		}
+		code, err := strconv.ParseUint(text[idx+1:idx+1+digits], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape \\%s", text[idx:idx+1+digits])
+		}
+		value.WriteRune(rune(code))
+		idx += digits
+	}
+	return value.String(), nil
+}
+
+// mergeDocuments merges the paths and definitions of every document into the first one. Definitions with the
+// same name and content are shared, conflicting definitions from later documents are renamed with a prefix
+// derived from their file name. Operations can't be renamed safely so duplicates are reported as errors.
+// Security schemes and tags are merged too, failing on conflicting entries. The base path, the document wide
+// security and the produces and consumes lists of later documents are applied to their own operations.
+func mergeDocuments(names []string, documents []interface{}) (interface{}, error) {
+	root, ok := documents[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not an object", names[0])
+	}
+	rootHost, rootBasePath, err := documentBasePath(root)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", names[0], err)
+	}
+
+	rootPaths := documentSection(root, "paths")
+	rootDefinitions := documentDefinitions(root)
+	operationIds := make(map[string]string)
+	if err := collectOperationIds(names[0], rootPaths, operationIds); err != nil {
+		return nil, err
+	}
+
+	for idx, document := range documents[1:] {
+		name := names[idx+1]
+		doc, ok := document.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s is not an object", name)
+		}
+
+		if version, ok := doc["openapi"]; ok {
+			root["openapi"] = version
+		}
+
+		host, basePath, err := documentBasePath(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		if host != "" && rootHost != "" && host != rootHost {
+			return nil, fmt.Errorf("the server %s of %s differs from the server %s of %s", host, name, rootHost, names[0])
+		}
+		if basePath != rootBasePath {
+			// Operations keep their URL when the base path of the first document is a prefix of theirs.
+			if rootBasePath != "" && !strings.HasPrefix(basePath, rootBasePath+"/") {
+				return nil, fmt.Errorf("the base path %q of %s can't be joined onto the base path %q of %s", basePath, name, rootBasePath, names[0])
+			}
+			prefixed := make(map[string]interface{})
+			for route, item := range documentSection(doc, "paths") {
+				prefixed[basePath[len(rootBasePath):]+route] = item
+			}
+			doc["paths"] = prefixed
+		}
+		for _, key := range []string{"security", "produces", "consumes"} {
+			if value, ok := doc[key]; ok && !reflect.DeepEqual(value, root[key]) {
+				applyToOperations(documentSection(doc, "paths"), key, value)
+			}
+		}
+		if _, ok := root["security"]; ok {
+			if _, ok := doc["security"]; !ok {
+				// Operations of a document without a document wide security don't take the one of the first.
+				applyToOperations(documentSection(doc, "paths"), "security", []interface{}{})
+			}
+		}
+
+		if err := mergeSection(documentSection(root, "securityDefinitions"), documentSection(doc, "securityDefinitions"), "security scheme", name); err != nil {
+			return nil, err
+		}
+		if components, ok := doc["components"].(map[string]interface{}); ok {
+			if schemes, ok := components["securitySchemes"].(map[string]interface{}); ok {
+				rootComponents := documentSection(root, "components")
+				if err := mergeSection(documentSection(rootComponents, "securitySchemes"), schemes, "security scheme", name); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := mergeTags(root, doc, name); err != nil {
+			return nil, err
+		}
+
+		definitions := documentDefinitions(doc)
+		renames := make(map[string]string)
+		prefix := pascalToCamel(snakeToPascal(strings.NewReplacer("-", "_", ".", "_").Replace(
+			strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)))))
+		for key, def := range definitions {
+			existing, ok := rootDefinitions[key]
+			if !ok || reflect.DeepEqual(existing, def) {
+				continue
+			}
+
+			renamed := prefix + camelToPascal(key)
+			if _, ok := rootDefinitions[renamed]; ok {
+				return nil, fmt.Errorf("definition %s from %s conflicts with %s and can't be renamed", key, name, names[0])
+			}
+			fmt.Fprintf(os.Stderr, "Renamed conflicting definition %s from %s to %s\n", key, name, renamed)
+			renames[key] = renamed
+		}
+		renameRefs(doc, renames)
+
+		for key, def := range definitions {
+			if renamed, ok := renames[key]; ok {
+				key = renamed
+			}
+			rootDefinitions[key] = def
+		}
+
+		paths := documentSection(doc, "paths")
+		for route, item := range paths {
+			operations, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rootPath, ok := rootPaths[route].(map[string]interface{})
+			if !ok {
+				rootPath = make(map[string]interface{})
+				rootPaths[route] = rootPath
+			}
+			for method, operation := range operations {
+				if _, ok := rootPath[method]; ok {
+					return nil, fmt.Errorf("operation %s %s is defined in both %s and %s", strings.ToUpper(method), route, names[0], name)
+				}
+				rootPath[method] = operation
+			}
+		}
+		if err := collectOperationIds(name, paths, operationIds); err != nil {
+			return nil, err
+		}
+	}
+
+	return root, nil
+}
+
+// documentBasePath returns the host and the base path, without its trailing slash, of the basePath of a Swagger 2.0
+// document or of the first server of an OpenAPI 3.x one.
+func documentBasePath(document map[string]interface{}) (string, string, error) {
+	if servers, ok := document["servers"].([]interface{}); ok && len(servers) > 0 {
+		server, _ := servers[0].(map[string]interface{})
+		location, _ := server["url"].(string)
+		parsed, err := url.Parse(location)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid server URL %q: %s", location, err)
+		}
+		return parsed.Host, strings.TrimRight(parsed.Path, "/"), nil
+	}
+	host, _ := document["host"].(string)
+	basePath, _ := document["basePath"].(string)
+	return host, strings.TrimRight(basePath, "/"), nil
+}
+
+// applyToOperations sets a field, such as the security or the produces list of a document, on the operations of
+// its paths which don't set their own.
+func applyToOperations(paths map[string]interface{}, key string, value interface{}) {
+	for _, item := range paths {
+		operations, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for method, operation := range operations {
+			op, ok := operation.(map[string]interface{})
+			if !ok || method == "parameters" {
+				continue
+			}
+			if _, ok := op[key]; !ok {
+				op[key] = deepCopy(value)
+			}
+		}
+	}
+}
+
+// mergeSection adds the entries of a section of a document, such as its security schemes, to the section of the
+// first one, failing on entries with the same name and a different content.
+func mergeSection(root map[string]interface{}, section map[string]interface{}, kind string, name string) error {
+	for key, value := range section {
+		if existing, ok := root[key]; ok && !reflect.DeepEqual(existing, value) {
+			return fmt.Errorf("%s %s of %s conflicts with the one already defined", kind, key, name)
+		}
+		root[key] = value
+	}
+	return nil
+}
+
+// mergeTags adds the tags of a document to the tags of the first one, failing on tags with the same name and a
+// different description.
+func mergeTags(root map[string]interface{}, document map[string]interface{}, name string) error {
+	tags, _ := document["tags"].([]interface{})
+	if len(tags) == 0 {
+		return nil
+	}
+	rootTags, _ := root["tags"].([]interface{})
+	existing := make(map[string]interface{})
+	for _, tag := range rootTags {
+		if object, ok := tag.(map[string]interface{}); ok {
+			existing[fmt.Sprint(object["name"])] = tag
+		}
+	}
+	for _, tag := range tags {
+		object, ok := tag.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if previous, ok := existing[fmt.Sprint(object["name"])]; ok {
+			if !reflect.DeepEqual(previous, tag) {
+				return fmt.Errorf("tag %v of %s conflicts with the one already defined", object["name"], name)
+			}
+			continue
+		}
+		rootTags = append(rootTags, tag)
+	}
+	root["tags"] = rootTags
+	return nil
+}
+
+// documentSection returns the named top level object of a document, creating it if missing.
+func documentSection(document map[string]interface{}, name string) map[string]interface{} {
+	section, ok := document[name].(map[string]interface{})
+	if !ok {
+		section = make(map[string]interface{})
+		document[name] = section
+	}
+	return section
+}
+
+// documentDefinitions returns the definitions of a document, moving any OpenAPI 3.x component schemas into
+// them.
+func documentDefinitions(document map[string]interface{}) map[string]interface{} {
+	definitions := documentSection(document, "definitions")
+	if components, ok := document["components"].(map[string]interface{}); ok {
+		if schemas, ok := components["schemas"].(map[string]interface{}); ok {
+			for key, def := range schemas {
+				definitions[key] = def
+			}
+			delete(components, "schemas")
+		}
+	}
+	return definitions
+}
+
+// collectOperationIds records the file each operation id came from, failing on duplicates.
+func collectOperationIds(name string, paths map[string]interface{}, operationIds map[string]string) error {
+	for _, item := range paths {
+		operations, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, operation := range operations {
+			op, ok := operation.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, ok := op["operationId"].(string)
+			if !ok {
+				continue
+			}
+			if other, ok := operationIds[id]; ok && other != name {
+				return fmt.Errorf("operation id %s is defined in both %s and %s", id, other, name)
+			}
+			operationIds[id] = name
+		}
+	}
+	return nil
+}
+
+// renameRefs rewrites every local $ref to a renamed definition. The parameters, responses and nested $defs of the
+// same name are left alone.
+func renameRefs(node interface{}, renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+
+	switch value := node.(type) {
+	case []interface{}:
+		for _, item := range value {
+			renameRefs(item, renames)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			if ref, ok := item.(string); ok && key == "$ref" {
+				name := ref[strings.LastIndex(ref, "/")+1:]
+				renamed, ok := renames[name]
+				if ok && (ref == "#/definitions/"+name || ref == "#/components/schemas/"+name) {
+					value[key] = "#/definitions/" + renamed
+				}
+				continue
+			}
+			renameRefs(item, renames)
+		}
+	}
+}
+
+// refResolver imports the targets of external and remote $ref references into a document.
+type refResolver struct {
+	definitions map[string]interface{}
+	// owners maps each definition name to the reference it was imported from, empty for local definitions.
+	owners    map[string]string
+	documents map[string]interface{}
+	resolved  map[string]string
+}
+
+// resolveExternalRefs loads every document referenced by a `other.json#/definitions/Foo` style or remote URL
+// $ref, imports the referenced definitions into the document, and rewrites the refs to point at them. Refs to
+// anything other than a schema definition are inlined.
+func resolveExternalRefs(location string, document interface{}) error {
+	root, ok := document.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	r := &refResolver{
+		definitions: documentDefinitions(root),
+		owners:      make(map[string]string),
+		documents:   map[string]interface{}{location: document},
+		resolved:    make(map[string]string),
+	}
+	for name := range r.definitions {
+		r.owners[name] = ""
+	}
+
+	return r.walk(root, location, false)
+}
+
+// walk resolves refs in node. Local refs are left alone in the root document, in an external document they
+// refer to that document and are resolved like any other external ref.
+func (r *refResolver) walk(node interface{}, base string, external bool) error {
+	switch value := node.(type) {
+	case []interface{}:
+		for _, item := range value {
+			if err := r.walk(item, base, external); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		if ref, ok := value["$ref"].(string); ok && (external || !strings.HasPrefix(ref, "#")) {
+			location, fragment := ref, ""
+			if idx := strings.Index(ref, "#"); idx >= 0 {
+				location, fragment = ref[:idx], ref[idx+1:]
+			}
+			location = resolveLocation(base, location)
+
+			if !isDefinitionPointer(fragment) {
+				target, err := r.lookup(location, fragment)
+				if err != nil {
+					return err
+				}
+				delete(value, "$ref")
+				for key, item := range deepCopy(target).(map[string]interface{}) {
+					value[key] = item
+				}
+				return r.walk(value, location, true)
+			}
+
+			name, err := r.importDefinition(location, fragment)
+			if err != nil {
+				return err
+			}
+			value["$ref"] = "#/definitions/" + name
+		}
+
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if key == "$ref" {
+				continue
+			}
+			if err := r.walk(value[key], base, external); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// importDefinition copies the definition at location#fragment into the document and returns its name.
+func (r *refResolver) importDefinition(location, fragment string) (string, error) {
+	key := location + "#" + fragment
+	if name, ok := r.resolved[key]; ok {
+		return name, nil
+	}
+
+	target, err := r.lookup(location, fragment)
+	if err != nil {
+		return "", err
+	}
+
+	base := strings.TrimSuffix(path.Base(location), path.Ext(location))
+	name := base
+	if fragment != "" {
+		name = fragment[strings.LastIndex(fragment, "/")+1:]
+	}
+	if owner, ok := r.owners[name]; ok && owner != key {
+		if reflect.DeepEqual(r.definitions[name], target) {
+			r.resolved[key] = name
+			return name, nil
+		}
+
+		name = pascalToCamel(snakeToPascal(strings.NewReplacer("-", "_", ".", "_").Replace(base))) + camelToPascal(name)
+		if owner, ok := r.owners[name]; ok && owner != key {
+			return "", fmt.Errorf("definition %s imported from %s conflicts with an existing definition", name, key)
+		}
+	}
+
+	definition := deepCopy(target)
+	r.resolved[key] = name
+	r.owners[name] = key
+	r.definitions[name] = definition
+
+	return name, r.walk(definition, location, true)
+}
+
+// lookup loads the document at location and resolves the JSON pointer fragment in it.
+func (r *refResolver) lookup(location, fragment string) (interface{}, error) {
+	document, ok := r.documents[location]
+	if !ok {
+		content, err := readLocation(location)
+		if err != nil {
+			return nil, err
+		}
+
+		format := formatFromExtension(location)
+		if format == "" {
+			format = "json"
+		}
+		if document, err = decodeDocument(content, format); err != nil {
+			return nil, fmt.Errorf("unable to decode %s: %s", location, err)
+		}
+		r.documents[location] = document
+	}
+
+	if strings.Contains(fragment, "/$defs/") {
+		// Nested $defs have already been hoisted into the top level definitions.
+		fragment = "/definitions/" + fragment[strings.LastIndex(fragment, "/")+1:]
+	}
+
+	node := document
+	for _, token := range strings.Split(strings.TrimPrefix(fragment, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unable to resolve %s#%s", location, fragment)
+		}
+		if node, ok = object[token]; !ok {
+			return nil, fmt.Errorf("unable to resolve %s#%s", location, fragment)
+		}
+	}
+
+	return node, nil
+}
+
+// isDefinitionPointer reports whether a JSON pointer names a schema definition or a whole schema document.
+func isDefinitionPointer(fragment string) bool {
+	parent := ""
+	if idx := strings.LastIndex(fragment, "/"); idx >= 0 {
+		parent = fragment[:idx]
+	}
+
+	return fragment == "" || strings.HasSuffix(parent, "/definitions") || strings.HasSuffix(parent, "/$defs") ||
+		strings.HasSuffix(parent, "/components/schemas")
+}
+
+// resolveLocation resolves a ref location relative to the document it appears in.
+func resolveLocation(base, location string) string {
+	if location == "" {
+		return base
+	}
+
+	if baseURL, err := url.Parse(base); err == nil && (baseURL.Scheme == "http" || baseURL.Scheme == "https") {
+		if ref, err := url.Parse(location); err == nil {
+			return baseURL.ResolveReference(ref).String()
+		}
+	}
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") || filepath.IsAbs(location) {
+		return location
+	}
+
+	return filepath.Join(filepath.Dir(base), location)
+}
+
+// readLocation reads a local file or fetches a remote http(s) document.
+func readLocation(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.ReadFile(location)
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	response, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch %s: %s", location, response.Status)
+	}
+
+	return io.ReadAll(response.Body)
+}
+
+// deepCopy copies a generic document node so imported definitions can be rewritten independently.
+func deepCopy(node interface{}) interface{} {
+	switch value := node.(type) {
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for idx, item := range value {
+			copied[idx] = deepCopy(item)
+		}
+		return copied
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			copied[key] = deepCopy(item)
+		}
+		return copied
+	default:
+		return node
+	}
+}
+
+// applyAnyOfStrategy rewrites anyOf definitions and properties. The "first" strategy uses the type of the first
+// schema, "any" falls back to AnyCodable, and "union" generates an enum with a case for every schema, the same
+// way oneOf definitions without a discriminator are generated.
+func applyAnyOfStrategy(s *Schema, strategy string) error {
+	if strategy != "first" && strategy != "any" && strategy != "union" {
+		return fmt.Errorf("unknown anyOf strategy: %s", strategy)
+	}
+
+	for defname, def := range s.Definitions {
+		if len(def.AnyOf) > 0 {
+			switch strategy {
+			case "first":
+				def.Alias = swiftType(schemaProperty(def.AnyOf[0]))
+			case "any":
+				def.Alias = "AnyCodable"
+			case "union":
+				def.OneOf = def.AnyOf
+			}
+		}
+
+		for propname, property := range def.Properties {
+			if len(property.AnyOf) == 0 {
+				continue
+			}
+
+			switch strategy {
+			case "first":
+				first := schemaProperty(property.AnyOf[0])
+				property.Type, property.Ref, property.Format, property.Items = first.Type, first.Ref, first.Format, first.Items
+				property.AnyOf = nil
+			case "union":
+				name := defname + camelToPascal(propname)
+				if _, ok := s.Definitions[name]; ok {
+					return fmt.Errorf("the anyOf of property %s of %s generates the definition %s, which already exists", propname, defname, name)
+				}
+				s.Definitions[name] = ObjectDefinition{Description: property.Description, OneOf: property.AnyOf}
+				property.Type, property.Ref = "", "#/definitions/"+name
+				property.AnyOf = nil
+			}
+			def.Properties[propname] = property
+		}
+
+		s.Definitions[defname] = def
+	}
+
+	return nil
+}
+
+// schemaProperty converts an inline schema into a property so its Swift type can be resolved.
+func schemaProperty(schema ObjectSchema) ObjectProperty {
+	return ObjectProperty{
+		Type:   schema.Type,
+		Ref:    schema.Ref,
+		Format: schema.Format,
+		Items:  schema.Items,
+	}
+}
+
+// operations lists every operation ordered by path and method.
+func (s *Schema) operations() []OperationContext {
+	urls := make([]string, 0, len(s.Paths))
+	for url := range s.Paths {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	var operations []OperationContext
+	for _, url := range urls {
+		methods := make([]string, 0, len(s.Paths[url]))
+		for method := range s.Paths[url] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			operations = append(operations, OperationContext{Url: url, Method: method, Operation: s.Paths[url][method]})
+		}
+	}
+
+	return operations
+}
+
+// isIsolated reports whether an operation is generated into the extension of deprecated operations.
+func (s *Schema) isIsolated(operation OperationContext) bool {
+	return s.Options.DeprecatedOperations == "isolate" && operation.Operation.Deprecated
+}
+
+// clientOperations lists the operations generated into the ApiClient class itself.
+func (s *Schema) clientOperations() []OperationContext {
+	var operations []OperationContext
+	for _, operation := range s.operations() {
+		if !s.isIsolated(operation) {
+			operations = append(operations, operation)
+		}
+	}
+	return operations
+}
+
+// deprecatedGroup groups the operations isolated into the extension of deprecated operations of the ApiClient.
+func (s *Schema) deprecatedGroup() TagGroup {
+	group := TagGroup{ClassName: "ApiClient"}
+	for _, operation := range s.operations() {
+		if s.isIsolated(operation) {
+			group.Deprecated = append(group.Deprecated, operation)
+		}
+	}
+	return group
+}
+
+// filterOperations keeps only the operations whose OperationId matches include, when given, and doesn't match
+// exclude, when given, removing the paths left without any from the schema.
+func filterOperations(s *Schema, include, exclude *regexp.Regexp) {
+	for url, methods := range s.Paths {
+		for method, operation := range methods {
+			if include != nil && !include.MatchString(operation.OperationId) ||
+				exclude != nil && exclude.MatchString(operation.OperationId) {
+				delete(methods, method)
+			}
+		}
+		if len(methods) == 0 {
+			delete(s.Paths, url)
+		}
+	}
+}
+
+// Allowlist lists exactly which operations, by OperationId, and definitions are generated. The definitions they
+// reference are generated as well, an omitted list of operations keeps all of them.
+type Allowlist struct {
+	Operations  []string `json:"operations"`
+	Definitions []string `json:"definitions"`
+}
+
+// loadConfigFile decodes a configuration file, such as an allowlist, in the json or yaml format into config.
+func loadConfigFile(name string, config interface{}) error {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if formatFromExtension(name) == "yaml" {
+		document, err := decodeYAML(content)
+		if err != nil {
+			return err
+		}
+		if content, err = json.Marshal(document); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(content, config)
+}
+
+// applyAllowlist removes the operations an allowlist doesn't list, and then every definition which is neither
+// listed nor referenced, directly or through other definitions, by a remaining operation or listed definition.
+func applyAllowlist(s *Schema, allowlist *Allowlist) error {
+	if allowlist.Operations != nil {
+		listed := make(map[string]bool, len(allowlist.Operations))
+		for _, operationId := range allowlist.Operations {
+			listed[operationId] = true
+		}
+		for url, methods := range s.Paths {
+			for method, operation := range methods {
+				if listed[operation.OperationId] {
+					delete(listed, operation.OperationId)
+				} else {
+					delete(methods, method)
+				}
+			}
+			if len(methods) == 0 {
+				delete(s.Paths, url)
+			}
+		}
+		for operationId := range listed {
+			return fmt.Errorf("unknown operation %q in the allowlist", operationId)
+		}
+	}
+
+	var pending []string
+	for _, defname := range allowlist.Definitions {
+		if _, ok := s.Definitions[defname]; !ok {
+			return fmt.Errorf("unknown definition %q in the allowlist", defname)
+		}
+		pending = append(pending, defname)
+	}
+	for _, methods := range s.Paths {
+		for _, operation := range methods {
+			pending = append(pending, referencedDefinitions(reflect.ValueOf(operation))...)
+		}
+	}
+
+	kept := make(map[string]bool)
+	for len(pending) > 0 {
+		defname := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if kept[defname] {
+			continue
+		}
+		kept[defname] = true
+		if def, ok := s.Definitions[defname]; ok {
+			pending = append(pending, referencedDefinitions(reflect.ValueOf(def))...)
+		}
+	}
+	for defname := range s.Definitions {
+		if !kept[defname] {
+			delete(s.Definitions, defname)
+		}
+	}
+	return nil
+}
+
+// referencedDefinitions lists the names of the definitions referenced by the Ref fields found anywhere in a value.
+func referencedDefinitions(value reflect.Value) []string {
+	var names []string
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			names = referencedDefinitions(value.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.Name == "Ref" && field.Type.Kind() == reflect.String {
+				if ref := value.Field(i).String(); ref != "" {
+					names = append(names, ref[strings.LastIndex(ref, "/")+1:])
+				}
+			} else if field.IsExported() {
+				names = append(names, referencedDefinitions(value.Field(i))...)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			names = append(names, referencedDefinitions(value.Index(i))...)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			names = append(names, referencedDefinitions(value.MapIndex(key))...)
+		}
+	}
+	return names
+}
+
+// NameOverrides maps operations, by OperationId or generated method name, and definitions, by name or generated
+// type name, onto the Swift names generated for them instead.
+type NameOverrides struct {
+	Operations  map[string]string `json:"operations"`
+	Definitions map[string]string `json:"definitions"`
+}
+
+// applyNameOverrides names the methods of the overridden operations through their x-swift-name extension and
+// registers the overridden type names with typeName.
+func applyNameOverrides(s *Schema, overrides *NameOverrides) error {
+	operations := make(map[string]string, len(overrides.Operations))
+	for name, override := range overrides.Operations {
+		operations[name] = override
+	}
+	for _, methods := range s.Paths {
+		for _, operation := range methods {
+			for _, name := range []string{operation.OperationId, snakeToPascal(stripOperationPrefix(operation.OperationId))} {
+				override, ok := operations[name]
+				if !ok {
+					continue
+				}
+				if operation.Extensions == nil {
+					operation.Extensions = make(map[string]interface{})
+				}
+				operation.Extensions["x-swift-name"] = override
+				delete(operations, name)
+			}
+		}
+	}
+	for name := range operations {
+		return fmt.Errorf("unknown operation %q in the name overrides", name)
+	}
+
+	for name := range overrides.Definitions {
+		known := false
+		for defname := range s.Definitions {
+			known = known || defname == name || typeName(defname) == name
+		}
+		if !known {
+			return fmt.Errorf("unknown definition %q in the name overrides", name)
+		}
+	}
+	for name, override := range overrides.Definitions {
+		typeNameOverrides[name] = override
+	}
+	return nil
+}
+
+// operationName returns the name of the method generated for an operation, given by its x-swift-name extension or
+// derived from its OperationId.
+func operationName(operation *Operation) string {
+	if name, ok := operation.Extensions["x-swift-name"].(string); ok && name != "" {
+		return name
+	}
+	return snakeToPascal(stripOperationPrefix(operation.OperationId))
+}
+
+// RetryOverride is how an operation is retried, as declared by its x-idempotent extension and the maxRetries and
+// baseDelayMs of its x-retry extension, or an entry of a -retry-policies file.
+type RetryOverride struct {
+	Idempotent  *bool `json:"idempotent,omitempty"`
+	MaxRetries  *int  `json:"maxRetries,omitempty"`
+	BaseDelayMs *int  `json:"baseDelayMs,omitempty"`
+}
+
+// applyRetryOverrides sets the x-idempotent and x-retry extensions of the operations overridden by OperationId or
+// method name.
+func applyRetryOverrides(s *Schema, overrides map[string]RetryOverride) error {
+	remaining := make(map[string]RetryOverride, len(overrides))
+	for name, override := range overrides {
+		remaining[name] = override
+	}
+	for _, methods := range s.Paths {
+		for _, operation := range methods {
+			for _, name := range []string{operation.OperationId, operationName(operation)} {
+				override, ok := remaining[name]
+				if !ok {
+					continue
+				}
+				if operation.Extensions == nil {
+					operation.Extensions = make(map[string]interface{})
+				}
+				if override.Idempotent != nil {
+					operation.Extensions["x-idempotent"] = *override.Idempotent
+				}
+				if override.MaxRetries != nil || override.BaseDelayMs != nil {
+					operation.Extensions["x-retry"] = RetryOverride{MaxRetries: override.MaxRetries, BaseDelayMs: override.BaseDelayMs}
+				}
+				delete(remaining, name)
+			}
+		}
+	}
+	for name := range remaining {
+		return fmt.Errorf("unknown operation %q in the retry policies", name)
+	}
+	return nil
+}
+
+// writeMethods are the HTTP methods whose requests -outbox queues while offline.
+var writeMethods = map[string]bool{"post": true, "put": true, "patch": true, "delete": true}
+
+// queuesOffline reports whether the requests of an operation are queued in the outbox while offline: those of write
+// methods with -outbox, unless marked x-outbox: false or returning a session.
+func (s *Schema) queuesOffline(context OperationContext) bool {
+	if !s.Options.Outbox || !writeMethods[strings.ToLower(context.Method)] {
+		return false
+	}
+	if value, ok := context.Operation.Extensions["x-outbox"].(bool); ok && !value {
+		return false
+	}
+	// Authenticating offline makes no sense later, the session replaying returns is dropped.
+	model := successResponse(context.Operation).Model
+	for _, session := range s.sessionModels() {
+		if session.Type == model {
+			return false
+		}
+	}
+	return true
+}
+
+// idempotentMethods are the HTTP methods retried unless an x-idempotent extension says otherwise.
+var idempotentMethods = map[string]bool{"get": true, "head": true, "options": true, "put": true, "delete": true}
+
+// retryPolicy returns the RetryPolicy expression the requests of an operation are run with, or "" when they aren't
+// retried. Operations marked x-idempotent: false never retry, those with an x-retry extension use its policy, taking
+// what it leaves out from the client's, and the rest use the client's when their method is idempotent.
+func (s *Schema) retryPolicy(context OperationContext) (string, error) {
+	operation := context.Operation
+	if !s.Options.Retries {
+		return "", nil
+	}
+	idempotent := idempotentMethods[strings.ToLower(context.Method)]
+	if extension, ok := operation.Extensions["x-idempotent"]; ok {
+		value, ok := extension.(bool)
+		if !ok {
+			return "", fmt.Errorf("x-idempotent of %s isn't a boolean", operation.OperationId)
+		}
+		if !value {
+			return "", nil
+		}
+		idempotent = true
+	}
+	extension, ok := operation.Extensions["x-retry"]
+	if !ok {
+		if idempotent {
+			return "retryPolicy", nil
+		}
+		return "", nil
+	}
+	var override RetryOverride
+	data, err := json.Marshal(extension)
+	if err == nil {
+		err = json.Unmarshal(data, &override)
+	}
+	if err != nil {
+		return "", fmt.Errorf("x-retry of %s isn't a retry policy: %s", operation.OperationId, err)
+	}
+	maxRetries, baseDelayMs := "retryPolicy.maxRetries", "retryPolicy.baseDelayMs"
+	if override.MaxRetries != nil {
+		if *override.MaxRetries <= 0 {
+			return "", nil
+		}
+		maxRetries = strconv.Itoa(*override.MaxRetries)
+	}
+	if override.BaseDelayMs != nil {
+		if *override.BaseDelayMs < 0 {
+			return "", fmt.Errorf("the baseDelayMs of x-retry of %s is negative", operation.OperationId)
+		}
+		baseDelayMs = strconv.Itoa(*override.BaseDelayMs)
+	}
+	return fmt.Sprintf("RetryPolicy(maxRetries: %s, baseDelayMs: %s, maxDelayMs: retryPolicy.maxDelayMs, maxRetryAfterMs: retryPolicy.maxRetryAfterMs)", maxRetries, baseDelayMs), nil
+}
+
+// idempotencyKeyMethods are the HTTP methods whose requests send an Idempotency-Key when marked x-idempotent: true.
+var idempotencyKeyMethods = map[string]bool{"post": true, "put": true}
+
+// idempotencyKey reports whether the requests of an operation send an Idempotency-Key header, the same for all their
+// retries: those of POST and PUT operations marked x-idempotent: true with -retries, unless the operation declares
+// the header as a parameter.
+func (s *Schema) idempotencyKey(context OperationContext) bool {
+	operation := context.Operation
+	if !s.Options.Retries || !idempotencyKeyMethods[strings.ToLower(context.Method)] {
+		return false
+	}
+	if value, ok := operation.Extensions["x-idempotent"].(bool); !ok || !value {
+		return false
+	}
+	for _, parameter := range operation.Parameters {
+		if parameter.In == "header" && strings.EqualFold(parameter.Name, "Idempotency-Key") {
+			return false
+		}
+	}
+	return true
+}
+
+// SessionRefresh is the operation the generated SessionManager refreshes sessions through, named by -session-refresh.
+type SessionRefresh struct {
+	ClassName        string // the client class declaring the operation
+	Method           string
+	Credentials      bool // the operation takes Credentials
+	BodyName         string
+	BodyType         string
+	BodyRefreshToken string // the property of the body the refresh token is sent in
+	Session          SessionModel
+}
+
+// SessionModel is a model holding the token and refresh token of a session.
+type SessionModel struct {
+	Type                 string
+	Token                string // the Swift properties of the tokens
+	RefreshToken         string
+	TokenOptional        bool
+	RefreshTokenOptional bool
+}
+
+// sessionModel reports whether a definition is a session, with a token and a refresh token.
+func sessionModel(defname string, definition ObjectDefinition) (SessionModel, bool) {
+	token, hasToken := stringProperty(definition, "token")
+	refreshToken, hasRefreshToken := stringProperty(definition, "refreshToken")
+	if !hasToken || !hasRefreshToken {
+		return SessionModel{}, false
+	}
+	return SessionModel{
+		Type:                 typeName(defname),
+		Token:                swiftName(token),
+		RefreshToken:         swiftName(refreshToken),
+		TokenOptional:        isOptional(definition, token, definition.Properties[token]),
+		RefreshTokenOptional: isOptional(definition, refreshToken, definition.Properties[refreshToken]),
+	}, true
+}
+
+// sessionModels returns the session models which -session-type generates initializers of the session type from.
+func (s *Schema) sessionModels() []SessionModel {
+	var models []SessionModel
+	for _, defname := range s.definitionNames() {
+		if model, ok := sessionModel(defname, s.Definitions[defname]); ok {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// sessionRefresh resolves the -session-refresh operation, which has to send a refresh token as the only required
+// property of its body and return a session with a token and a refresh token. It returns nil without the flag.
+func (s *Schema) sessionRefresh() (*SessionRefresh, error) {
+	if s.Options.SessionRefresh == "" {
+		return nil, nil
+	}
+	for _, group := range s.tagGroups() {
+		for _, context := range append(group.Operations, group.Deprecated...) {
+			operation := context.Operation
+			if operation.OperationId != s.Options.SessionRefresh {
+				continue
+			}
+
+			refresh := &SessionRefresh{
+				ClassName:   "ApiClient",
+				Method:      operationName(operation),
+				Credentials: len(s.operationCredentials(operation)) > 0,
+			}
+			if s.Options.SplitTags {
+				refresh.ClassName = group.ClassName
+			}
+			for _, parameter := range operation.Parameters {
+				if parameter.In != "body" || parameter.Schema.Ref == "" {
+					return nil, fmt.Errorf("the session refresh operation %s takes parameters besides its body", operation.OperationId)
+				}
+				body := s.Definitions[parameter.Schema.Ref[strings.LastIndex(parameter.Schema.Ref, "/")+1:]]
+				name, ok := stringProperty(body, "refreshToken")
+				if !ok {
+					return nil, fmt.Errorf("the body of the session refresh operation %s has no refresh token", operation.OperationId)
+				}
+				for _, required := range body.Required {
+					if required != name {
+						return nil, fmt.Errorf("the body of the session refresh operation %s requires %s", operation.OperationId, required)
+					}
+				}
+				refresh.BodyName = swiftName(parameter.Name)
+				refresh.BodyType = convertRefToClassName(parameter.Schema.Ref)
+				refresh.BodyRefreshToken = swiftName(name)
+			}
+			if refresh.BodyType == "" {
+				return nil, fmt.Errorf("the session refresh operation %s has no body", operation.OperationId)
+			}
+
+			for _, session := range s.sessionModels() {
+				if session.Type == successResponse(operation).Model {
+					refresh.Session = session
+					return refresh, nil
+				}
+			}
+			return nil, fmt.Errorf("the session refresh operation %s doesn't return a token and a refresh token", operation.OperationId)
+		}
+	}
+	return nil, fmt.Errorf("unknown session refresh operation %s", s.Options.SessionRefresh)
+}
+
+// stringProperty finds the string property of a definition named, in camel or snake case, as name.
+func stringProperty(definition ObjectDefinition, name string) (string, bool) {
+	for propname, property := range definition.Properties {
+		if property.Type == "string" && snakeToCamel(propname) == name {
+			return propname, true
+		}
+	}
+	return "", false
+}
+
+// supportTypeNames are the types generated alongside the models, which no definition may be named as.
+var supportTypeNames = []string{
+	"AnyCodable", "ApiClient", "ApiResponseError", "BuilderError", "CircuitBreakerAdapter", "CircuitOpenError",
+	"ClientMetricsListener", "ClientVersion", "Credentials", "Cursor", "EmptyResponse", "FileOutboxStore",
+	"GrpcStatusCode", "Gzip", "HttpRequest", "JSONCoders", "JSONLines", "JWT", "LoggerProtocol", "MultipartFormData",
+	"NoopLogger", "OAuth2Token", "Outbox", "OutboxConflictResolution", "OutboxQueuedError", "OutboxRequest",
+	"OutboxStore", "RateLimitedError", "RequestInterceptor", "RequestMetrics", "ResponseInterceptor", "RetryPolicy",
+	"ServerSentEvents", "SessionManager", "StringCoding",
+}
+
+// checkCollisions fails when two definitions generate the same Swift type, a definition generates a type named as
+// a support type, or two operations generate the same method of a client class.
+func (s *Schema) checkCollisions() error {
+	types := make(map[string]string)
+	for _, name := range supportTypeNames {
+		types[name] = "the support type " + name
+	}
+	if s.Options.SessionType != "" {
+		types[s.Options.SessionType] = "the session type"
+	}
+	for _, defname := range s.definitionNames() {
+		name := typeName(defname)
+		if other, ok := types[name]; ok {
+			return fmt.Errorf("definition %s and %s both generate the type %s, rename one with -names", defname, other, name)
+		}
+		types[name] = "definition " + defname
+	}
+
+	groups := []TagGroup{{ClassName: "ApiClient", Operations: s.operations()}}
+	if s.Options.SplitTags {
+		groups = s.tagGroups()
+		properties := make(map[string]string)
+		for _, group := range groups {
+			if other, ok := types[group.ClassName]; ok {
+				return fmt.Errorf("tag %s and %s both generate the type %s, rename the tag", group.Name, other, group.ClassName)
+			}
+			types[group.ClassName] = "tag " + group.Name
+			if other, ok := properties[group.Property]; ok {
+				return fmt.Errorf("tags %s and %s both generate the ApiClient property %s, rename one", other, group.Name, group.Property)
+			}
+			properties[group.Property] = group.Name
+		}
+	}
+
+	for _, group := range groups {
+		methods := make(map[string]string)
+		for _, operation := range append(group.Operations, group.Deprecated...) {
+			for _, method := range s.operationMethods(operation.Operation) {
+				if other, ok := methods[method]; ok {
+					return fmt.Errorf("operations %s and %s both generate the method %s.%s, rename one with -names", other, operation.Operation.OperationId, group.ClassName, method)
+				}
+				methods[method] = operation.Operation.OperationId
+			}
+		}
+	}
+	return nil
+}
+
+// operationMethods lists the names of the methods the client generates for an operation, the async one and its
+// Download, Publisher, Result and Pages variants. The completion handler variant overloads the async one.
+func (s *Schema) operationMethods(operation *Operation) []string {
+	name := operationName(operation)
+	methods := []string{name}
+	if isDownload(operation) {
+		methods = append(methods, name+"Download")
+	}
+	if s.publisherType(operation) != "" {
+		methods = append(methods, name+"Publisher")
+	}
+	if s.resultType(operation) != "" {
+		methods = append(methods, name+"Result")
+	}
+	if s.pagination(operation) != nil {
+		methods = append(methods, name+"Pages")
+	}
+	return methods
+}
+
+// dropDeprecatedOperations removes the deprecated operations, and the paths left without any, from the schema.
+func dropDeprecatedOperations(s *Schema) {
+	for url, methods := range s.Paths {
+		for method, operation := range methods {
+			if operation.Deprecated {
+				delete(methods, method)
+			}
+		}
+		if len(methods) == 0 {
+			delete(s.Paths, url)
+		}
+	}
+}
+
+// tagGroups groups the operations by their first tag, untagged operations are grouped under "Default".
+func (s *Schema) tagGroups() []TagGroup {
+	groups := make(map[string]*TagGroup)
+	var names []string
+	for _, operation := range s.operations() {
+		name := "Default"
+		if len(operation.Operation.Tags) > 0 {
+			name = operation.Operation.Tags[0]
+		}
+
+		group, ok := groups[name]
+		if !ok {
+			identifier := swiftIdentifier(name)
+			group = &TagGroup{
+				Name:      name,
+				ClassName: identifier + "ApiClient",
+				Property:  swiftName(pascalToCamel(identifier)),
+			}
+			groups[name] = group
+			names = append(names, name)
+		}
+		if s.isIsolated(operation) {
+			group.Deprecated = append(group.Deprecated, operation)
+		} else {
+			group.Operations = append(group.Operations, operation)
+		}
+	}
+	sort.Strings(names)
+
+	tagGroups := make([]TagGroup, 0, len(names))
+	for _, name := range names {
+		tagGroups = append(tagGroups, *groups[name])
+	}
+
+	return tagGroups
+}
+
+// swiftIdentifier turns a name which may hold any character into a Pascal case Swift identifier.
+func swiftIdentifier(name string) string {
+	return snakeToPascal(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name))
+}
+
+// formatCode tidies up the output of the template, ending it with a single newline and indenting the declarations
+// nested in the namespace.
+func formatCode(code []byte, schema *Schema) []byte {
+	code = append(bytes.TrimRight(code, "\n"), '\n')
+	if schema.Options.WrapNamespace {
+		code = indentNamespace(code, schema.Namespace)
+	}
+	return code
+}
+
+// indentNamespace indents the declarations nested in the namespace extension, which is written flush left by the
+// template, and drops the blank line the first declaration starts with.
+func indentNamespace(code []byte, namespace string) []byte {
+	lines := strings.Split(string(code), "\n")
+	indented := make([]string, 0, len(lines))
+	nested := false
+	for _, line := range lines {
+		switch {
+		case line == "extension "+namespace+" {":
+			nested = true
+		case line == "} // "+namespace:
+			nested = false
+		case nested && line == "" && indented[len(indented)-1] == "extension "+namespace+" {":
+			continue
+		case nested && line != "":
+			line = "    " + line
+		}
+		indented = append(indented, line)
+	}
+	return []byte(strings.Join(indented, "\n"))
+}
+
+// DefinitionContext is the data of the template generating a single definition.
+type DefinitionContext struct {
+	Name       string
+	Definition ObjectDefinition
+	Options    Options
+}
+
+func (s *Schema) definitionContext(name string) DefinitionContext {
+	return DefinitionContext{Name: name, Definition: s.Definitions[name], Options: s.Options}
+}
+
+// templateCall is a named template of the code template together with the data it is executed with.
+type templateCall struct {
+	Name string
+	Data interface{}
+}
+
+// renderFile executes templates into the content of a Swift file, nesting the declarations in the namespace when
+// it wraps the generated types, and declaring the namespace itself when asked to. Extensions of types which aren't
+// generated follow the namespace. Files without any declarations or extensions are rendered as nil.
+func renderFile(tmpl *template.Template, schema *Schema, declareNamespace bool, declarations []templateCall, extensions []templateCall) ([]byte, error) {
+	var declared, extended bytes.Buffer
+	for _, call := range declarations {
+		if err := tmpl.ExecuteTemplate(&declared, call.Name, call.Data); err != nil {
+			return nil, err
+		}
+	}
+	for _, call := range extensions {
+		if err := tmpl.ExecuteTemplate(&extended, call.Name, call.Data); err != nil {
+			return nil, err
+		}
+	}
+	if len(bytes.TrimSpace(declared.Bytes())) == 0 && len(bytes.TrimSpace(extended.Bytes())) == 0 {
+		return nil, nil
+	}
+
+	var code bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&code, "fileHeader", schema); err != nil {
+		return nil, err
+	}
+	if declareNamespace {
+		if err := tmpl.ExecuteTemplate(&code, "namespaceDeclaration", schema); err != nil {
+			return nil, err
+		}
+	}
+	if declared.Len() > 0 {
+		if err := tmpl.ExecuteTemplate(&code, "namespaceStart", schema); err != nil {
+			return nil, err
+		}
+		code.Write(declared.Bytes())
+		if err := tmpl.ExecuteTemplate(&code, "namespaceEnd", schema); err != nil {
+			return nil, err
+		}
+	}
+	code.Write(extended.Bytes())
+
+	return formatCode(code.Bytes(), schema), nil
+}
+
+// writeTemplateFile writes a file rendered by a single template, such as an adapter, to path.
+func writeTemplateFile(tmpl *template.Template, schema *Schema, name string, path string) error {
+	var code bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&code, name, schema); err != nil {
+		return err
+	}
+	return os.WriteFile(path, formatCode(code.Bytes(), schema), 0644)
+}
+
+// writeSeparateFiles writes the models and the client into separate files so the models can be used without the
+// HTTP layer. The models file declares the namespace the client is nested in as well. Either path may be empty to
+// skip writing that file.
+func writeSeparateFiles(tmpl *template.Template, schema *Schema, modelsPath string, clientPath string) error {
+	if modelsPath != "" {
+		declarations := []templateCall{{"modelSupport", schema}}
+		for _, name := range schema.definitionNames() {
+			declarations = append(declarations, templateCall{"definition", schema.definitionContext(name)})
+		}
+		code, err := renderFile(tmpl, schema, true, declarations, []templateCall{{"modelExtensions", schema}})
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(modelsPath, code, 0644); err != nil {
+			return err
+		}
+	}
+
+	if clientPath != "" {
+		declarations := []templateCall{{"clientSupport", schema}, {"client", schema}}
+		code, err := renderFile(tmpl, schema, false, declarations, []templateCall{{"clientExtensions", schema}})
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(clientPath, code, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// definitionNames lists the names of the definitions in the order the template ranges over them.
+func (s *Schema) definitionNames() []string {
+	names := make([]string, 0, len(s.Definitions))
+	for name := range s.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeSplitFiles writes the support code, every definition and the client into separate files under dir, along
+// with a manifest.json listing the files.
+func writeSplitFiles(tmpl *template.Template, schema *Schema, dir string) error {
+	files := map[string][]byte{}
+	add := func(name string, declarations []templateCall, extensions []templateCall) error {
+		code, err := renderFile(tmpl, schema, false, declarations, extensions)
+		if err != nil {
+			return err
+		}
+		if code != nil {
+			files[name] = code
+		}
+		return nil
+	}
+
+	if schema.Options.WrapNamespace {
+		var code bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&code, "fileHeader", schema); err != nil {
+			return err
+		}
+		if err := tmpl.ExecuteTemplate(&code, "namespaceDeclaration", schema); err != nil {
+			return err
+		}
+		files[schema.Namespace+".swift"] = formatCode(code.Bytes(), schema)
+	}
+	if err := add("Models/Support.swift", []templateCall{{"modelSupport", schema}}, []templateCall{{"modelExtensions", schema}}); err != nil {
+		return err
+	}
+	for name := range schema.Definitions {
+		if err := add("Models/"+typeName(name)+".swift", []templateCall{{"definition", schema.definitionContext(name)}}, nil); err != nil {
+			return err
+		}
+	}
+	if err := add("Client/Support.swift", []templateCall{{"clientSupport", schema}}, []templateCall{{"clientExtensions", schema}}); err != nil {
+		return err
+	}
+	if err := add("Client/ApiClient.swift", []templateCall{{"client", schema}}, nil); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for name, code := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), code, 0644); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "manifest.json"), append(manifest, '\n'), 0644)
+}
+
+// isEnumEncoding reports whether an enum encoding is one the generator supports.
+func isEnumEncoding(encoding string) bool {
+	switch encoding {
+	case "string", "int", "either":
+		return true
+	default:
+		return false
+	}
+}
+
+// enumEncoding returns how an enum definition is encoded, its x-enum-encoding extension taking precedence over
+// the -enum-encoding flag.
+func (s *Schema) enumEncoding(defname string) (string, error) {
+	override, ok := s.Definitions[defname].Extensions["x-enum-encoding"]
+	if !ok {
+		return s.Options.EnumEncoding, nil
+	}
+
+	encoding, _ := override.(string)
+	if !isEnumEncoding(encoding) {
+		return "", fmt.Errorf("unknown x-enum-encoding %v of %s", override, defname)
+	}
+	return encoding, nil
+}
+
+// securityCredentials lists every security scheme of the document, ordered by name.
+func (s *Schema) securityCredentials() []Credential {
+	names := make([]string, 0, len(s.SecurityDefinitions))
+	for name := range s.SecurityDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	credentials := make([]Credential, 0, len(names))
+	for _, name := range names {
+		credentials = append(credentials, s.credential(name))
+	}
+
+	return credentials
+}
+
+// operationCredentials lists the security schemes accepted by an operation, ordered by name. Operations without
+// their own security requirements use the document wide ones.
+func (s *Schema) operationCredentials(operation *Operation) []Credential {
+	security := operation.Security
+	if security == nil {
+		security = s.Security
+	}
+
+	var names []string
+	for _, requirement := range security {
+		for name := range requirement {
+			if _, ok := s.SecurityDefinitions[name]; ok {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var credentials []Credential
+	for idx, name := range names {
+		if idx == 0 || names[idx-1] != name {
+			credentials = append(credentials, s.credential(name))
+		}
+	}
+
+	return credentials
+}
+
+// credential describes how the secret of a security scheme is sent.
+func (s *Schema) credential(name string) Credential {
+	scheme := s.SecurityDefinitions[name]
+	credential := Credential{
+		Name:        name,
+		Property:    swiftName(pascalToCamel(swiftIdentifier(name))),
+		Key:         scheme.Name,
+		Description: scheme.Description,
+	}
+
+	switch {
+	case scheme.Type == "basic":
+		credential.Kind = "basic"
+	case scheme.Type == "apiKey" && strings.EqualFold(scheme.Name, "Authorization") && scheme.In == "header":
+		// grpc-gateway documents bearer tokens as an API key in the Authorization header.
+		credential.Kind = "bearer"
+	case scheme.Type == "apiKey":
+		credential.Kind = scheme.In
+	default:
+		// HTTP bearer, OAuth2 and OpenID Connect all send a bearer token.
+		credential.Kind = "bearer"
+	}
+
+	if credential.Description == "" {
+		switch credential.Kind {
+		case "basic":
+			credential.Description = "The username and password of HTTP basic authentication."
+		case "bearer":
+			credential.Description = "The bearer token sent in the Authorization header."
+		default:
+			credential.Description = fmt.Sprintf("The API key sent in the %s %s.", scheme.Name, credential.Kind)
+		}
+	}
+
+	return credential
+}
+
+// oauth2Flows lists the OAuth2 security schemes of the document, ordered by name.
+func (s *Schema) oauth2Flows() []OAuth2Flow {
+	var flows []OAuth2Flow
+	for _, credential := range s.securityCredentials() {
+		scheme := s.SecurityDefinitions[credential.Name]
+		if scheme.Type != "oauth2" {
+			continue
+		}
+
+		flow := OAuth2Flow{
+			Credential: credential,
+			TokenUrl:   scheme.TokenUrl,
+			RefreshUrl: scheme.RefreshUrl,
+		}
+		if scheme.Flow == "accessCode" {
+			flow.AuthorizationUrl = scheme.AuthorizationUrl
+		}
+		if flow.RefreshUrl == "" && scheme.Flow != "implicit" {
+			flow.RefreshUrl = scheme.TokenUrl
+		}
+		flows = append(flows, flow)
+	}
+
+	return flows
+}
+
+// usesCookies reports whether an operation sends cookie parameters or cookie API keys.
+func (s *Schema) usesCookies(operation *Operation) bool {
+	for _, param := range operation.Parameters {
+		if param.In == "cookie" {
+			return true
+		}
+	}
+	for _, credential := range s.operationCredentials(operation) {
+		if credential.Kind == "cookie" {
+			return true
+		}
+	}
+	return false
+}
+
+// collectionFormatOf maps the OpenAPI 3.x style and explode of an array parameter onto a Swagger 2.0 collectionFormat.
+func collectionFormatOf(param Parameter) string {
+	explode := param.Explode == nil || *param.Explode
+	switch param.Style {
+	case "spaceDelimited":
+		return "ssv"
+	case "pipeDelimited":
+		return "pipes"
+	case "", "form":
+		if explode {
+			return "multi"
+		}
+	}
+	return "csv"
+}
+
+// collectionSeparator returns the separator joining the values of an array parameter, or an empty string when every
+// value is sent as its own query item.
+func collectionSeparator(param Parameter) string {
+	switch param.CollectionFormat {
+	case "multi":
+		return ""
+	case "ssv":
+		return " "
+	case "tsv":
+		return "\t"
+	case "pipes":
+		return "|"
+	default:
+		// csv is the Swagger 2.0 default.
+		return ","
+	}
+}
+
+// wellKnownTypes maps protobuf well-known types onto the primitive schema they are written as in JSON.
+var wellKnownTypes = map[string]Items{
+	"timestamp":   {Type: "string", Format: "date-time"},
+	"duration":    {Type: "string", Format: "duration"},
+	"bytesvalue":  {Type: "string", Format: "byte"},
+	"stringvalue": {Type: "string"},
+	"boolvalue":   {Type: "boolean"},
+	"int32value":  {Type: "integer", Format: "int32"},
+	"uint32value": {Type: "integer", Format: "uint32"},
+	"int64value":  {Type: "string", Format: "int64"},
+	"uint64value": {Type: "string", Format: "uint64"},
+	"doublevalue": {Type: "number", Format: "double"},
+	"floatvalue":  {Type: "number", Format: "float"},
+	"struct":      {Type: "object"},
+}
+
+// addWellKnownTypes parses name=type[:format] rules, such as "int64value=integer:int64", into wellKnownTypes. An
+// empty type generates the definition of the well-known type as is.
+func addWellKnownTypes(rules string) error {
+	for _, rule := range strings.Split(rules, ",") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("invalid well-known type rule %q, expected name=type[:format]", rule)
+		}
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if strings.TrimSpace(parts[1]) == "" {
+			delete(wellKnownTypes, name)
+			continue
+		}
+
+		typeAndFormat := strings.SplitN(strings.TrimSpace(parts[1]), ":", 2)
+		wellKnown := Items{Type: typeAndFormat[0]}
+		if len(typeAndFormat) == 2 {
+			wellKnown.Format = typeAndFormat[1]
+		}
+		switch wellKnown.Type {
+		case "string", "integer", "number", "boolean", "object":
+		default:
+			return fmt.Errorf("unknown type %q in rule %q", wellKnown.Type, rule)
+		}
+		wellKnownTypes[name] = wellKnown
+	}
+
+	return nil
+}
+
+// wellKnownName returns the lowercase name of the protobuf well-known type a definition name refers to, such as
+// "timestamp" for "protobufTimestamp" or "google.protobuf.Timestamp".
+func wellKnownName(name string) (string, bool) {
+	name = strings.ToLower(name)
+	for _, prefix := range []string{"google.protobuf.", "googleprotobuf", "protobuf"} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix), true
+		}
+	}
+	return "", false
+}
+
+// wellKnownType looks up the protobuf well-known type a definition name refers to.
+func wellKnownType(name string) (Items, bool) {
+	if name, ok := wellKnownName(name); ok {
+		wellKnown, ok := wellKnownTypes[name]
+		return wellKnown, ok
+	}
+	return Items{}, false
+}
+
+// isWrapperType reports whether a definition name refers to a protobuf wrapper type, such as BoolValue, whose
+// absence means something else than its default value.
+func isWrapperType(name string) bool {
+	name, ok := wellKnownName(name)
+	return ok && strings.HasSuffix(name, "value")
+}
+
+// inlineWellKnownTypes replaces the references to protobuf well-known type definitions with the primitive schema
+// they are written as, and drops the definitions so no wrapper types are generated for them.
+func inlineWellKnownTypes(s *Schema) {
+	inline := func(ref string) (Items, bool) {
+		if ref == "" {
+			return Items{}, false
+		}
+		return wellKnownType(ref[strings.LastIndex(ref, "/")+1:])
+	}
+
+	for defname, def := range s.Definitions {
+		for propname, property := range def.Properties {
+			if wellKnown, ok := inline(property.Ref); ok {
+				property.Wrapper = isWrapperType(property.Ref[strings.LastIndex(property.Ref, "/")+1:])
+				property.Ref = ""
+				property.Type = wellKnown.Type
+				property.Format = wellKnown.Format
+			}
+			if wellKnown, ok := inline(property.Items.Ref); ok {
+				property.Items = wellKnown
+			}
+			if wellKnown, ok := inline(property.AdditionalProperties.Ref); ok {
+				property.AdditionalProperties = AdditionalProperties{Type: wellKnown.Type, Format: wellKnown.Format}
+			}
+			def.Properties[propname] = property
+		}
+		s.Definitions[defname] = def
+	}
+
+	for defname := range s.Definitions {
+		if _, ok := wellKnownType(defname); ok {
+			delete(s.Definitions, defname)
+		}
+	}
+}
+
+// aliasMapDefinitions turns the definitions which are plain maps, having additionalProperties but no properties,
+// into typealiases of Swift dictionaries.
+func aliasMapDefinitions(s *Schema) {
+	for defname, def := range s.Definitions {
+		if def.AdditionalProperties == nil || len(def.Properties) > 0 || len(def.Enum) > 0 || len(def.OneOf) > 0 || len(def.AnyOf) > 0 {
+			continue
+		}
+		def.Alias = "[String: " + valueType(*def.AdditionalProperties) + "]"
+		s.Definitions[defname] = def
+	}
+}
+
+// recursiveDefinitions finds the definitions which contain themselves through properties and oneOf schemas which
+// are stored inline. Arrays and maps store their elements out of line so they don't count.
+func recursiveDefinitions(s *Schema) map[string]bool {
+	names := make(map[string]string, len(s.Definitions))
+	for defname := range s.Definitions {
+		names[typeName(defname)] = defname
+	}
+
+	edges := make(map[string][]string, len(s.Definitions))
+	for defname, def := range s.Definitions {
+		var refs []string
+		for _, property := range def.Properties {
+			refs = append(refs, property.Ref)
+			for _, schema := range property.AnyOf {
+				refs = append(refs, schema.Ref)
+			}
+		}
+		for _, schema := range def.OneOf {
+			refs = append(refs, schema.Ref)
+		}
+
+		for _, ref := range refs {
+			if ref == "" {
+				continue
+			}
+			if target, ok := names[convertRefToClassName(ref)]; ok {
+				edges[defname] = append(edges[defname], target)
+			}
+		}
+	}
+
+	recursive := make(map[string]bool)
+	for defname := range s.Definitions {
+		visited := make(map[string]bool)
+		stack := append([]string(nil), edges[defname]...)
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if current == defname {
+				recursive[defname] = true
+				break
+			}
+			if visited[current] {
+				continue
+			}
+			visited[current] = true
+			stack = append(stack, edges[current]...)
+		}
+	}
+
+	return recursive
+}
+
+// ProtoFile is a parsed .proto file.
+type ProtoFile struct {
+	Path     string
+	Package  string
+	Imports  []string
+	Messages []*ProtoMessage
+	Enums    []*ProtoEnum
+}
+
+// ProtoMessage is a message of a .proto file, along with the messages and enums nested in it.
+type ProtoMessage struct {
+	Name     string
+	FullName string // qualified by the package and the messages it is nested in
+	Package  string
+	Doc      string
+	Parent   *ProtoMessage
+	Fields   []*ProtoField // the fields which aren't part of a oneof
+	Oneofs   []*ProtoOneof
+	Messages []*ProtoMessage
+	Enums    []*ProtoEnum
+}
+
+// SwiftName is the name of the Swift struct of the message.
+func (m *ProtoMessage) SwiftName() string {
+	return swiftName(m.Name)
+}
+
+// swiftPath is the Swift type of the message within the namespace of its package.
+func (m *ProtoMessage) swiftPath() string {
+	if m.Parent == nil {
+		return m.SwiftName()
+	}
+	return m.Parent.swiftPath() + "." + m.SwiftName()
+}
+
+// AllFields are the fields of the message, followed by those of its oneofs.
+func (m *ProtoMessage) AllFields() []*ProtoField {
+	fields := append([]*ProtoField(nil), m.Fields...)
+	for _, oneof := range m.Oneofs {
+		fields = append(fields, oneof.Fields...)
+	}
+	return fields
+}
+
+// ProtoProperty is a property of the Swift struct of a message, as taken by its initializer.
+type ProtoProperty struct {
+	Name    string
+	Type    string
+	Default string
+}
+
+// Properties are the properties of the Swift struct of the message, one per field and one per oneof.
+func (m *ProtoMessage) Properties() []ProtoProperty {
+	var properties []ProtoProperty
+	for _, field := range m.Fields {
+		properties = append(properties, ProtoProperty{field.Property(), field.SwiftType(), field.DefaultValue()})
+	}
+	for _, oneof := range m.Oneofs {
+		properties = append(properties, ProtoProperty{oneof.Property(), oneof.TypeName() + "?", "nil"})
+	}
+	return properties
+}
+
+// ProtoOneof is a oneof of a message, generated as a Swift enum with a case per field.
+type ProtoOneof struct {
+	Name   string
+	Doc    string
+	Fields []*ProtoField
+}
+
+// TypeName is the name of the Swift enum of the oneof.
+func (o *ProtoOneof) TypeName() string {
+	return swiftName(snakeToPascal(o.Name))
+}
+
+// Property is the Swift property holding the case of the oneof which is set.
+func (o *ProtoOneof) Property() string {
+	return swiftName(snakeToCamel(o.Name))
+}
+
+// ProtoField is a field of a message, or a case of one of its oneofs.
+type ProtoField struct {
+	Name     string
+	Number   int
+	Doc      string
+	Type     string // the type as written, resolved into Value
+	KeyType  string // the key type of a map
+	Repeated bool
+	Optional bool // declared optional, or of a type with presence such as a message
+	Value    protoValue
+}
+
+// protoValue is how the values of a field are represented in Swift and coded in JSON.
+type protoValue struct {
+	Swift   string // the Swift type of the values
+	Coded   string // the type the values are coded as, empty when they are coded as themselves
+	Decode  string // turns a coded $0 into a value
+	Encode  string // turns a value $0 into its coded form
+	Default string // the proto3 default value, empty for values with presence
+
+	Wire     string // the SwiftProtobuf name of the type the values are coded as, as in decodeSingularStringField
+	WireType string // the Swift type SwiftProtobuf codes the values as, empty when it codes them as themselves
+	ToWire   string // turns a value $0 into its SwiftProtobuf form
+	FromWire string // turns a SwiftProtobuf $0 into a value
+	Packed   bool   // repeated values are packed
+}
+
+// WireSwiftType is the Swift type SwiftProtobuf codes the values as.
+func (v protoValue) WireSwiftType() string {
+	if v.WireType != "" {
+		return v.WireType
+	}
+	return v.Swift
+}
+
+// Property is the Swift property of the field, or the case of its oneof.
+func (f *ProtoField) Property() string {
+	return swiftName(snakeToCamel(f.Name))
+}
+
+// SwiftType is the Swift type of the property of the field.
+func (f *ProtoField) SwiftType() string {
+	switch {
+	case f.KeyType != "":
+		return "[String: " + f.Value.Swift + "]"
+	case f.Repeated:
+		return "[" + f.Value.Swift + "]"
+	case f.Optional:
+		return f.Value.Swift + "?"
+	}
+	return f.Value.Swift
+}
+
+// DefaultValue is the value of the property of the field when it is missing from the JSON.
+func (f *ProtoField) DefaultValue() string {
+	switch {
+	case f.KeyType != "":
+		return "[:]"
+	case f.Repeated:
+		return "[]"
+	case f.Optional:
+		return "nil"
+	}
+	return f.Value.Default
+}
+
+// CodedType is the type the field is coded as.
+func (f *ProtoField) CodedType() string {
+	coded := f.Value.Coded
+	if coded == "" {
+		coded = f.Value.Swift
+	}
+	switch {
+	case f.KeyType != "":
+		return "[String: " + coded + "]"
+	case f.Repeated:
+		return "[" + coded + "]"
+	}
+	return coded
+}
+
+// Decoding is the expression decoding the field from the keyed container.
+func (f *ProtoField) Decoding() string {
+	decoding := fmt.Sprintf("try container.decodeIfPresent(%s.self, forKey: .%s)", f.CodedType(), f.Property())
+	if f.Value.Decode != "" {
+		switch {
+		case f.KeyType != "":
+			decoding += "?.mapValues { " + f.Value.Decode + " }"
+		case f.Repeated:
+			decoding += "?.map { " + f.Value.Decode + " }"
+		default:
+			decoding += ".map { " + f.Value.Decode + " }"
+		}
+	}
+	if f.DefaultValue() == "nil" {
+		return decoding
+	}
+	return decoding + " ?? " + f.DefaultValue()
+}
+
+// Encoding is the statement encoding the field into the keyed container.
+func (f *ProtoField) Encoding() string {
+	value := f.Property()
+	if f.Value.Encode != "" {
+		switch {
+		case f.KeyType != "":
+			value += ".mapValues { " + f.Value.Encode + " }"
+		case f.Repeated || f.Optional:
+			value += ".map { " + f.Value.Encode + " }"
+		default:
+			value = strings.ReplaceAll(f.Value.Encode, "$0", value)
+		}
+	}
+	if f.DefaultValue() == "nil" {
+		return fmt.Sprintf("try container.encodeIfPresent(%s, forKey: .%s)", value, f.Property())
+	}
+	return fmt.Sprintf("try container.encode(%s, forKey: .%s)", value, f.Property())
+}
+
+// CaseValue is the value of the oneof case of the field, from its decoded value.
+func (f *ProtoField) CaseValue() string {
+	if f.Value.Decode == "" {
+		return "value"
+	}
+	return strings.ReplaceAll(f.Value.Decode, "$0", "value")
+}
+
+// CaseEncoding is the coded form of the value of the oneof case of the field.
+func (f *ProtoField) CaseEncoding() string {
+	if f.Value.Encode == "" {
+		return "value"
+	}
+	return strings.ReplaceAll(f.Value.Encode, "$0", "value")
+}
+
+// wireMap is the SwiftProtobuf type of a map field.
+func (f *ProtoField) wireMap() string {
+	if f.Value.Wire == "Message" {
+		return "SwiftProtobuf._ProtobufMessageMap<SwiftProtobuf.ProtobufString, " + f.Value.WireSwiftType() + ">"
+	}
+	return "SwiftProtobuf._ProtobufMap<SwiftProtobuf.ProtobufString, SwiftProtobuf.Protobuf" + f.Value.Wire + ">"
+}
+
+// wireValue turns the value of the field, or of its oneof case, into its SwiftProtobuf form.
+func (f *ProtoField) wireValue(value string) string {
+	if f.Value.ToWire == "" {
+		return value
+	}
+	return strings.ReplaceAll(f.Value.ToWire, "$0", value)
+}
+
+// fromWire turns the SwiftProtobuf form of a value of the field, or of its oneof case, into the value.
+func (f *ProtoField) fromWire(value string) string {
+	if f.Value.FromWire == "" {
+		return value
+	}
+	return strings.ReplaceAll(f.Value.FromWire, "$0", value)
+}
+
+// WireDecoding are the statements decoding the field from a SwiftProtobuf decoder.
+func (f *ProtoField) WireDecoding() []string {
+	property := "self." + f.Property()
+	var decode string
+	switch {
+	case f.KeyType != "":
+		decode = "try decoder.decodeMapField(fieldType: " + f.wireMap() + ".self, value: &%s)"
+	case f.Repeated:
+		decode = "try decoder.decodeRepeated" + f.Value.Wire + "Field(value: &%s)"
+	default:
+		decode = "try decoder.decodeSingular" + f.Value.Wire + "Field(value: &%s)"
+	}
+	if f.Value.ToWire == "" {
+		return []string{fmt.Sprintf(decode, property)}
+	}
+	switch {
+	case f.KeyType != "":
+		return []string{
+			"var value = " + property + ".mapValues { " + f.Value.ToWire + " }",
+			fmt.Sprintf(decode, "value"),
+			property + " = value.mapValues { " + f.Value.FromWire + " }",
+		}
+	case f.Repeated || f.Optional:
+		return []string{
+			"var value = " + property + ".map { " + f.Value.ToWire + " }",
+			fmt.Sprintf(decode, "value"),
+			property + " = value.map { " + f.Value.FromWire + " }",
+		}
+	}
+	return []string{
+		"var value = " + f.wireValue(property),
+		fmt.Sprintf(decode, "value"),
+		property + " = " + f.fromWire("value"),
+	}
+}
+
+// WireTraversal are the statements visiting the field with a SwiftProtobuf visitor, unless it holds its default.
+func (f *ProtoField) WireTraversal() []string {
+	property := "self." + f.Property()
+	switch {
+	case f.KeyType != "":
+		value := property
+		if f.Value.ToWire != "" {
+			value += ".mapValues { " + f.Value.ToWire + " }"
+		}
+		return []string{
+			"if !" + property + ".isEmpty {",
+			fmt.Sprintf("    try visitor.visitMapField(fieldType: %s.self, value: %s, fieldNumber: %d)", f.wireMap(), value, f.Number),
+			"}",
+		}
+	case f.Repeated:
+		value := property
+		if f.Value.ToWire != "" {
+			value += ".map { " + f.Value.ToWire + " }"
+		}
+		kind := "Repeated"
+		if f.Value.Packed {
+			kind = "Packed"
+		}
+		return []string{
+			"if !" + property + ".isEmpty {",
+			fmt.Sprintf("    try visitor.visit%s%sField(value: %s, fieldNumber: %d)", kind, f.Value.Wire, value, f.Number),
+			"}",
+		}
+	case f.Optional:
+		return []string{
+			"if let value = " + property + " {",
+			fmt.Sprintf("    try visitor.visitSingular%sField(value: %s, fieldNumber: %d)", f.Value.Wire, f.wireValue("value"), f.Number),
+			"}",
+		}
+	}
+	return []string{
+		"if " + property + " != " + f.Value.Default + " {",
+		fmt.Sprintf("    try visitor.visitSingular%sField(value: %s, fieldNumber: %d)", f.Value.Wire, f.wireValue(property), f.Number),
+		"}",
+	}
+}
+
+// CaseFromWire is the value of the oneof case of the field, from its SwiftProtobuf form.
+func (f *ProtoField) CaseFromWire() string {
+	return f.fromWire("value")
+}
+
+// CaseToWire is the SwiftProtobuf form of the value of the oneof case of the field.
+func (f *ProtoField) CaseToWire() string {
+	return f.wireValue("value")
+}
+
+// NameMapEntry is the entry of the field in the SwiftProtobuf name map of its message.
+func (f *ProtoField) NameMapEntry() string {
+	if strings.Contains(f.Name, "_") {
+		return fmt.Sprintf("%d: .standard(proto: %q)", f.Number, f.Name)
+	}
+	return fmt.Sprintf("%d: .same(proto: %q)", f.Number, f.Name)
+}
+
+// ProtoEnum is an enum of a .proto file.
+type ProtoEnum struct {
+	Name     string
+	FullName string
+	Package  string
+	Doc      string
+	Parent   *ProtoMessage
+	Values   []*ProtoEnumValue
+}
+
+// ProtoEnumValue is a value of an enum.
+type ProtoEnumValue struct {
+	Name   string
+	Doc    string
+	Number int
+	Case   string // the Swift case, without the prefix of the enum name the value may repeat
+}
+
+// SwiftName is the name of the Swift enum of the enum.
+func (e *ProtoEnum) SwiftName() string {
+	return swiftName(e.Name)
+}
+
+// swiftPath is the Swift type of the enum within the namespace of its package.
+func (e *ProtoEnum) swiftPath() string {
+	if e.Parent == nil {
+		return e.SwiftName()
+	}
+	return e.Parent.swiftPath() + "." + e.SwiftName()
+}
+
+// Cases are the values of the enum, leaving out the aliases of a value listed before them.
+func (e *ProtoEnum) Cases() []*ProtoEnumValue {
+	var cases []*ProtoEnumValue
+	seen := make(map[int]bool)
+	for _, value := range e.Values {
+		if !seen[value.Number] {
+			seen[value.Number] = true
+			cases = append(cases, value)
+		}
+	}
+	return cases
+}
+
+// defaultCase is the case of the proto3 default value of the enum, numbered 0.
+func (e *ProtoEnum) defaultCase() string {
+	for _, value := range e.Values {
+		if value.Number == 0 {
+			return value.Case
+		}
+	}
+	return e.Values[0].Case
+}
+
+// protoScalars are the scalar types of protocol buffers.
+var protoScalars = map[string]protoValue{
+	"string":   {Swift: "String", Default: `""`, Wire: "String"},
+	"bool":     {Swift: "Bool", Default: "false", Wire: "Bool", Packed: true},
+	"bytes":    {Swift: "Data", Default: "Data()", Wire: "Bytes"},
+	"double":   {Swift: "Double", Default: "0", Wire: "Double", Packed: true},
+	"float":    {Swift: "Float", Default: "0", Wire: "Float", Packed: true},
+	"int32":    {Swift: "Int32", Default: "0", Wire: "Int32", Packed: true},
+	"sint32":   {Swift: "Int32", Default: "0", Wire: "SInt32", Packed: true},
+	"sfixed32": {Swift: "Int32", Default: "0", Wire: "SFixed32", Packed: true},
+	"uint32":   {Swift: "UInt32", Default: "0", Wire: "UInt32", Packed: true},
+	"fixed32":  {Swift: "UInt32", Default: "0", Wire: "Fixed32", Packed: true},
+	"int64":    {Swift: "Int64", Coded: "ProtoInt64<Int64>", Decode: "$0.value", Encode: "ProtoInt64($0)", Default: "0", Wire: "Int64", Packed: true},
+	"sint64":   {Swift: "Int64", Coded: "ProtoInt64<Int64>", Decode: "$0.value", Encode: "ProtoInt64($0)", Default: "0", Wire: "SInt64", Packed: true},
+	"sfixed64": {Swift: "Int64", Coded: "ProtoInt64<Int64>", Decode: "$0.value", Encode: "ProtoInt64($0)", Default: "0", Wire: "SFixed64", Packed: true},
+	"uint64":   {Swift: "UInt64", Coded: "ProtoInt64<UInt64>", Decode: "$0.value", Encode: "ProtoInt64($0)", Default: "0", Wire: "UInt64", Packed: true},
+	"fixed64":  {Swift: "UInt64", Coded: "ProtoInt64<UInt64>", Decode: "$0.value", Encode: "ProtoInt64($0)", Default: "0", Wire: "Fixed64", Packed: true},
+}
+
+// protoWellKnownTypes are the well-known types of google/protobuf which map to Swift types, the wrappers mapping to
+// optional scalars.
+var protoWellKnownTypes = map[string]protoValue{
+	"google.protobuf.Timestamp":   {Swift: "Date", Coded: "ProtoTimestamp", Decode: "$0.date", Encode: "ProtoTimestamp($0)", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_Timestamp", ToWire: "SwiftProtobuf.Google_Protobuf_Timestamp(date: $0)", FromWire: "$0.date"},
+	"google.protobuf.StringValue": {Swift: "String", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_StringValue", ToWire: "SwiftProtobuf.Google_Protobuf_StringValue($0)", FromWire: "$0.value"},
+	"google.protobuf.BoolValue":   {Swift: "Bool", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_BoolValue", ToWire: "SwiftProtobuf.Google_Protobuf_BoolValue($0)", FromWire: "$0.value"},
+	"google.protobuf.BytesValue":  {Swift: "Data", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_BytesValue", ToWire: "SwiftProtobuf.Google_Protobuf_BytesValue($0)", FromWire: "$0.value"},
+	"google.protobuf.DoubleValue": {Swift: "Double", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_DoubleValue", ToWire: "SwiftProtobuf.Google_Protobuf_DoubleValue($0)", FromWire: "$0.value"},
+	"google.protobuf.FloatValue":  {Swift: "Float", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_FloatValue", ToWire: "SwiftProtobuf.Google_Protobuf_FloatValue($0)", FromWire: "$0.value"},
+	"google.protobuf.Int32Value":  {Swift: "Int32", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_Int32Value", ToWire: "SwiftProtobuf.Google_Protobuf_Int32Value($0)", FromWire: "$0.value"},
+	"google.protobuf.UInt32Value": {Swift: "UInt32", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_UInt32Value", ToWire: "SwiftProtobuf.Google_Protobuf_UInt32Value($0)", FromWire: "$0.value"},
+	"google.protobuf.Int64Value":  {Swift: "Int64", Coded: "ProtoInt64<Int64>", Decode: "$0.value", Encode: "ProtoInt64($0)", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_Int64Value", ToWire: "SwiftProtobuf.Google_Protobuf_Int64Value($0)", FromWire: "$0.value"},
+	"google.protobuf.UInt64Value": {Swift: "UInt64", Coded: "ProtoInt64<UInt64>", Decode: "$0.value", Encode: "ProtoInt64($0)", Wire: "Message", WireType: "SwiftProtobuf.Google_Protobuf_UInt64Value", ToWire: "SwiftProtobuf.Google_Protobuf_UInt64Value($0)", FromWire: "$0.value"},
+}
+
+// protoToken is a token of a .proto file.
+type protoToken struct {
+	Text   string
+	Doc    string // the comment on the lines right above the token
+	String bool   // a quoted string literal, whose Text is unquoted
+	Line   int
+}
+
+// tokenizeProto splits the content of a .proto file into tokens, attaching the comments leading a declaration to its
+// first token. Trailing comments and comments a blank line separates from the next token are dropped.
+func tokenizeProto(path string, content string) ([]protoToken, error) {
+	var tokens []protoToken
+	var comments []string
+	line, tokenLine, commentLine := 1, 0, 0
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(content[i:], "//"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content) - i
+			}
+			if line != tokenLine {
+				if line > commentLine+1 {
+					comments = nil
+				}
+				comments = append(comments, strings.TrimSpace(content[i+2:i+end]))
+				commentLine = line
+			}
+			i += end
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: unterminated comment", path, line)
+			}
+			line += strings.Count(content[i:i+end+4], "\n")
+			i += end + 4
+		default:
+			token := protoToken{Line: line}
+			if line > commentLine+1 {
+				comments = nil
+			}
+			token.Doc = strings.Join(comments, "\n")
+			comments = nil
+			switch {
+			case c == '"' || c == '\'':
+				j := i + 1
+				for j < len(content) && content[j] != c && content[j] != '\n' {
+					if content[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				if j >= len(content) || content[j] != c {
+					return nil, fmt.Errorf("%s:%d: unterminated string", path, line)
+				}
+				token.Text, token.String = content[i+1:j], true
+				i = j + 1
+			case isProtoIdentifier(c):
+				j := i
+				for j < len(content) && isProtoIdentifier(content[j]) {
+					j++
+				}
+				token.Text = content[i:j]
+				i = j
+			default:
+				token.Text = string(c)
+				i++
+			}
+			tokens = append(tokens, token)
+			tokenLine = line
+		}
+	}
+	return tokens, nil
+}
+
+func isProtoIdentifier(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// protoParser parses the tokens of a .proto file into its messages and enums, skipping options, services and
+// extensions.
+type protoParser struct {
+	path   string
+	tokens []protoToken
+	pos    int
+}
+
+func (p *protoParser) next() (protoToken, error) {
+	if p.pos >= len(p.tokens) {
+		return protoToken{}, fmt.Errorf("%s: unexpected end of file", p.path)
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *protoParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos].Text
+}
+
+func (p *protoParser) expect(text string) error {
+	token, err := p.next()
+	if err != nil {
+		return err
+	}
+	if token.Text != text || token.String {
+		return fmt.Errorf("%s:%d: expected %q but found %q", p.path, token.Line, text, token.Text)
+	}
+	return nil
+}
+
+func (p *protoParser) identifier() (string, error) {
+	token, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if token.String || !isProtoIdentifier(token.Text[0]) {
+		return "", fmt.Errorf("%s:%d: expected a name but found %q", p.path, token.Line, token.Text)
+	}
+	return token.Text, nil
+}
+
+// skipStatement skips the tokens up to the semicolon ending the statement, along with any braces it holds.
+func (p *protoParser) skipStatement() error {
+	depth := 0
+	for {
+		token, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case token.String:
+		case token.Text == "{" || token.Text == "[":
+			depth++
+		case token.Text == "}" || token.Text == "]":
+			depth--
+		case token.Text == ";" && depth == 0:
+			return nil
+		}
+	}
+}
+
+// skipBlock skips the tokens up to the brace closing the block the statement declares.
+func (p *protoParser) skipBlock() error {
+	depth := 0
+	for {
+		token, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case token.String:
+		case token.Text == "{":
+			depth++
+		case token.Text == "}":
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// parseProtoFile parses the content of a .proto file.
+func parseProtoFile(path string, content string) (*ProtoFile, error) {
+	tokens, err := tokenizeProto(path, content)
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{path: path, tokens: tokens}
+	file := &ProtoFile{Path: path}
+	for p.pos < len(p.tokens) {
+		token, _ := p.next()
+		switch token.Text {
+		case "syntax", "edition", "option":
+			err = p.skipStatement()
+		case "package":
+			if file.Package, err = p.identifier(); err == nil {
+				err = p.expect(";")
+			}
+		case "import":
+			var imported protoToken
+			if imported, err = p.next(); err == nil && !imported.String {
+				imported, err = p.next()
+			}
+			if err == nil {
+				file.Imports = append(file.Imports, imported.Text)
+				err = p.expect(";")
+			}
+		case "message":
+			var message *ProtoMessage
+			if message, err = p.parseMessage(token.Doc, file.Package, nil); err == nil {
+				file.Messages = append(file.Messages, message)
+			}
+		case "enum":
+			var enum *ProtoEnum
+			if enum, err = p.parseEnum(token.Doc, file.Package, nil); err == nil {
+				file.Enums = append(file.Enums, enum)
+			}
+		case "service", "extend":
+			err = p.skipBlock()
+		case ";":
+		default:
+			err = fmt.Errorf("%s:%d: unexpected %q", path, token.Line, token.Text)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func protoFullName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (p *protoParser) parseMessage(doc string, pkg string, parent *ProtoMessage) (*ProtoMessage, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	scope := pkg
+	if parent != nil {
+		scope = parent.FullName
+	}
+	message := &ProtoMessage{Name: name, FullName: protoFullName(scope, name), Package: pkg, Doc: doc, Parent: parent}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.peek() != "}" {
+		token, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch token.Text {
+		case "message":
+			var nested *ProtoMessage
+			if nested, err = p.parseMessage(token.Doc, pkg, message); err == nil {
+				message.Messages = append(message.Messages, nested)
+			}
+		case "enum":
+			var enum *ProtoEnum
+			if enum, err = p.parseEnum(token.Doc, pkg, message); err == nil {
+				message.Enums = append(message.Enums, enum)
+			}
+		case "oneof":
+			var oneof *ProtoOneof
+			if oneof, err = p.parseOneof(token.Doc); err == nil {
+				message.Oneofs = append(message.Oneofs, oneof)
+			}
+		case "option", "reserved", "extensions":
+			err = p.skipStatement()
+		case "extend":
+			err = p.skipBlock()
+		case ";":
+		default:
+			var field *ProtoField
+			if field, err = p.parseField(token); err == nil {
+				message.Fields = append(message.Fields, field)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return message, p.expect("}")
+}
+
+func (p *protoParser) parseOneof(doc string) (*ProtoOneof, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	oneof := &ProtoOneof{Name: name, Doc: doc}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.peek() != "}" {
+		token, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch token.Text {
+		case "option":
+			err = p.skipStatement()
+		case ";":
+		default:
+			var field *ProtoField
+			if field, err = p.parseField(token); err == nil {
+				oneof.Fields = append(oneof.Fields, field)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return oneof, p.expect("}")
+}
+
+// parseField parses a field starting with its first token, a label or its type.
+func (p *protoParser) parseField(first protoToken) (*ProtoField, error) {
+	field := &ProtoField{Doc: first.Doc}
+	token := first
+	var err error
+	switch first.Text {
+	case "repeated", "optional", "required":
+		field.Repeated = first.Text == "repeated"
+		field.Optional = first.Text == "optional"
+		if token, err = p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if token.Text == "map" && p.peek() == "<" {
+		p.pos++
+		if field.KeyType, err = p.identifier(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if field.Type, err = p.identifier(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(">"); err != nil {
+			return nil, err
+		}
+	} else if token.String || !isProtoIdentifier(token.Text[0]) {
+		return nil, fmt.Errorf("%s:%d: expected a field but found %q", p.path, token.Line, token.Text)
+	} else {
+		field.Type = token.Text
+	}
+	if field.Name, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	number, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if field.Number, err = strconv.Atoi(number.Text); err != nil {
+		return nil, fmt.Errorf("%s:%d: invalid number %q of %s", p.path, number.Line, number.Text, field.Name)
+	}
+	if p.peek() == "[" {
+		return field, p.skipStatement()
+	}
+	return field, p.expect(";")
+}
+
+func (p *protoParser) parseEnum(doc string, pkg string, parent *ProtoMessage) (*ProtoEnum, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	scope := pkg
+	if parent != nil {
+		scope = parent.FullName
+	}
+	enum := &ProtoEnum{Name: name, FullName: protoFullName(scope, name), Package: pkg, Doc: doc, Parent: parent}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.peek() != "}" {
+		token, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch token.Text {
+		case "option", "reserved":
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+			continue
+		case ";":
+			continue
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		number, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		text := number.Text
+		if text == "-" {
+			if number, err = p.next(); err != nil {
+				return nil, err
+			}
+			text = "-" + number.Text
+		}
+		value, err := strconv.ParseInt(text, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid number %q of %s", p.path, number.Line, text, token.Text)
+		}
+		if p.peek() == "[" {
+			err = p.skipStatement()
+		} else {
+			err = p.expect(";")
+		}
+		if err != nil {
+			return nil, err
+		}
+		enum.Values = append(enum.Values, &ProtoEnumValue{Name: token.Text, Doc: token.Doc, Number: int(value)})
+	}
+	if len(enum.Values) == 0 {
+		return nil, fmt.Errorf("%s: enum %s has no values", p.path, enum.FullName)
+	}
+	// Values conventionally repeat the name of their enum, as in TYPE_UNSPECIFIED of Type.
+	prefix := strings.ToUpper(camelToSnake(name)) + "_"
+	for _, value := range enum.Values {
+		trimmed := strings.TrimPrefix(value.Name, prefix)
+		if trimmed == "" || unicode.IsDigit(rune(trimmed[0])) {
+			trimmed = value.Name
+		}
+		value.Case = enumCaseName(trimmed)
+	}
+	return enum, p.expect("}")
+}
+
+// loadProtoFiles parses the .proto inputs and the files they import, which are looked up relative to the directory
+// of the importing file and its parents. The well-known types of google/protobuf are mapped instead of loaded. It
+// returns every loaded file along with the files of the inputs, once each, in the order of the inputs.
+func loadProtoFiles(inputs []string) ([]*ProtoFile, []*ProtoFile, error) {
+	var files []*ProtoFile
+	loaded := make(map[string]*ProtoFile)
+	var load func(path string) (*ProtoFile, error)
+	load = func(path string) (*ProtoFile, error) {
+		path = filepath.Clean(path)
+		if file, ok := loaded[path]; ok {
+			return file, nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parseProtoFile(path, string(content))
+		if err != nil {
+			return nil, err
+		}
+		loaded[path] = file
+		files = append(files, file)
+		for _, imported := range file.Imports {
+			if strings.HasPrefix(imported, "google/protobuf/") {
+				continue
+			}
+			found, err := findProtoImport(path, imported)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := load(found); err != nil {
+				return nil, err
 			}
 		}
+		return file, nil
 	}
-	return
+	var inputFiles []*ProtoFile
+	seen := make(map[*ProtoFile]bool)
+	for _, input := range inputs {
+		file, err := load(input)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !seen[file] {
+			seen[file] = true
+			inputFiles = append(inputFiles, file)
+		}
+	}
+	return files, inputFiles, nil
 }
 
-func isPropertyEnum(string) (output string) {
-	return
+func findProtoImport(from string, imported string) (string, error) {
+	for dir := filepath.Dir(from); ; dir = filepath.Dir(dir) {
+		candidate := filepath.Join(dir, imported)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+		if dir == filepath.Dir(dir) {
+			return "", fmt.Errorf("unable to find %s imported by %s", imported, from)
+		}
+	}
 }
 
-// pascalToCamel converts a Pascal case string to a camel case string.
-func pascalToCamel(input string) (camelCase string) {
-	if input == "" {
-		return ""
+// protoNamespace is the Swift enum the types of a package are nested in, named after the last component of the
+// package.
+func protoNamespace(pkg string) string {
+	if pkg == "" {
+		return "Proto"
 	}
-
-	camelCase = strings.ToLower(string(input[0]))
-	camelCase += string(input[1:])
-	return camelCase
+	return swiftName(snakeToPascal(pkg[strings.LastIndex(pkg, ".")+1:]))
 }
 
-func splitEnumDescription(description string) (output []string) {
-	return strings.Split(description, "\n")
+// protoGenerator resolves the types of the fields of the messages to generate, collecting the messages and enums of
+// imported packages they use along the way.
+type protoGenerator struct {
+	messages   map[string]*ProtoMessage
+	enums      map[string]*ProtoEnum
+	included   map[interface{}]bool
+	int64      bool
+	timestamps bool
 }
 
-func stripNewlines(input string) string {
-	return strings.Replace(input, "\n", " ", -1)
+func (g *protoGenerator) register(messages []*ProtoMessage, enums []*ProtoEnum) {
+	for _, enum := range enums {
+		g.enums[enum.FullName] = enum
+	}
+	for _, message := range messages {
+		g.messages[message.FullName] = message
+		g.register(message.Messages, message.Enums)
+	}
 }
 
-func stripOperationPrefix(input string) string {
-	return strings.Replace(input, "Nakama_", "", 1)
+// lookup finds the message or enum a type name refers to from a scope, searching the scope and then its parents.
+func (g *protoGenerator) lookup(scope string, name string) (*ProtoMessage, *ProtoEnum) {
+	if strings.HasPrefix(name, ".") {
+		return g.messages[name[1:]], g.enums[name[1:]]
+	}
+	parts := strings.Split(scope, ".")
+	for i := len(parts); i >= 0; i-- {
+		candidate := protoFullName(strings.Join(parts[:i], "."), name)
+		if message, ok := g.messages[candidate]; ok {
+			return message, nil
+		}
+		if enum, ok := g.enums[candidate]; ok {
+			return nil, enum
+		}
+	}
+	return nil, nil
 }
 
-func descriptionOrTitle(description string, title string) string {
-	if description != "" {
-		return description
+// swiftReference is the Swift type a message refers to another type of a package by, qualified by the namespace of
+// the package unless it is the package of the message.
+func swiftReference(message *ProtoMessage, pkg string, path string) string {
+	qualify := pkg != message.Package
+	// The oneof enums of the message and those it is nested in shadow the types of the package.
+	first := strings.SplitN(path, ".", 2)[0]
+	for parent := message; parent != nil && !qualify; parent = parent.Parent {
+		for _, oneof := range parent.Oneofs {
+			qualify = qualify || oneof.TypeName() == first
+		}
 	}
-
-	return title
+	if qualify {
+		return protoNamespace(pkg) + "." + path
+	}
+	return path
 }
 
-// camelToPascal converts a string from camel case to Pascal case.
-func camelToPascal(camelCase string) (pascalCase string) {
+func (g *protoGenerator) resolve(message *ProtoMessage, field *ProtoField) error {
+	if field.KeyType != "" && field.KeyType != "string" {
+		return fmt.Errorf("map<%s, %s> of %s.%s isn't supported, only maps with string keys are", field.KeyType, field.Type, message.FullName, field.Name)
+	}
+	name := strings.TrimPrefix(field.Type, ".")
+	if scalar, ok := protoScalars[field.Type]; ok {
+		field.Value = scalar
+	} else if known, ok := protoWellKnownTypes[name]; ok {
+		field.Value = known
+	} else if strings.HasPrefix(name, "google.protobuf.") {
+		return fmt.Errorf("%s of %s.%s isn't supported", field.Type, message.FullName, field.Name)
+	} else {
+		target, enum := g.lookup(message.FullName, field.Type)
+		switch {
+		case target != nil:
+			field.Value = protoValue{Swift: swiftReference(message, target.Package, target.swiftPath()), Wire: "Message"}
+			if err := g.include(target); err != nil {
+				return err
+			}
+		case enum != nil:
+			swift := swiftReference(message, enum.Package, enum.swiftPath())
+			// Enums are coded as their raw values, which have the wire format of int32.
+			field.Value = protoValue{Swift: swift, Default: "." + enum.defaultCase(), Wire: "Int32", WireType: "Int32", ToWire: "$0.rawValue", FromWire: swift + "(rawValue: $0)", Packed: true}
+			if enum.Parent != nil {
+				return g.include(enum.Parent)
+			}
+			g.included[enum] = true
+		default:
+			return fmt.Errorf("unknown type %s of %s.%s", field.Type, message.FullName, field.Name)
+		}
+	}
+	if field.Value.Default == "" {
+		field.Optional = true
+	}
+	g.int64 = g.int64 || strings.HasPrefix(field.Value.Coded, "ProtoInt64")
+	g.timestamps = g.timestamps || field.Value.Coded == "ProtoTimestamp"
+	return nil
+}
 
-	if len(camelCase) <= 0 {
-		return ""
+// include marks a message to generate along with the messages it is nested in and nests, resolving their fields.
+func (g *protoGenerator) include(message *ProtoMessage) error {
+	if g.included[message] {
+		return nil
+	}
+	if message.Parent != nil && !g.included[message.Parent] {
+		return g.include(message.Parent)
+	}
+	g.included[message] = true
+	for _, oneof := range message.Oneofs {
+		for _, nested := range message.Messages {
+			if nested.SwiftName() == oneof.TypeName() {
+				return fmt.Errorf("the oneof %s of %s has the Swift name of the message nested in it", oneof.Name, message.FullName)
+			}
+		}
+		for _, nested := range message.Enums {
+			if nested.SwiftName() == oneof.TypeName() {
+				return fmt.Errorf("the oneof %s of %s has the Swift name of the enum nested in it", oneof.Name, message.FullName)
+			}
+		}
+	}
+	for _, field := range message.AllFields() {
+		if err := g.resolve(message, field); err != nil {
+			return err
+		}
+	}
+	for _, enum := range message.Enums {
+		g.included[enum] = true
+	}
+	for _, nested := range message.Messages {
+		if err := g.include(nested); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	pascalCase = strings.ToUpper(string(camelCase[0])) + camelCase[1:]
-	return
+// ProtoNamespace is the Swift enum namespace of the types generated for a package.
+type ProtoNamespace struct {
+	Name         string
+	Package      string
+	Declarations string
 }
 
-func main() {
-	// Argument flags
-	var output = flag.String("output", "", "The output for generated code.")
-	flag.Parse()
+// ProtoFileContext is the data of the template generating the types of .proto files.
+type ProtoFileContext struct {
+	Namespaces []*ProtoNamespace
+	Int64      bool
+	Timestamps bool
+	Protobuf   bool
+	// SwiftProtobuf is the version of SwiftProtobuf pinned in Package.resolved the messages conform to the
+	// protocols of, when Protobuf is set.
+	SwiftProtobuf string
+	Socket        *ProtoSocket
+}
 
-	inputs := flag.Args()
-	if len(inputs) < 1 {
-		fmt.Printf("No input file found: %s\n\n", inputs)
-		fmt.Println("openapi-gen [flags] inputs...")
-		flag.PrintDefaults()
-		return
-	}
+// ProtoOptions are the options of the generator for .proto inputs.
+type ProtoOptions struct {
+	AccessLevel string
+	// Socket generates a Socket exchanging the Envelope messages of the inputs over a WebSocket.
+	Socket bool
+	// Protobuf makes the messages SwiftProtobuf messages, which the Socket exchanges as binary protocol buffers
+	// instead of JSON.
+	Protobuf bool
+	// PackageResolved is the Package.resolved pinning the SwiftProtobuf version the messages are generated for.
+	PackageResolved string
+}
 
-	inputFile := inputs[0]
-	content, err := os.ReadFile(inputFile)
+// isSupportedSwiftProtobuf reports whether the messages support a version of SwiftProtobuf. They conform to its
+// internal protocols as protoc-gen-swift does, which only 1.x versions are known to match, and are Sendable, which
+// its types are as of 1.20.
+func isSupportedSwiftProtobuf(version string) bool {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	major, err := strconv.Atoi(parts[0])
 	if err != nil {
-		fmt.Printf("Unable to read file: %s\n", err)
-		return
+		return false
 	}
+	minor, err := strconv.Atoi(parts[1])
+	return err == nil && major == 1 && minor >= 20
+}
 
-	var namespace (string) = ""
-
-	if len(inputs) > 1 {
-		if len(inputs[1]) <= 0 {
-			fmt.Println("Empty Namespace provided.")
-			return
+// swiftProtobufVersion returns the version of SwiftProtobuf pinned in a Package.resolved, failing unless the
+// generated messages support it.
+func swiftProtobufVersion(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("the protobuf format needs the SwiftProtobuf version pinned in Package.resolved: %s", err)
+	}
+	type pin struct {
+		Identity string
+		Package  string // the name of the pins of version 1 files, which nest them in an object
+		State    struct {
+			Version string
 		}
-
-		namespace = inputs[1]
 	}
-
-	var schema *Schema
-	if err := json.Unmarshal(content, &schema); err != nil {
-		fmt.Printf("Unable to decode input file %s : %s\n", inputFile, err)
-		return
+	var resolved struct {
+		Pins   []pin
+		Object struct {
+			Pins []pin
+		}
 	}
-	schema.Namespace = namespace
+	if err := json.Unmarshal(content, &resolved); err != nil {
+		return "", fmt.Errorf("invalid %s: %s", path, err)
+	}
+	for _, pin := range append(resolved.Pins, resolved.Object.Pins...) {
+		if pin.Identity != "swift-protobuf" && pin.Package != "SwiftProtobuf" {
+			continue
+		}
+		version := pin.State.Version
+		if !isSupportedSwiftProtobuf(version) {
+			return "", fmt.Errorf("SwiftProtobuf %s pinned in %s isn't supported by the protobuf format, pin a version from 1.20 before 2.0", version, path)
+		}
+		return version, nil
+	}
+	return "", fmt.Errorf("%s doesn't pin SwiftProtobuf, which the protobuf format needs", path)
+}
 
-	generateBodyDefinitionFromSchema(schema)
+// ProtoSocket is the data of the template generating the Socket, which exchanges the envelope message of the inputs.
+type ProtoSocket struct {
+	Protobuf  bool   // envelopes are exchanged as binary protocol buffers
+	Envelope  string // the Swift type of the envelope
+	Message   string // the Swift enum of the oneof of the messages the envelope carries
+	Error     string // the Swift type of the message the server answers failed requests with, if any
+	ErrorCase string // the case of the oneof carrying the error
 
-	fmap := template.FuncMap{
-		"snakeToCamel": snakeToCamel,
-		"camelToSnake": camelToSnake,
-		"cleanRef":     convertRefToClassName,
-		"isRefToEnum": func(ref string) bool {
-			// swagger schema definition keys have inconsistent casing
-			var camelOk bool
-			var pascalOk bool
-			var enums []string
+	MatchData *ProtoSocketMatchData // the match data the envelope carries, if any
+}
 
-			asCamel := pascalToCamel(ref)
-			if _, camelOk = schema.Definitions[asCamel]; camelOk {
-				enums = schema.Definitions[asCamel].Enum
-			}
+// ProtoSocketMatchData is the match data an envelope carries, the MatchData the server relays and the MatchDataSend
+// the client sends, which the Socket sends and dispatches by op code.
+type ProtoSocketMatchData struct {
+	Data     string // the Swift type of the match data the server relays
+	DataCase string // the case of the oneof carrying it
+	Send     string // the Swift type of the match data the client sends
+	SendCase string // the case of the oneof carrying it
+	OpCode   string // the Swift type of op codes
+	Presence string // the Swift type of the presences the match data is sent to
+	Reliable bool   // whether the match data can be sent unreliably
+}
 
-			asPascal := camelToPascal(ref)
-			if _, pascalOk = schema.Definitions[asPascal]; pascalOk {
-				enums = schema.Definitions[asPascal].Enum
+// protoSocket finds the envelope of the inputs, the Envelope message with a cid string field and a oneof of the
+// messages it carries, whose error case holds the errors the server answers failed requests with.
+func (g *protoGenerator) protoSocket(files []*ProtoFile) (*ProtoSocket, error) {
+	for _, file := range files {
+		for _, message := range file.Messages {
+			if message.Name != "Envelope" {
+				continue
 			}
-
-			if !pascalOk && !camelOk {
-				fmt.Printf("no definition found: %v", ref)
-				return false
+			hasCid := false
+			for _, field := range message.Fields {
+				hasCid = hasCid || (field.Name == "cid" && field.Type == "string" && !field.Repeated)
+			}
+			if !hasCid || len(message.Oneofs) != 1 {
+				return nil, fmt.Errorf("the Envelope of %s needs a cid string field and a oneof of the messages it carries", file.Path)
+			}
+			namespace := protoNamespace(message.Package)
+			oneof := message.Oneofs[0]
+			socket := &ProtoSocket{Envelope: namespace + "." + message.swiftPath(), Message: namespace + "." + message.swiftPath() + "." + oneof.TypeName()}
+			for _, field := range oneof.Fields {
+				if field.Name != "error" {
+					continue
+				}
+				if target, _ := g.lookup(message.FullName, field.Type); target != nil {
+					socket.Error = protoNamespace(target.Package) + "." + target.swiftPath()
+					socket.ErrorCase = field.Property()
+				}
 			}
+			socket.MatchData = g.protoSocketMatchData(message.FullName, oneof)
+			return socket, nil
+		}
+	}
+	return nil, fmt.Errorf("a Socket needs an Envelope message in the inputs")
+}
 
-			return len(enums) > 0
-		},
-		"pascalToCamel":        pascalToCamel,
-		"snakeToPascal":        snakeToPascal,
-		"stripNewlines":        stripNewlines,
-		"title":                strings.Title,
-		"uppercase":            strings.ToUpper,
-		"camelToPascal":        camelToPascal,
-		"splitEnumDescription": splitEnumDescription,
-		"stripOperationPrefix": stripOperationPrefix,
-		"descriptionOrTitle":   descriptionOrTitle,
+// protoSocketMatchData finds the match_data and match_data_send cases of the oneof of an envelope, returning nil
+// unless both are there and carry the match ID, op code, data and presences of the match data.
+func (g *protoGenerator) protoSocketMatchData(scope string, oneof *ProtoOneof) *ProtoSocketMatchData {
+	var data, send *ProtoField
+	for _, field := range oneof.Fields {
+		switch field.Name {
+		case "match_data":
+			data = field
+		case "match_data_send":
+			send = field
+		}
+	}
+	if data == nil || send == nil {
+		return nil
+	}
+	dataMessage, _ := g.lookup(scope, data.Type)
+	sendMessage, _ := g.lookup(scope, send.Type)
+	if dataMessage == nil || sendMessage == nil {
+		return nil
+	}
+	fields := map[string]*ProtoField{}
+	for _, field := range sendMessage.Fields {
+		fields[field.Name] = field
+	}
+	matchID, opCode, payload, presences := fields["match_id"], fields["op_code"], fields["data"], fields["presences"]
+	if matchID == nil || matchID.Type != "string" || opCode == nil || payload == nil || payload.Type != "bytes" || presences == nil || !presences.Repeated {
+		return nil
+	}
+	presence, _ := g.lookup(sendMessage.FullName, presences.Type)
+	if presence == nil {
+		return nil
+	}
+	hasOpCode := false
+	for _, field := range dataMessage.Fields {
+		hasOpCode = hasOpCode || (field.Name == "op_code" && field.Type == opCode.Type)
 	}
+	if !hasOpCode {
+		return nil
+	}
+	reliable := fields["reliable"]
+	return &ProtoSocketMatchData{
+		Data:     protoNamespace(dataMessage.Package) + "." + dataMessage.swiftPath(),
+		DataCase: data.Property(),
+		Send:     protoNamespace(sendMessage.Package) + "." + sendMessage.swiftPath(),
+		SendCase: send.Property(),
+		OpCode:   opCode.Value.Swift,
+		Presence: protoNamespace(presence.Package) + "." + presence.swiftPath(),
+		Reliable: reliable != nil && reliable.Type == "bool",
+	}
+}
 
-	tmpl, err := template.New(inputFile).Funcs(fmap).Parse(codeTemplate)
+// ProtoMessageContext is the data of the template generating the struct of a message, with the types nested in it
+// already rendered.
+type ProtoMessageContext struct {
+	Message  *ProtoMessage
+	Nested   string
+	Protobuf bool
+}
+
+// writeProtoFile generates Codable Swift types for the messages and enums of .proto inputs, such as the realtime
+// protocol of rtapi/realtime.proto, and for the messages of imported files they use. The types of every package are
+// nested in an enum named after its last component.
+func writeProtoFile(inputs []string, output string, options ProtoOptions) error {
+	accessLevel := options.AccessLevel
+	switch accessLevel {
+	case "public", "package", "internal":
+	default:
+		return fmt.Errorf("Unknown access level: %s", accessLevel)
+	}
+	files, inputFiles, err := loadProtoFiles(inputs)
 	if err != nil {
-		panic(err)
+		return err
 	}
-
-	if len(*output) < 1 {
-		tmpl.Execute(os.Stdout, schema)
-		return
+	generator := &protoGenerator{
+		messages: make(map[string]*ProtoMessage),
+		enums:    make(map[string]*ProtoEnum),
+		included: make(map[interface{}]bool),
+	}
+	for _, file := range files {
+		generator.register(file.Messages, file.Enums)
+	}
+	// Every type of the inputs is generated, and only the types of the imported files which the inputs use.
+	for _, file := range inputFiles {
+		for _, enum := range file.Enums {
+			generator.included[enum] = true
+		}
+		for _, message := range file.Messages {
+			if err := generator.include(message); err != nil {
+				return err
+			}
+		}
 	}
 
-	f, err := os.Create(*output)
+	tmpl, err := template.New("protoFile").Funcs(template.FuncMap{
+		"access": func() string {
+			// internal is the default, so it is left implicit.
+			if accessLevel == "internal" {
+				return ""
+			}
+			return accessLevel + " "
+		},
+		"protoDoc": protoDoc,
+	}).Parse(protoTemplate)
 	if err != nil {
-		fmt.Printf("Unable to create file: %s\n", err)
-		return
+		panic(err)
 	}
-	defer f.Close()
-
-	writer := bufio.NewWriter(f)
-	tmpl.Execute(writer, schema)
-	writer.Flush()
-}
 
-type Schema struct {
-	Namespace string
-	Paths     map[string]map[string]struct {
-		Summary     string
-		OperationId string
-		Responses   struct {
-			Ok struct {
-				Schema struct {
-					Ref string `json:"$ref"`
+	context := ProtoFileContext{Int64: generator.int64, Timestamps: generator.timestamps, Protobuf: options.Protobuf}
+	if options.Protobuf {
+		if context.SwiftProtobuf, err = swiftProtobufVersion(options.PackageResolved); err != nil {
+			return err
+		}
+	}
+	if options.Socket {
+		if context.Socket, err = generator.protoSocket(inputFiles); err != nil {
+			return err
+		}
+		context.Socket.Protobuf = options.Protobuf
+	}
+	namespaces := make(map[string]*ProtoNamespace)
+	for _, file := range files {
+		var declarations []string
+		for _, enum := range file.Enums {
+			if generator.included[enum] {
+				code, err := renderProtoEnum(tmpl, enum)
+				if err != nil {
+					return err
 				}
-			} `json:"200"`
+				declarations = append(declarations, code)
+			}
 		}
-		Parameters []struct {
-			Name     string
-			In       string
-			Required bool
-			Type     string   // used with primitives
-			Items    struct { // used with type "array"
-				Type string
+		for _, message := range file.Messages {
+			if generator.included[message] {
+				code, err := renderProtoMessage(tmpl, message, options.Protobuf)
+				if err != nil {
+					return err
+				}
+				declarations = append(declarations, code)
 			}
-			Format string       // used with type "boolean"
-			Schema ObjectSchema `json:"schema"`
 		}
-		Security []map[string][]struct {
+		if len(declarations) == 0 {
+			continue
+		}
+		name := protoNamespace(file.Package)
+		namespace, ok := namespaces[name]
+		if !ok {
+			namespace = &ProtoNamespace{Name: name, Package: file.Package}
+			namespaces[name] = namespace
+			context.Namespaces = append(context.Namespaces, namespace)
+		} else if namespace.Package != file.Package {
+			return fmt.Errorf("the packages %s and %s both generate the namespace %s", namespace.Package, file.Package, name)
+		}
+		for _, declaration := range declarations {
+			if namespace.Declarations != "" {
+				namespace.Declarations += "\n\n"
+			}
+			namespace.Declarations += indentProto(declaration)
 		}
 	}
-	Definitions map[string]ObjectDefinition
-}
 
-type ObjectSchema struct {
-	Type       string
-	Ref        string `json:"$ref"`
-	Properties map[string]struct {
-		Type        string
-		Description string
+	var code bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&code, "protoFile", context); err != nil {
+		return err
 	}
-	Description string
-}
-
-type ObjectDefinition struct {
-	Properties map[string]ObjectProperty
-
-	Enum        []string
-	Description string
-	// used only by enums
-	Title string
+	generated := append(bytes.TrimRight(code.Bytes(), "\n"), '\n')
+	if output == "" {
+		_, err = os.Stdout.Write(generated)
+		return err
+	}
+	return os.WriteFile(output, generated, 0644)
 }
 
-type ObjectProperty struct {
-	Type                 string
-	Ref                  string `json:"$ref"` // used with object
-	Items                Items
-	AdditionalProperties AdditionalProperties
-	Format               string // used with type "boolean"
-	Description          string
-	Title                string // used by enums
+func renderProtoEnum(tmpl *template.Template, enum *ProtoEnum) (string, error) {
+	var code bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&code, "protoEnum", enum); err != nil {
+		return "", err
+	}
+	return strings.Trim(code.String(), "\n"), nil
 }
 
-type Items struct {
-	Type string
-	Ref  string `json:"$ref"`
+func renderProtoMessage(tmpl *template.Template, message *ProtoMessage, protobuf bool) (string, error) {
+	var nested []string
+	for _, enum := range message.Enums {
+		code, err := renderProtoEnum(tmpl, enum)
+		if err != nil {
+			return "", err
+		}
+		nested = append(nested, indentProto(code))
+	}
+	for _, child := range message.Messages {
+		code, err := renderProtoMessage(tmpl, child, protobuf)
+		if err != nil {
+			return "", err
+		}
+		nested = append(nested, indentProto(code))
+	}
+	var code bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&code, "protoMessage", ProtoMessageContext{Message: message, Nested: strings.Join(nested, "\n\n"), Protobuf: protobuf}); err != nil {
+		return "", err
+	}
+	// The sections of the struct are separated by blank lines, which the empty ones leave doubled.
+	tidied := regexp.MustCompile(`\n{3,}`).ReplaceAllString(code.String(), "\n\n")
+	tidied = strings.Replace(tidied, "{\n\n", "{\n", 1)
+	return strings.Trim(tidied, "\n"), nil
 }
 
-type AdditionalProperties struct {
-	Type   string // used with type "map"
-	Format string // used with type "map"
-	Ref    string `json:"$ref"` // used with object
+// indentProto indents the lines of a declaration to nest it in another.
+func indentProto(code string) string {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "    " + line
+		}
+	}
+	return strings.Join(lines, "\n")
 }
 
-func generateBodyDefinitionFromSchema(s *Schema) {
-	// Needed because of this change: https://github.com/grpc-ecosystem/grpc-gateway/issues/1670
-	for _, def := range s.Paths {
-		if verb, ok := def["put"]; ok {
-			for idx, param := range verb.Parameters {
-				if param.In == "body" && param.Name == "body" && param.Schema.Ref == "" {
-					objectName := "Api" + strings.TrimPrefix(verb.OperationId, fmt.Sprintf("%s_", s.Namespace)) + "Request"
-					param.Schema.Ref = "#/definitions/" + objectName
-					def["put"].Parameters[idx] = param
-
-					properties := make(map[string]ObjectProperty)
-					for key, p := range param.Schema.Properties {
-						properties[key] = ObjectProperty{
-							Type:                 p.Type,
-							Items:                Items{},
-							AdditionalProperties: AdditionalProperties{},
-							Description:          p.Description,
-						}
-					}
-
-					s.Definitions[objectName] = ObjectDefinition{
-						Properties:  properties,
-						Description: param.Schema.Description,
-					}
-				}
-			}
-		}
+// protoDoc turns the comment of a declaration into a doc comment, indenting its lines.
+func protoDoc(doc string, indent string) string {
+	lines := strings.Split(doc, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(indent+"/// "+line, " ")
 	}
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,1194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the generator instead of the tests when GENERATOR_ARGS is set, so tests can run it end to end
+// with fresh flags.
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("GENERATOR_ARGS"); ok {
+		os.Args = append([]string{os.Args[0]}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runGenerator runs the generator with the flags on a swagger document, returning what it prints, the code or
+// the error.
+func runGenerator(t *testing.T, document string, flags ...string) string {
+	t.Helper()
+	input := filepath.Join(t.TempDir(), "test.swagger.json")
+	if err := os.WriteFile(input, []byte(document), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := append(append([]string{}, flags...), input, "Test")
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "GENERATOR_ARGS="+strings.Join(args, "\n"))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("generator failed: %s", err)
+	}
+	return string(output)
+}
+
+// generate runs the generator with the flags on a swagger document, returning the code it prints.
+func generate(t *testing.T, document string, flags ...string) string {
+	t.Helper()
+	code := runGenerator(t, document, flags...)
+	if !strings.HasPrefix(code, "/*") {
+		t.Fatalf("generator failed: %s", code)
+	}
+	return code
+}
+
+// assertContains fails unless the generated code contains every line.
+func assertContains(t *testing.T, code string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(code, line) {
+			t.Errorf("missing %q in:\n%s", line, code)
+		}
+	}
+}
+
+func TestSessionType(t *testing.T) {
+	document := `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "paths": {},
+  "definitions": {
+    "apiSession": {
+      "type": "object",
+      "properties": {
+        "token": {"type": "string"},
+        "refresh_token": {"type": "string"}
+      }
+    }
+  }
+}`
+	code := generate(t, document, "-session-type", "Session")
+	assertContains(t, code, "public struct Session: Sendable {", "public struct ApiSession: ", "\nenum JWT {",
+		"\n        guard let claims = JWT.claims(of: token), let exp = claims[\"exp\"] as? NSNumber else {")
+	// The initializer takes a model, which has the same access level.
+	assertContains(t, code, "\nextension Session {\n    /// Decodes the session an authentication or refresh returned.\n    public init(_ session: ApiSession) throws {")
+}
+
+func TestDecodeYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    interface{}
+		err     string
+	}{
+		{"document markers", "---\na: 1\n...\n", map[string]interface{}{"a": 1.0}, ""},
+		{"markers in block scalars", "a: |\n  x\n  ---\n  ...\n", map[string]interface{}{"a": "x\n---\n...\n"}, ""},
+		{"escapes", `a: "\/ \x41 \u00e9 \e \N \_ \""`, map[string]interface{}{"a": "/ A é \x1b \u0085 \u00a0 \""}, ""},
+		{"escaped line break", "a: \"one \\\n  two\"\n", map[string]interface{}{"a": "one two"}, ""},
+		{"escaped backslash", `a: "\\"`, map[string]interface{}{"a": `\`}, ""},
+		{"invalid escape", `a: "\q"`, nil, "invalid escape"},
+		{"multiple documents", "a: 1\n---\nb: 2\n", nil, "multiple documents"},
+		{"content after the end", "a: 1\n...\nb: 2\n", nil, "multiple documents"},
+		{"directives", "%YAML 1.2\n---\na: 1\n", nil, "directives"},
+		{"anchors", "a: &x\n  b: 1\n", nil, "anchors"},
+		{"anchored keys", "&x b: 1\n", nil, "anchors"},
+		{"aliases", "a: *x\n", nil, "anchors"},
+		{"aliases in sequences", "a:\n  - *x\n", nil, "anchors"},
+		{"aliases in flow collections", "a: [*x]\n", nil, "anchors"},
+		{"tags", "a: !!str 1\n", nil, "anchors"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeYAML([]byte(test.content))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got %v, %v, want an error containing %q", got, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+// operationDocument is a swagger document with a GET /v1/thing operation taking the parameters, given as JSON.
+func operationDocument(parameters string) string {
+	return `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "paths": {
+    "/v1/thing": {
+      "get": {
+        "operationId": "Test_GetThing",
+        "parameters": [` + parameters + `],
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Thing"}}}
+      }
+    }
+  },
+  "definitions": {"Thing": {"type": "object", "properties": {"id": {"type": "string"}}}}
+}`
+}
+
+func TestHeaderParameters(t *testing.T) {
+	tests := []struct {
+		name      string
+		parameter string
+		signature string
+		headers   string
+	}{
+		{
+			"required string",
+			`{"name": "X-Tenant", "in": "header", "required": true, "type": "string"}`,
+			"func TestGetThing(\n        xTenant: String) async throws -> Thing {",
+			"        headers[\"X-Tenant\"] = xTenant\n",
+		},
+		{
+			"optional string",
+			`{"name": "If-None-Match", "in": "header", "type": "string"}`,
+			"func TestGetThing(\n        ifNoneMatch: String?) async throws -> Thing {",
+			"        if let ifNoneMatch {\n            headers[\"If-None-Match\"] = ifNoneMatch\n        }\n",
+		},
+		{
+			"required integer",
+			`{"name": "X-Retry-Count", "in": "header", "required": true, "type": "integer"}`,
+			"func TestGetThing(\n        xRetryCount: Int) async throws -> Thing {",
+			"        headers[\"X-Retry-Count\"] = \"\\(xRetryCount)\"\n",
+		},
+		{
+			"optional integer",
+			`{"name": "X-Retry-Count", "in": "header", "type": "integer"}`,
+			"func TestGetThing(\n        xRetryCount: Int?) async throws -> Thing {",
+			"        if let xRetryCount {\n            headers[\"X-Retry-Count\"] = \"\\(xRetryCount)\"\n        }\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := generate(t, operationDocument(test.parameter))
+			assertContains(t, code, test.signature, test.headers)
+		})
+	}
+}
+
+func TestOperationURLs(t *testing.T) {
+	swagger := func(basePath string) string {
+		return `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},` + basePath + `
+  "paths": {"/v1/thing": {"get": {"operationId": "Test_GetThing", "responses": {"200": {"description": "ok"}}}}}
+}`
+	}
+	openAPI := func(server string) string {
+		return `{
+  "openapi": "3.0.0",
+  "info": {"title": "test", "version": "1.0"},
+  "servers": [{"url": "` + server + `"}],
+  "paths": {"/v1/thing": {"get": {"operationId": "Test_GetThing", "responses": {"200": {"description": "ok"}}}}}
+}`
+	}
+	tests := []struct {
+		name     string
+		document string
+		path     string
+	}{
+		{"no basePath", swagger(""), "return basePath + path"},
+		{"empty basePath", swagger(`"basePath": "",`), "return basePath + path"},
+		{"root basePath", swagger(`"basePath": "/",`), "return basePath + path"},
+		{"basePath with a trailing slash", swagger(`"basePath": "/v2/",`), `return basePath + "/v2" + path`},
+		{"server URL with a port and a path", openAPI("https://api.example.com:8443/api/v1/"), `return basePath + "/api/v1" + path`},
+		{"relative server URL", openAPI("/api"), `return basePath + "/api" + path`},
+		{"server URL without a path", openAPI("https://api.example.com"), "return basePath + path"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := generate(t, test.document)
+			// The host, port and path of the base URI are kept, without its trailing slashes.
+			assertContains(t, code,
+				"        urlComponents.host = baseUri.host\n        urlComponents.port = baseUri.port\n        urlComponents.path = operationPath(\"/v1/thing\")\n",
+				"        var basePath = baseUri.path\n        while basePath.hasSuffix(\"/\") {\n            basePath.removeLast()\n        }\n        "+test.path+"\n")
+		})
+	}
+}
+
+func TestFormattedTypes(t *testing.T) {
+	tests := []struct {
+		schemaType string
+		format     string
+		want       string
+	}{
+		{"integer", "", "Int"},
+		{"integer", "int32", "Int32"},
+		{"integer", "int64", "Int64"},
+		{"integer", "uint32", "UInt32"},
+		{"integer", "uint64", "UInt64"},
+		{"number", "", "Double"},
+		{"number", "double", "Double"},
+		{"number", "float", "Float"},
+		{"boolean", "", "Bool"},
+		{"string", "", "String"},
+		// grpc-gateway sends 64-bit integers as JSON strings.
+		{"string", "int64", "String"},
+		{"string", "uint64", "String"},
+		{"string", "uuid", "UUID"},
+	}
+	for _, test := range tests {
+		t.Run(test.schemaType+"/"+test.format, func(t *testing.T) {
+			property := ObjectProperty{Type: test.schemaType, Format: test.format}
+			if got := swiftType(property); got != test.want {
+				t.Errorf("property: got %s, want %s", got, test.want)
+			}
+			array := ObjectProperty{Type: "array", Items: Items{Type: test.schemaType, Format: test.format}}
+			if got := swiftType(array); got != "["+test.want+"]" {
+				t.Errorf("array items: got %s, want [%s]", got, test.want)
+			}
+			values := AdditionalProperties{Type: test.schemaType, Format: test.format}
+			if got := swiftType(ObjectProperty{Type: "object", AdditionalProperties: values}); got != "[String: "+test.want+"]" {
+				t.Errorf("map values: got %s, want [String: %s]", got, test.want)
+			}
+			nested := Items{Type: "object", AdditionalProperties: &values}
+			if got := swiftType(ObjectProperty{Type: "array", Items: nested}); got != "[[String: "+test.want+"]]" {
+				t.Errorf("map items: got %s, want [[String: %s]]", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnumCaseDocs(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		values      []string
+		want        map[string]string
+	}{
+		{
+			"apiOperator",
+			"- NO_OVERRIDE: Do not override the leaderboard operator.\n - BEST: Override the leaderboard operator with BEST.\n - SET: Override the leaderboard operator with SET.\n - INCREMENT: Override the leaderboard operator with INCREMENT.\n - DECREMENT: Override the leaderboard operator with DECREMENT.",
+			[]string{"NO_OVERRIDE", "BEST", "SET", "INCREMENT", "DECREMENT"},
+			map[string]string{
+				"NO_OVERRIDE": "Do not override the leaderboard operator.",
+				"BEST":        "Override the leaderboard operator with BEST.",
+				"SET":         "Override the leaderboard operator with SET.",
+				"INCREMENT":   "Override the leaderboard operator with INCREMENT.",
+				"DECREMENT":   "Override the leaderboard operator with DECREMENT.",
+			},
+		},
+		{
+			"apiStoreEnvironment with a title and continued lines",
+			"Environment where a purchase/subscription took place,\n\n - UNKNOWN: Unknown environment.\n - SANDBOX: Sandbox/test environment.\n - PRODUCTION: Production environment,\nwhere real money is spent.",
+			[]string{"UNKNOWN", "SANDBOX", "PRODUCTION"},
+			map[string]string{
+				"UNKNOWN":    "Unknown environment.",
+				"SANDBOX":    "Sandbox/test environment.",
+				"PRODUCTION": "Production environment, where real money is spent.",
+			},
+		},
+		{
+			"apiStoreProvider with more values than documented",
+			"- APPLE_APP_STORE: Apple App Store\n - GOOGLE_PLAY_STORE: Google Play Store\n - HUAWEI_APP_GALLERY: Huawei App Gallery",
+			[]string{"APPLE_APP_STORE", "GOOGLE_PLAY_STORE", "HUAWEI_APP_GALLERY", "FACEBOOK_INSTANT_STORE"},
+			map[string]string{
+				"APPLE_APP_STORE":    "Apple App Store",
+				"GOOGLE_PLAY_STORE":  "Google Play Store",
+				"HUAWEI_APP_GALLERY": "Huawei App Gallery",
+			},
+		},
+		{
+			"apiStoreProvider with fewer values than documented",
+			"- APPLE_APP_STORE: Apple App Store\n - GOOGLE_PLAY_STORE: Google Play Store\n - HUAWEI_APP_GALLERY: Huawei App Gallery\n - FACEBOOK_INSTANT_STORE: Facebook Instant Store",
+			[]string{"APPLE_APP_STORE", "GOOGLE_PLAY_STORE"},
+			map[string]string{
+				"APPLE_APP_STORE":   "Apple App Store",
+				"GOOGLE_PLAY_STORE": "Google Play Store",
+			},
+		},
+		{
+			"no case docs",
+			"The friend status.",
+			[]string{"FRIEND", "INVITE_SENT"},
+			map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := enumCaseDocs(test.description, test.values); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeywordNames(t *testing.T) {
+	tests := []struct {
+		name       string
+		identifier string // the property and parameter
+		query      string // query parameters are sent snake_case
+		enumCase   string
+	}{
+		{"protocol", "protocol_", "protocol", "protocol_"},
+		{"extension", "extension_", "extension", "extension_"},
+		{"where", "where_", "where", "where_"},
+		{"self", "self_", "self", "self_"},
+		{"Type", "Type_", "type", "type"},
+		{"default", "default_", "default", "default_"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := swiftName(test.name); got != test.identifier {
+				t.Errorf("swiftName: got %s, want %s", got, test.identifier)
+			}
+			if got := enumCaseName(test.name); got != test.enumCase {
+				t.Errorf("enumCaseName: got %s, want %s", got, test.enumCase)
+			}
+			document := `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "paths": {
+    "/v1/thing": {
+      "get": {
+        "operationId": "Test_GetThing",
+        "parameters": [{"name": "` + test.name + `", "in": "query", "type": "string"}],
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Thing"}}}
+      }
+    }
+  },
+  "definitions": {
+    "Thing": {"type": "object", "properties": {"` + test.name + `": {"type": "string"}}},
+    "Kind": {"type": "string", "enum": ["` + test.name + `"]}
+  }
+}`
+			code := generate(t, document, "-json-keys", "schema")
+			assertContains(t, code,
+				"    public var "+test.identifier+": String\n",
+				"        case "+test.identifier+" = \""+test.name+"\"\n",
+				"func TestGetThing(\n        "+test.identifier+": String?) async throws -> Thing {",
+				"        if let "+test.identifier+" {\n            queryItems.append(URLQueryItem(name: \""+test.query+"\", value: "+test.identifier+".",
+				"    case "+test.enumCase+"\n",
+				"        case \""+test.name+"\": self = ."+test.enumCase+"\n",
+				"        case ."+test.enumCase+": return \""+test.name+"\"\n")
+		})
+	}
+}
+
+func TestMergeDocuments(t *testing.T) {
+	decode := func(document string) interface{} {
+		var value interface{}
+		if err := json.Unmarshal([]byte(document), &value); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	main := `{
+  "swagger": "2.0",
+  "basePath": "/v2",
+  "security": [{"BearerJwt": []}],
+  "securityDefinitions": {"BearerJwt": {"type": "apiKey", "name": "Authorization", "in": "header"}},
+  "tags": [{"name": "Accounts"}],
+  "definitions": {"Account": {"type": "object", "properties": {"id": {"type": "string"}}}},
+  "paths": {"/account": {"get": {"operationId": "GetAccount"}}}
+}`
+	tests := []struct {
+		name  string
+		other string
+		want  string
+		err   string
+	}{
+		{
+			"security schemes, tags and produces",
+			`{
+  "swagger": "2.0",
+  "basePath": "/v2",
+  "produces": ["application/x-protobuf"],
+  "security": [{"BasicAuth": []}],
+  "securityDefinitions": {"BasicAuth": {"type": "basic"}},
+  "tags": [{"name": "Accounts"}, {"name": "Storage"}],
+  "paths": {"/storage": {"get": {"operationId": "ListStorage"}}}
+}`,
+			`{
+  "swagger": "2.0",
+  "basePath": "/v2",
+  "security": [{"BearerJwt": []}],
+  "securityDefinitions": {
+    "BearerJwt": {"type": "apiKey", "name": "Authorization", "in": "header"},
+    "BasicAuth": {"type": "basic"}
+  },
+  "tags": [{"name": "Accounts"}, {"name": "Storage"}],
+  "definitions": {"Account": {"type": "object", "properties": {"id": {"type": "string"}}}},
+  "paths": {
+    "/account": {"get": {"operationId": "GetAccount"}},
+    "/storage": {"get": {"operationId": "ListStorage", "produces": ["application/x-protobuf"], "security": [{"BasicAuth": []}]}}
+  }
+}`,
+			"",
+		},
+		{
+			"longer base path",
+			`{"swagger": "2.0", "basePath": "/v2/admin/", "paths": {"/users": {"get": {"operationId": "ListUsers"}}}}`,
+			`{
+  "swagger": "2.0",
+  "basePath": "/v2",
+  "security": [{"BearerJwt": []}],
+  "securityDefinitions": {"BearerJwt": {"type": "apiKey", "name": "Authorization", "in": "header"}},
+  "tags": [{"name": "Accounts"}],
+  "definitions": {"Account": {"type": "object", "properties": {"id": {"type": "string"}}}},
+  "paths": {
+    "/account": {"get": {"operationId": "GetAccount"}},
+    "/admin/users": {"get": {"operationId": "ListUsers", "security": []}}
+  }
+}`,
+			"",
+		},
+		{
+			"renamed definitions",
+			`{
+  "swagger": "2.0",
+  "basePath": "/v2",
+  "parameters": {"Thing": {"name": "thing", "in": "query", "type": "string"}},
+  "definitions": {
+    "Account": {"type": "object", "properties": {"id": {"type": "integer"}}},
+    "Wrapper": {"type": "object", "properties": {"account": {"$ref": "#/definitions/Account"}, "legacy": {"$ref": "#/components/schemas/Account"}}}
+  },
+  "paths": {"/wrapper": {"get": {"operationId": "GetWrapper", "parameters": [{"$ref": "#/parameters/Account"}]}}}
+}`,
+			`{
+  "swagger": "2.0",
+  "basePath": "/v2",
+  "security": [{"BearerJwt": []}],
+  "securityDefinitions": {"BearerJwt": {"type": "apiKey", "name": "Authorization", "in": "header"}},
+  "tags": [{"name": "Accounts"}],
+  "definitions": {
+    "Account": {"type": "object", "properties": {"id": {"type": "string"}}},
+    "otherAccount": {"type": "object", "properties": {"id": {"type": "integer"}}},
+    "Wrapper": {"type": "object", "properties": {"account": {"$ref": "#/definitions/otherAccount"}, "legacy": {"$ref": "#/definitions/otherAccount"}}}
+  },
+  "paths": {
+    "/account": {"get": {"operationId": "GetAccount"}},
+    "/wrapper": {"get": {"operationId": "GetWrapper", "parameters": [{"$ref": "#/parameters/Account"}], "security": []}}
+  }
+}`,
+			"",
+		},
+		{
+			"other base path",
+			`{"swagger": "2.0", "basePath": "/v3", "paths": {}}`,
+			"",
+			`the base path "/v3" of other.json can't be joined onto the base path "/v2" of main.json`,
+		},
+		{
+			"conflicting security scheme",
+			`{"swagger": "2.0", "basePath": "/v2", "securityDefinitions": {"BearerJwt": {"type": "basic"}}, "paths": {}}`,
+			"",
+			"security scheme BearerJwt of other.json conflicts with the one already defined",
+		},
+		{
+			"conflicting tag",
+			`{"swagger": "2.0", "basePath": "/v2", "tags": [{"name": "Accounts", "description": "Other"}], "paths": {}}`,
+			"",
+			"tag Accounts of other.json conflicts with the one already defined",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := mergeDocuments([]string{"main.json", "other.json"}, []interface{}{decode(main), decode(test.other)})
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got %v, want the error %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := decode(test.want); !reflect.DeepEqual(got, want) {
+				gotJSON, _ := json.MarshalIndent(got, "", "  ")
+				t.Errorf("got %s", gotJSON)
+			}
+		})
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	thing := `"responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Thing"}}}`
+	document := func(paths string) string {
+		return `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "paths": {` + paths + `},
+  "definitions": {"Thing": {"type": "object", "properties": {"id": {"type": "string"}}}}
+}`
+	}
+	tests := []struct {
+		name     string
+		document string
+		flags    []string
+		err      string
+	}{
+		{
+			"tags generating the same class",
+			document(`"/v1/a": {"get": {"operationId": "Test_GetA", "tags": ["foo-bar"], ` + thing + `}},
+    "/v1/b": {"get": {"operationId": "Test_GetB", "tags": ["foo_bar"], ` + thing + `}}`),
+			[]string{"-split-tags"},
+			"tag foo_bar and tag foo-bar both generate the type FooBarApiClient, rename the tag",
+		},
+		{
+			"methods of tags generating the same class",
+			document(`"/v1/a": {"get": {"operationId": "Test_GetThing", "tags": ["Things"], ` + thing + `}},
+    "/v1/b": {"post": {"operationId": "Test_GetThing", "tags": ["Other"], ` + thing + `}}`),
+			[]string{"-split-tags"},
+			"",
+		},
+		{
+			"Result variants",
+			document(`"/v1/a": {"get": {"operationId": "Test_GetThing", ` + thing + `}},
+    "/v1/b": {"get": {"operationId": "Test_GetThingResult", ` + thing + `}}`),
+			[]string{"-result-variants"},
+			"operations Test_GetThing and Test_GetThingResult both generate the method ApiClient.TestGetThingResult, rename one with -names",
+		},
+		{
+			"Publisher variants",
+			document(`"/v1/a": {"get": {"operationId": "Test_GetThingPublisher", ` + thing + `}},
+    "/v1/b": {"get": {"operationId": "Test_GetThing", ` + thing + `}}`),
+			[]string{"-combine"},
+			"operations Test_GetThingPublisher and Test_GetThing both generate the method ApiClient.TestGetThingPublisher, rename one with -names",
+		},
+		{
+			"variants without the flag",
+			document(`"/v1/a": {"get": {"operationId": "Test_GetThing", ` + thing + `}},
+    "/v1/b": {"get": {"operationId": "Test_GetThingResult", ` + thing + `}}`),
+			nil,
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := strings.TrimSpace(runGenerator(t, test.document, test.flags...))
+			if test.err == "" {
+				if !strings.HasPrefix(got, "/*") {
+					t.Errorf("got %s, want the generated code", got)
+				}
+			} else if got != test.err {
+				t.Errorf("got %s, want %s", got, test.err)
+			}
+		})
+	}
+}
+
+func TestSwiftProtobufVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolved string
+		want     string
+		err      string
+	}{
+		{"version 2 file", `{"pins": [{"identity": "swift-protobuf", "state": {"version": "1.22.1"}}], "version": 2}`, "1.22.1", ""},
+		{"version 1 file", `{"object": {"pins": [{"package": "SwiftProtobuf", "state": {"version": "1.20.0"}}]}, "version": 1}`, "1.20.0", ""},
+		{"too old", `{"pins": [{"identity": "swift-protobuf", "state": {"version": "1.9.0"}}]}`, "", "SwiftProtobuf 1.9.0 pinned"},
+		{"next major version", `{"pins": [{"identity": "swift-protobuf", "state": {"version": "2.0.0"}}]}`, "", "SwiftProtobuf 2.0.0 pinned"},
+		{"branch", `{"pins": [{"identity": "swift-protobuf", "state": {"branch": "main"}}]}`, "", "SwiftProtobuf  pinned"},
+		{"not pinned", `{"pins": [{"identity": "grpc-swift", "state": {"version": "1.19.1"}}]}`, "", "doesn't pin SwiftProtobuf"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "Package.resolved")
+			if err := os.WriteFile(path, []byte(test.resolved), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := swiftProtobufVersion(path)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got %s, %v, want an error containing %q", got, err, test.err)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("got %s, %v, want %s", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestAnyOfUnionClash(t *testing.T) {
+	document := `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "paths": {},
+  "definitions": {
+    "Thing": {"type": "object", "properties": {"value": {"anyOf": [{"type": "string"}, {"type": "integer"}]}}},
+    "ThingValue": {"type": "object", "properties": {"id": {"type": "string"}}}
+  }
+}`
+	got := strings.TrimSpace(runGenerator(t, document, "-anyof", "union"))
+	if want := "the anyOf of property value of Thing generates the definition ThingValue, which already exists"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	got = strings.TrimSpace(runGenerator(t, document, "-anyof", "all"))
+	if want := "unknown anyOf strategy: all"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDefsClash(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		err      string
+	}{
+		{
+			"definition",
+			`{"openapi": "3.1.0", "components": {"schemas": {
+  "Thing": {"type": "object", "$defs": {"Id": {"type": "string"}}},
+  "Id": {"type": "integer"}
+}}}`,
+			"the $defs schema Id conflicts with the definition of the same name",
+		},
+		{
+			"other $defs",
+			`{"openapi": "3.1.0", "components": {"schemas": {
+  "Thing": {"type": "object", "$defs": {"Id": {"type": "string"}}},
+  "Other": {"type": "object", "$defs": {"Id": {"type": "integer"}}}
+}}}`,
+			"the $defs schemas Id of different schemas conflict",
+		},
+		{
+			"same schema",
+			`{"openapi": "3.1.0", "components": {"schemas": {
+  "Thing": {"type": "object", "$defs": {"Id": {"type": "string"}}},
+  "Other": {"type": "object", "$defs": {"Id": {"type": "string"}}}
+}}}`,
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := decodeDocument([]byte(test.document), "json")
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || err.Error() != test.err {
+				t.Errorf("got %v, want the error %q", err, test.err)
+			}
+		})
+	}
+}
+
+// runProtoGenerator writes the .proto files to a directory and runs the generator with the flags on the inputs,
+// paths relative to the directory, returning what it prints, the code or the error.
+func runProtoGenerator(t *testing.T, files map[string]string, inputs []string, flags ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	args := append([]string{}, flags...)
+	for _, input := range inputs {
+		args = append(args, dir+string(filepath.Separator)+input)
+	}
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "GENERATOR_ARGS="+strings.Join(args, "\n"))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("generator failed: %s", err)
+	}
+	return string(output)
+}
+
+func TestProtoTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		missing []string
+		err     string
+	}{
+		{
+			"comments",
+			"// Detached.\n\n// The thing.\nmessage Thing {\n  // The id.\n  string id = 1; // Trailing.\n}\n",
+			[]string{"    /// The thing.\n    public struct Thing: ", "        /// The id.\n        public var id: String\n"},
+			[]string{"Detached", "Trailing"},
+			"",
+		},
+		{
+			"enum prefixes",
+			"enum ThingKind {\n  THING_KIND_UNSPECIFIED = 0;\n  // The first kind.\n  THING_KIND_FIRST = 1;\n  THING_KIND_2D = 2;\n  OTHER = 3;\n}\n",
+			[]string{"        case unspecified\n        /// The first kind.\n        case first\n        case thingKind2d\n        case other\n"},
+			nil,
+			"",
+		},
+		{
+			"nested lookup",
+			"message Thing {\n  message Inner { string id = 1; }\n  message Other {\n    Inner inner = 1;\n    .test.api.Thing.Inner qualified = 2;\n  }\n  Other other = 1;\n}\n",
+			[]string{"public var inner: Thing.Inner?", "public var qualified: Thing.Inner?", "public var other: Thing.Other?"},
+			nil,
+			"",
+		},
+		{
+			"string maps",
+			"message Thing {\n  map<string, string> labels = 1;\n}\n",
+			[]string{"public var labels: [String: String]"},
+			nil,
+			"",
+		},
+		{
+			"other maps",
+			"message Thing {\n  map<int32, string> labels = 1;\n}\n",
+			nil,
+			nil,
+			"map<int32, string> of test.api.Thing.labels isn't supported, only maps with string keys are",
+		},
+		{
+			"unknown types",
+			"message Thing {\n  Missing missing = 1;\n}\n",
+			nil,
+			nil,
+			"unknown type Missing of test.api.Thing.missing",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := "syntax = \"proto3\";\npackage test.api;\n\n" + test.content
+			code := runProtoGenerator(t, map[string]string{"test.proto": content}, []string{"test.proto"})
+			if test.err != "" {
+				if got := strings.TrimSpace(code); got != test.err {
+					t.Errorf("got %s, want %s", got, test.err)
+				}
+				return
+			}
+			assertContains(t, code, test.want...)
+			for _, line := range test.missing {
+				if strings.Contains(code, line) {
+					t.Errorf("unexpected %q in:\n%s", line, code)
+				}
+			}
+		})
+	}
+}
+
+func TestProtoInputs(t *testing.T) {
+	files := map[string]string{
+		"common.proto": "syntax = \"proto3\";\npackage test.common;\nmessage Used { string id = 1; }\nmessage Unused { string id = 1; }\n",
+		"a.proto":      "syntax = \"proto3\";\npackage test.api;\nimport \"common.proto\";\nmessage A { common.Used used = 1; }\n",
+		"b.proto":      "syntax = \"proto3\";\npackage test.api;\nmessage B { string name = 1; }\n",
+	}
+	// The imports of an input are loaded before the next input.
+	code := runProtoGenerator(t, files, []string{"a.proto", "b.proto"})
+	assertContains(t, code, "public struct A: ", "public struct B: ", "public struct Used: ")
+	if strings.Contains(code, "Unused") {
+		t.Errorf("unexpected Unused in:\n%s", code)
+	}
+	// An input given twice is generated once.
+	code = runProtoGenerator(t, files, []string{"b.proto", "./b.proto"})
+	if got := strings.Count(code, "public struct B: "); got != 1 {
+		t.Errorf("got %d B structs in:\n%s", got, code)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	code := generate(t, operationDocument(""), "-circuit-breaker")
+	// The adapter protocol is internal, so the adapter wrapping one can't be public.
+	assertContains(t, code,
+		"\nfinal class CircuitBreakerAdapter: HttpAdapterProtocol, @unchecked Sendable {",
+		"\n    init(adapter: HttpAdapterProtocol, failureThreshold: Int = 5, cooldown: TimeInterval = 30) {",
+		"\npublic struct CircuitOpenError: Error {",
+	)
+}
+
+func TestAccessLevel(t *testing.T) {
+	document := `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "paths": {
+    "/v1/thing": {
+      "get": {
+        "operationId": "Test_GetThing",
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Thing"}}}
+      }
+    }
+  },
+  "definitions": {
+    "Thing": {"type": "object", "properties": {"id": {"type": "string"}, "kind": {"$ref": "#/definitions/Kind"}}},
+    "Kind": {"type": "string", "enum": ["ONE", "TWO"]}
+  }
+}`
+	tests := []struct {
+		level  string
+		access string
+	}{
+		{"public", "public "},
+		{"package", "package "},
+		{"internal", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.level, func(t *testing.T) {
+			code := generate(t, document, "-access-level", test.level)
+			assertContains(t, code,
+				"\n"+test.access+"protocol ThingProtocol: Codable {",
+				"\n"+test.access+"struct Thing: ThingProtocol, CustomStringConvertible, Sendable {",
+				"\n    "+test.access+"init(\n",
+				"\n    "+test.access+"init(from decoder: Decoder) throws {",
+				"\n    "+test.access+"func encode(to encoder: Encoder) throws {",
+				"\n    "+test.access+"var description: String {",
+				"\n"+test.access+"enum Kind: ",
+				// The client takes the internal HttpAdapterProtocol, so it stays internal.
+				"\nclass ApiClient: @unchecked Sendable",
+			)
+		})
+	}
+}
+
+// socketProto is a realtime protocol with the envelope a Socket exchanges.
+const socketProto = `syntax = "proto3";
+package test.rtapi;
+
+message Envelope {
+  string cid = 1;
+  oneof message {
+    Error error = 2;
+    Ping ping = 3;
+    Pong pong = 4;
+  }
+}
+
+message Error {
+  int32 code = 1;
+  string message = 2;
+}
+
+message Ping {}
+
+message Pong {}
+`
+
+func TestSocket(t *testing.T) {
+	code := runProtoGenerator(t, map[string]string{"realtime.proto": socketProto}, []string{"realtime.proto"}, "-socket")
+	assertContains(t, code,
+		"\nextension Rtapi.Error: Swift.Error {}",
+		"\n    public var onReceive: (@Sendable (Rtapi.Envelope) -> Void)?",
+		"\n    public func request(_ message: Rtapi.Envelope.Message) async throws -> Rtapi.Envelope.Message? {",
+		"components.queryItems = [URLQueryItem(name: \"token\", value: token), URLQueryItem(name: \"format\", value: \"json\")]",
+		// Requests are failed when the task waiting is cancelled, and their timer neither outlives them nor
+		// retains the socket.
+		"\n        let response = try await withTaskCancellationHandler {",
+		"\n                timers[cid] = Task { [weak self] in",
+		"\n        } onCancel: {\n            answer(cid, with: .failure(CancellationError()))\n        }",
+		"\n        let timer = timers.removeValue(forKey: cid)\n        lock.unlock()\n        timer?.cancel()",
+		"\n        if case .error(let error) = response.message {\n            throw error\n        }",
+		// Realtime connections can be pinned like HTTP requests.
+		"\n    public init(configuration: URLSessionConfiguration = .default, serverTrustEvaluator: ServerTrustEvaluating? = nil) {",
+		"\n        ServerTrustDelegate.handle(challenge, with: serverTrustEvaluator, logger: nil, completionHandler: completionHandler)",
+	)
+	if strings.Contains(code, "onMatchData") {
+		t.Errorf("unexpected match data in:\n%s", code)
+	}
+	got := strings.TrimSpace(runProtoGenerator(t, map[string]string{"realtime.proto": "syntax = \"proto3\";\nmessage Ping {}\n"}, []string{"realtime.proto"}, "-socket"))
+	if want := "a Socket needs an Envelope message in the inputs"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestSocketMatchData(t *testing.T) {
+	matchData := strings.Replace(socketProto, "    Pong pong = 4;\n", "    Pong pong = 4;\n    MatchData match_data = 5;\n    MatchDataSend match_data_send = 6;\n", 1) + `
+message UserPresence {
+  string user_id = 1;
+}
+
+message MatchData {
+  string match_id = 1;
+  UserPresence presence = 2;
+  int64 op_code = 3;
+  bytes data = 4;
+}
+
+message MatchDataSend {
+  string match_id = 1;
+  int64 op_code = 2;
+  bytes data = 3;
+  repeated UserPresence presences = 4;
+  bool reliable = 5;
+}
+`
+	code := runProtoGenerator(t, map[string]string{"realtime.proto": matchData}, []string{"realtime.proto"}, "-socket")
+	assertContains(t, code,
+		"\n    public var onMatchData: (@Sendable (Rtapi.MatchData) -> Void)?",
+		"\n    private var matchDataHandlers: [Int64: @Sendable (Rtapi.MatchData) -> Void] = [:]",
+		"\n    public func sendMatchState(matchId: String, opCode: Int64, data: Data, presences: [Rtapi.UserPresence] = [], reliable: Bool = true) async throws {\n        try await send(.matchDataSend(Rtapi.MatchDataSend(matchId: matchId, opCode: opCode, data: data, presences: presences, reliable: reliable)))",
+		"\n    public func onMatchState<State: Decodable>(opCode: Int64, as type: State.Type, _ handler: @escaping @Sendable (State, Rtapi.MatchData) -> Void) {",
+		"\n        if case .matchData(let matchData) = envelope.message {\n            didReceiveMatchData(matchData)\n        }",
+	)
+
+	// Without a reliable field the match data can't be sent unreliably.
+	unreliable := strings.Replace(matchData, "  bool reliable = 5;\n", "", 1)
+	code = runProtoGenerator(t, map[string]string{"realtime.proto": unreliable}, []string{"realtime.proto"}, "-socket")
+	assertContains(t, code, "\n    public func sendMatchState(matchId: String, opCode: Int64, data: Data, presences: [Rtapi.UserPresence] = []) async throws {")
+
+	// Match data with another op code type than the one sent isn't dispatched.
+	mismatched := strings.Replace(matchData, "  int64 op_code = 3;\n", "  int32 op_code = 3;\n", 1)
+	code = runProtoGenerator(t, map[string]string{"realtime.proto": mismatched}, []string{"realtime.proto"}, "-socket")
+	if strings.Contains(code, "onMatchData") {
+		t.Errorf("unexpected match data in:\n%s", code)
+	}
+}
+
+// TestAdapterProtocol checks the generated code against the HttpAdapterProtocol of the Satori SDK, which isn't
+// generated: the clients only call its requirements and the CircuitBreakerAdapter implements all of them.
+func TestAdapterProtocol(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("Satori", "HttpAdapterProtocol.swift"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requirements := regexp.MustCompile(`(?m)^\s*(?:func|var) (\w+)`).FindAllStringSubmatch(string(content), -1)
+	names := make(map[string]bool)
+	for _, requirement := range requirements {
+		names[requirement[1]] = true
+	}
+	if len(names) == 0 {
+		t.Fatal("no requirements found in HttpAdapterProtocol.swift")
+	}
+	document := `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "paths": {
+    "/v1/thing": {
+      "get": {
+        "operationId": "Test_GetThing",
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Thing"}}}
+      }
+    },
+    "/v1/things": {
+      "get": {
+        "operationId": "Test_WatchThings",
+        "produces": ["text/event-stream"],
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Thing"}}}
+      }
+    }
+  },
+  "definitions": {"Thing": {"type": "object", "properties": {"id": {"type": "string"}}}}
+}`
+	code := generate(t, document, "-circuit-breaker", "-retries")
+	for _, call := range regexp.MustCompile(`(?:httpAdapter|\badapter)\.(\w+)`).FindAllStringSubmatch(code, -1) {
+		if !names[call[1]] {
+			t.Errorf("the generated code calls %s, which HttpAdapterProtocol doesn't require", call[1])
+		}
+	}
+	start := strings.Index(code, "final class CircuitBreakerAdapter")
+	if start < 0 {
+		t.Fatalf("missing CircuitBreakerAdapter in:\n%s", code)
+	}
+	adapter := code[start:]
+	adapter = adapter[:strings.Index(adapter, "\n}\n")]
+	for name := range names {
+		if !regexp.MustCompile(`\n    (?:public |package )?(?:func|var) ` + name + `\b`).MatchString(adapter) {
+			t.Errorf("CircuitBreakerAdapter doesn't implement %s", name)
+		}
+	}
+}
+
+// refreshDocument is a swagger document with a POST /v1/refresh operation taking the parameters, given as JSON, and
+// returning the session schema.
+func refreshDocument(parameters string, response string) string {
+	return `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "paths": {
+    "/v1/refresh": {
+      "post": {
+        "operationId": "Test_Refresh",
+        "parameters": [` + parameters + `],
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/` + response + `"}}}
+      }
+    }
+  },
+  "definitions": {
+    "apiSession": {"type": "object", "properties": {"token": {"type": "string"}, "refreshToken": {"type": "string"}}},
+    "apiRefreshRequest": {"type": "object", "properties": {"refreshToken": {"type": "string"}, "vars": {"type": "object", "additionalProperties": {"type": "string"}}}},
+    "apiStrictRequest": {"type": "object", "properties": {"refreshToken": {"type": "string"}, "vars": {"type": "string"}}, "required": ["refreshToken", "vars"]},
+    "apiOtherRequest": {"type": "object", "properties": {"token": {"type": "string"}}}
+  }
+}`
+}
+
+func TestSessionRefresh(t *testing.T) {
+	body := `{"name": "body", "in": "body", "required": true, "schema": {"$ref": "#/definitions/apiRefreshRequest"}}`
+	tests := []struct {
+		name       string
+		parameters string
+		response   string
+		operation  string
+		err        string
+	}{
+		{"refresh", body, "apiSession", "Test_Refresh", ""},
+		{"unknown operation", body, "apiSession", "Test_Other", "unknown session refresh operation Test_Other"},
+		{"other parameters", body + `, {"name": "force", "in": "query", "type": "boolean"}`, "apiSession", "Test_Refresh", "the session refresh operation Test_Refresh takes parameters besides its body"},
+		{"no body", "", "apiSession", "Test_Refresh", "the session refresh operation Test_Refresh has no body"},
+		{"no refresh token", strings.Replace(body, "apiRefreshRequest", "apiOtherRequest", 1), "apiSession", "Test_Refresh", "the body of the session refresh operation Test_Refresh has no refresh token"},
+		{"other required properties", strings.Replace(body, "apiRefreshRequest", "apiStrictRequest", 1), "apiSession", "Test_Refresh", "the body of the session refresh operation Test_Refresh requires vars"},
+		{"no session", body, "apiRefreshRequest", "Test_Refresh", "the session refresh operation Test_Refresh doesn't return a token and a refresh token"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := runGenerator(t, refreshDocument(test.parameters, test.response), "-session-refresh", test.operation)
+			if test.err != "" {
+				if got := strings.TrimSpace(code); got != test.err {
+					t.Errorf("got %s, want %s", got, test.err)
+				}
+				return
+			}
+			assertContains(t, code,
+				"\npublic actor SessionManager: RequestInterceptor {",
+				// Both the manager and sessions read the expiry of tokens through JWT.
+				"\nenum JWT {",
+				"\n    public static func expiry(of jwt: String) -> Date? {\n        return JWT.expiry(of: jwt)\n    }",
+				"\n        guard let expiry = SessionManager.expiry(of: token), expiry.timeIntervalSinceNow < refreshMargin else {",
+				"\n    public func sessionManager(token: String, refreshToken: String, refreshMargin: TimeInterval = 300) -> SessionManager {",
+				"\n            let session = try await TestRefresh(body: ApiRefreshRequest(refreshToken: refreshToken))",
+				"\n            return (session.token, session.refreshToken)",
+			)
+		})
+	}
+}
+
+// retryDocument is a swagger document with a GET /v1/thing and a POST /v1/thing operation, each with the
+// extensions given as JSON members.
+func retryDocument(get string, post string) string {
+	return `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "paths": {
+    "/v1/thing": {
+      "get": {
+        "operationId": "Test_GetThing",
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Thing"}}}` + get + `
+      },
+      "post": {
+        "operationId": "Test_CreateThing",
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Thing"}}}` + post + `
+      }
+    }
+  },
+  "definitions": {"Thing": {"type": "object", "properties": {"id": {"type": "string"}}}}
+}`
+}
+
+func TestRetryPolicy(t *testing.T) {
+	const xRetry = "RetryPolicy(maxRetries: %s, baseDelayMs: %s, maxDelayMs: retryPolicy.maxDelayMs, maxRetryAfterMs: retryPolicy.maxRetryAfterMs)"
+	tests := []struct {
+		name     string
+		get      string
+		post     string
+		policies string
+		wantGet  string
+		wantPost string
+		err      string
+	}{
+		{"idempotent methods", "", "", "", "retryPolicy", "", ""},
+		{"idempotent", "", `, "x-idempotent": true`, "", "retryPolicy", "retryPolicy", ""},
+		{"not idempotent", `, "x-idempotent": false`, "", "", "", "", ""},
+		{"x-retry", "", `, "x-retry": {"maxRetries": 5, "baseDelayMs": 200}`, "", "retryPolicy", fmt.Sprintf(xRetry, "5", "200"), ""},
+		{"partial x-retry", `, "x-retry": {"maxRetries": 2}`, "", "", fmt.Sprintf(xRetry, "2", "retryPolicy.baseDelayMs"), "", ""},
+		{"no retries", `, "x-retry": {"maxRetries": 0}`, "", "", "", "", ""},
+		{"negative delay", `, "x-retry": {"baseDelayMs": -1}`, "", "", "", "", "the baseDelayMs of x-retry of Test_GetThing is negative"},
+		{"invalid x-idempotent", `, "x-idempotent": "yes"`, "", "", "", "", "x-idempotent of Test_GetThing isn't a boolean"},
+		{"invalid x-retry", `, "x-retry": 3`, "", "", "", "", "x-retry of Test_GetThing isn't a retry policy"},
+		{"policy by OperationId", "", "", `{"Test_GetThing": {"idempotent": false}}`, "", "", ""},
+		{"policy by method", "", "", `{"TestCreateThing": {"maxRetries": 4}}`, "retryPolicy", fmt.Sprintf(xRetry, "4", "retryPolicy.baseDelayMs"), ""},
+		{"policy replacing x-retry", "", `, "x-retry": {"maxRetries": 5, "baseDelayMs": 200}`, `{"Test_CreateThing": {"baseDelayMs": 100}}`, "retryPolicy", fmt.Sprintf(xRetry, "retryPolicy.maxRetries", "100"), ""},
+		{"unknown policy", "", "", `{"Test_Nope": {"idempotent": true}}`, "", "", `unknown operation "Test_Nope" in the retry policies`},
+	}
+	send := regexp.MustCompile(`var response: Thing = try await (?:(.*)\.run \{ try await )?send\(`)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flags := []string{"-retries"}
+			if test.policies != "" {
+				path := filepath.Join(t.TempDir(), "policies.json")
+				if err := os.WriteFile(path, []byte(test.policies), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				flags = append(flags, "-retry-policies", path)
+			}
+			code := runGenerator(t, retryDocument(test.get, test.post), flags...)
+			if test.err != "" {
+				if !strings.Contains(code, test.err) {
+					t.Errorf("got %s, want an error containing %s", code, test.err)
+				}
+				return
+			}
+			calls := send.FindAllStringSubmatch(code, -1)
+			if len(calls) != 2 {
+				t.Fatalf("got %d requests in:\n%s", len(calls), code)
+			}
+			if calls[0][1] != test.wantGet || calls[1][1] != test.wantPost {
+				t.Errorf("got %q and %q, want %q and %q", calls[0][1], calls[1][1], test.wantGet, test.wantPost)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	code := generate(t, operationDocument(""), "-retries")
+	// The backoff is capped and jittered, and doesn't trap however many retries are configured.
+	assertContains(t, code,
+		"\n    public init(maxRetries: Int, baseDelayMs: Int, maxDelayMs: Int = 30_000, maxRetryAfterMs: Int = 30_000) {",
+		"\n        let (backoffMs, overflow) = baseDelayMs.multipliedReportingOverflow(by: 1 << min(retries, Int.bitWidth - 2))\n        let delayMs = max(0, overflow ? maxDelayMs : min(backoffMs, maxDelayMs))\n        return Int.random(in: delayMs / 2...delayMs)",
+		"\n            let delayMs = retryAfter * 1000\n            return delayMs <= Double(maxRetryAfterMs) ? Int(delayMs) : nil",
+	)
+}
+
+func TestOutbox(t *testing.T) {
+	tests := []struct {
+		name     string
+		post     string
+		flags    []string
+		wantGet  string
+		wantPost string
+		queued   bool
+	}{
+		{"write methods", "", nil, "send(", "send(", true},
+		{"x-outbox", `, "x-outbox": false`, nil, "send(", "send(", false},
+		// Offline writes are queued on their first attempt, OutboxQueuedError isn't transient.
+		{"retries", `, "x-idempotent": true`, []string{"-retries"}, "retryPolicy.run { try await send(", "retryPolicy.run { try await send(", true},
+	}
+	send := regexp.MustCompile(`var response: Thing = try await (.*send\().*?(, queueOffline: true)?\)`)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := generate(t, retryDocument("", test.post), append([]string{"-outbox"}, test.flags...)...)
+			calls := send.FindAllStringSubmatch(code, -1)
+			if len(calls) != 2 {
+				t.Fatalf("got %d requests in:\n%s", len(calls), code)
+			}
+			if calls[0][1] != test.wantGet || calls[1][1] != test.wantPost {
+				t.Errorf("got %q and %q, want %q and %q", calls[0][1], calls[1][1], test.wantGet, test.wantPost)
+			}
+			if calls[0][2] != "" || (calls[1][2] != "") != test.queued {
+				t.Errorf("got %q and %q, want the create queued: %t", calls[0][2], calls[1][2], test.queued)
+			}
+		})
+	}
+
+	code := generate(t, retryDocument("", ""), "-outbox")
+	assertContains(t, code,
+		// Writes failing while offline are queued and reported as such.
+		"\n            if queueOffline, let outbox, Outbox.isOffline(error) {\n                let queued = OutboxRequest(method: method, url: uri, headers: headers, body: body)\n                try await outbox.enqueue(queued)",
+		"\n                throw OutboxQueuedError(request: queued, underlyingError: error)",
+		// Replays stop while offline or unavailable, and the requests replayed aren't queued again.
+		"\n            } catch let error where Outbox.isOffline(error) {\n                return\n            }",
+		"\n            case 200...299:\n                requests.removeFirst()\n            case 408, 429, 500...599:\n                return",
+		"\n            try await sendRaw(method: request.method, uri: request.url, path: request.url.path, headers: request.headers, body: request.body)\n",
+		// Requests the server rejects are resolved by the hook, dropped by default.
+		"onConflict: @escaping @Sendable (OutboxRequest, ApiResponseError) async -> OutboxConflictResolution = { _, _ in .drop }",
+		"\n                switch await onConflict(request, error) {\n                case .drop:\n                    requests.removeFirst()\n                case .keep:\n                    return\n                case .replace(let replacement):\n                    requests[0] = replacement\n                }\n            }\n            try store.save(requests)",
+	)
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright © 2024 The Satori Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import Security
+import XCTest
+@testable
+import Satori
+
+final class ServerTrustEvaluatingTests: XCTestCase {
+    // Self-signed P-256 certificates, valid until 2126.
+    private let pinned = ServerTrustEvaluatingTests.certificate("MIIBkTCCATegAwIBAgIUfSxz9yJL2FF+v/HVXozBchudcHswCgYIKoZIzj0EAwIwHTEbMBkGA1UEAwwScGlubmVkLnNhdG9yaS50ZXN0MCAXDTI2MTAxNjAxNTI1NFoYDzIxMjYwOTIyMDE1MjU0WjAdMRswGQYDVQQDDBJwaW5uZWQuc2F0b3JpLnRlc3QwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAAQ8vMDPGNiZvXuRBf3sDnngAUzC+AdUsYIvzku5wYH/j7C+pEOT3YvmeKjEujSVdJr5GDQpfgSb1rRuqbc5MJuRo1MwUTAdBgNVHQ4EFgQU+1yfGxP56u+GuWVLxchnFz41cwgwHwYDVR0jBBgwFoAU+1yfGxP56u+GuWVLxchnFz41cwgwDwYDVR0TAQH/BAUwAwEB/zAKBggqhkjOPQQDAgNIADBFAiB4x/5Gq7GIT2JmXiSgwsgdhuHuy5aaCIpeHenkaugHNwIhAKYm2R/MY4mP9LqKcZPn3Q0CAExmb9V+Vd0eIdgXHtJe")
+    private let other = ServerTrustEvaluatingTests.certificate("MIIBjzCCATWgAwIBAgIUXEXzIybB8S5ExCHskVHA48lI5wYwCgYIKoZIzj0EAwIwHDEaMBgGA1UEAwwRb3RoZXIuc2F0b3JpLnRlc3QwIBcNMjYxMDE2MDE1MjU0WhgPMjEyNjA5MjIwMTUyNTRaMBwxGjAYBgNVBAMMEW90aGVyLnNhdG9yaS50ZXN0MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEI0MKYnWgvKu8HAzLFXi8y1/vCIeBxRtqcTzLjScHg/6T3uWRRiECutoUOkN+LBW2TYSUIt9ygHRhmOiavSKJDaNTMFEwHQYDVR0OBBYEFDD/u0zm4ZdUA8QROSUA4Z88Fd/rMB8GA1UdIwQYMBaAFDD/u0zm4ZdUA8QROSUA4Z88Fd/rMA8GA1UdEwEB/wQFMAMBAf8wCgYIKoZIzj0EAwIDSAAwRQIhAMR7g+fD3pf6LuKWDgMFSnsnum+LrU98Yn4+lTkRvPlXAiB5seJsLw4CJC8APLa9McSfeGK0R+lzq3KBwlO6GqF6Mg==")
+
+    private static func certificate(_ base64: String) -> SecCertificate {
+        SecCertificateCreateWithData(nil, Data(base64Encoded: base64)! as CFData)!
+    }
+
+    /// The trust of a server presenting the certificate, anchored on it so the system trusts it.
+    private func trust(_ certificate: SecCertificate) throws -> SecTrust {
+        var trust: SecTrust?
+        XCTAssertEqual(SecTrustCreateWithCertificates(certificate, SecPolicyCreateBasicX509(), &trust), errSecSuccess)
+        let created = try XCTUnwrap(trust)
+        XCTAssertEqual(SecTrustSetAnchorCertificates(created, [certificate] as CFArray), errSecSuccess)
+        return created
+    }
+
+    func test_pinnedCertificates() throws {
+        let evaluator = PinnedCertificatesTrustEvaluator(certificates: [pinned])
+        XCTAssertNoThrow(try evaluator.evaluate(trust(pinned), forHost: "pinned.satori.test"))
+        XCTAssertThrowsError(try evaluator.evaluate(trust(other), forHost: "other.satori.test")) { error in
+            XCTAssertEqual((error as? ServerTrustError)?.host, "other.satori.test")
+        }
+    }
+
+    func test_pinnedPublicKeys() throws {
+        let evaluator = PinnedPublicKeysTrustEvaluator(certificates: [pinned])
+        XCTAssertEqual(evaluator.publicKeys.count, 1)
+        XCTAssertNoThrow(try evaluator.evaluate(trust(pinned), forHost: "pinned.satori.test"))
+        XCTAssertThrowsError(try evaluator.evaluate(trust(other), forHost: "other.satori.test")) { error in
+            XCTAssertEqual((error as? ServerTrustError)?.host, "other.satori.test")
+        }
+    }
+
+    func test_pinnedPublicKeysFromKeys() throws {
+        let key = try XCTUnwrap(SecCertificateCopyKey(pinned))
+        let evaluator = PinnedPublicKeysTrustEvaluator(publicKeys: [key])
+        XCTAssertNoThrow(try evaluator.evaluate(trust(pinned), forHost: "pinned.satori.test"))
+        XCTAssertThrowsError(try evaluator.evaluate(trust(other), forHost: "other.satori.test"))
+    }
+}